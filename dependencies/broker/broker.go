@@ -25,7 +25,8 @@ type MQ interface {
 	Publish(ctx context.Context, topic string, msg *Message) error
 	// Subscribe subscription: queue is the subscribed queue. If the queue is the same,
 	// only one message will be received, otherwise multiple messages will be received, autoAck is automatic ACK.
-	Subscribe(ctx context.Context, topic []string, queue string, h Handler, autoAck bool) error
+	// The options set the retry policy and the dead letter topic of the failed messages, see SubscribeOptions.
+	Subscribe(ctx context.Context, topic []string, queue string, h Handler, autoAck bool, opts ...SubscribeOption) error
 	// Unsubscribe the topic.
 	Unsubscribe(ctx context.Context, topic []string) error
 }
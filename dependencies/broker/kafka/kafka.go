@@ -185,7 +185,7 @@ func (k *kafkaBroker) Unsubscribe(_ context.Context, topics []string) error {
 
 // Subscribe subscribe
 func (k *kafkaBroker) Subscribe(ctx context.Context, topics []string,
-	consumerGroup string, handler broker.Handler, autoAck bool,
+	consumerGroup string, handler broker.Handler, autoAck bool, opts ...broker.SubscribeOption,
 ) error {
 	if consumerGroup == "" {
 		consumerGroup = k.opts.defaultQueue
@@ -208,6 +208,8 @@ func (k *kafkaBroker) Subscribe(ctx context.Context, topics []string,
 		handler: handler,
 		cg:      cg,
 		autoAck: autoAck,
+		mq:      k,
+		opts:    broker.NewSubscribeOptions(opts...),
 	}
 	go func() {
 		for {
@@ -285,6 +287,9 @@ type consumerGroupHandler struct {
 	handler broker.Handler
 	cg      sarama.ConsumerGroup
 	autoAck bool
+	// mq publishes the failed messages to the dead letter topics
+	mq   broker.MQ
+	opts *broker.SubscribeOptions
 }
 
 // Setup the setup
@@ -304,11 +309,12 @@ func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, cl
 		}
 		m.Body = msg.Value
 		p := &publication{topic: msg.Topic, m: &m, km: msg, cg: h.cg, sess: sess}
-		err := h.handler(p)
+		// the message is acked by Handle after it is published to the dead letter topic
+		err := h.opts.Handle(sess.Context(), h.mq, h.handler, p)
 		if err == nil && h.autoAck {
 			sess.MarkMessage(msg, "")
 		} else if err != nil {
-			slog.Error(fmt.Sprintf("subscriber error %v", p.err), logActions...)
+			slog.Error(fmt.Sprintf("subscriber error %v", err), logActions...)
 		}
 	}
 	return nil
@@ -0,0 +1,130 @@
+package broker
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeadLetterSuffix the suffix of the dead letter topic of a topic
+const DeadLetterSuffix = ".dlq"
+
+// the headers of the messages published to the dead letter topics
+const (
+	// HeaderOriginalTopic the topic the message was consumed from
+	HeaderOriginalTopic = "x-original-topic"
+	// HeaderError the error of the last attempt
+	HeaderError = "x-error"
+	// HeaderAttempts the count of the attempts
+	HeaderAttempts = "x-attempts"
+)
+
+// SubscribeOptions the options of Subscribe
+type SubscribeOptions struct {
+	// MaxAttempts the max attempts of the handler for a message, the message is published to the dead letter
+	// topic (see DeadLetterTopic) after the last attempt fails. It is 0 by default, which means the message is
+	// handled once and never dead lettered.
+	MaxAttempts int
+	// Backoff the delay before the second attempt, it is doubled on every attempt, 100ms by default.
+	Backoff time.Duration
+	// MaxBackoff the max delay between the attempts, 10s by default.
+	MaxBackoff time.Duration
+}
+
+// SubscribeOption the option of Subscribe
+type SubscribeOption func(*SubscribeOptions)
+
+// WithMaxAttempts set the max attempts of the handler for a message, see SubscribeOptions.
+func WithMaxAttempts(attempts int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.MaxAttempts = attempts
+	}
+}
+
+// WithBackoff set the delay before the second attempt and the max delay between the attempts
+func WithBackoff(backoff, maxBackoff time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Backoff = backoff
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+// NewSubscribeOptions apply the options, it is used by the implementations of Subscribe.
+func NewSubscribeOptions(opts ...SubscribeOption) *SubscribeOptions {
+	o := &SubscribeOptions{
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// DeadLetterTopic returns the dead letter topic of the topic
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// Handle runs the handler for the publication by the retry policy of the options, it is used by the
+// implementations of Subscribe. After the last attempt fails, the message is published to the dead letter
+// topic by the mq with the headers of the original topic, the error and the attempts, and the publication is
+// acked. It returns the error of the handler if the message is not dead lettered, or the error of the publish.
+func (o *SubscribeOptions) Handle(ctx context.Context, mq MQ, h Handler, p Publication) error {
+	err := h(p)
+	if err == nil || o.MaxAttempts <= 0 {
+		return err
+	}
+	attempts := 1
+	for backoff := o.Backoff; attempts < o.MaxAttempts; attempts++ {
+		select {
+		case <-ctx.Done():
+			// the message is not acked, so it is consumed again
+			return err
+		case <-time.After(backoff):
+		}
+		if err = h(p); err == nil {
+			return nil
+		}
+		backoff = min(backoff*2, o.MaxBackoff)
+	}
+	msg := p.Message()
+	header := make(map[string]string, len(msg.Header)+3)
+	maps.Copy(header, msg.Header)
+	header[HeaderOriginalTopic] = p.Topic()
+	header[HeaderError] = err.Error()
+	header[HeaderAttempts] = strconv.Itoa(attempts)
+	if err = mq.Publish(ctx, DeadLetterTopic(p.Topic()), &Message{Header: header, Body: msg.Body}); err != nil {
+		return err
+	}
+	return p.Ack()
+}
+
+// ReplayHandler returns the handler of a dead letter topic which publishes the messages back to their original
+// topics by the mq, the headers of the dead letter are removed, see Replay.
+func ReplayHandler(ctx context.Context, mq MQ) Handler {
+	return func(p Publication) error {
+		msg := p.Message()
+		topic, ok := msg.Header[HeaderOriginalTopic]
+		if !ok {
+			topic = strings.TrimSuffix(p.Topic(), DeadLetterSuffix)
+		}
+		header := maps.Clone(msg.Header)
+		delete(header, HeaderOriginalTopic)
+		delete(header, HeaderError)
+		delete(header, HeaderAttempts)
+		return mq.Publish(ctx, topic, &Message{Header: header, Body: msg.Body})
+	}
+}
+
+// Replay subscribes the dead letter topic of the topic by the queue, and moves its messages back to the topic,
+// it runs until the dead letter topic is unsubscribed, for exp:
+//
+//	err := broker.Replay(ctx, b, "orders", "orders-replay")
+//	...
+//	err = b.Unsubscribe(ctx, []string{broker.DeadLetterTopic("orders")})
+func Replay(ctx context.Context, mq MQ, topic, queue string) error {
+	return mq.Subscribe(ctx, []string{DeadLetterTopic(topic)}, queue, ReplayHandler(ctx, mq), true)
+}
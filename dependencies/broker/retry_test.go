@@ -0,0 +1,152 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/broker"
+)
+
+// memoryMQ records the published messages and the handlers of the subscriptions
+type memoryMQ struct {
+	published map[string][]*broker.Message
+	handlers  map[string]broker.Handler
+}
+
+func newMemoryMQ() *memoryMQ {
+	return &memoryMQ{published: make(map[string][]*broker.Message), handlers: make(map[string]broker.Handler)}
+}
+
+func (m *memoryMQ) Init(context.Context, *url.URL) error { return nil }
+
+func (m *memoryMQ) Close(context.Context) error { return nil }
+
+func (m *memoryMQ) Publish(_ context.Context, topic string, msg *broker.Message) error {
+	m.published[topic] = append(m.published[topic], msg)
+	return nil
+}
+
+func (m *memoryMQ) Subscribe(_ context.Context, topics []string, _ string, h broker.Handler, _ bool,
+	_ ...broker.SubscribeOption,
+) error {
+	for _, topic := range topics {
+		m.handlers[topic] = h
+	}
+	return nil
+}
+
+func (m *memoryMQ) Unsubscribe(_ context.Context, topics []string) error {
+	for _, topic := range topics {
+		delete(m.handlers, topic)
+	}
+	return nil
+}
+
+type memoryPublication struct {
+	topic string
+	msg   *broker.Message
+	acked bool
+}
+
+func (p *memoryPublication) Message() *broker.Message { return p.msg }
+
+func (p *memoryPublication) Ack() error {
+	p.acked = true
+	return nil
+}
+
+func (p *memoryPublication) Topic() string { return p.topic }
+
+func TestHandleRetry(t *testing.T) {
+	ctx := context.Background()
+	mq := newMemoryMQ()
+	opts := broker.NewSubscribeOptions(broker.WithMaxAttempts(3), broker.WithBackoff(time.Millisecond, time.Millisecond))
+
+	var attempts int
+	p := &memoryPublication{topic: "orders", msg: &broker.Message{Body: []byte("1")}}
+	err := opts.Handle(ctx, mq, func(broker.Publication) error {
+		if attempts++; attempts < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	}, p)
+	if err != nil || attempts != 3 || len(mq.published) != 0 {
+		t.Fatalf("expected the third attempt succeeded, got %d %v", attempts, err)
+	}
+
+	// the message is dead lettered after the last attempt
+	attempts = 0
+	p = &memoryPublication{topic: "orders", msg: &broker.Message{Header: map[string]string{"id": "1"}, Body: []byte("1")}}
+	err = opts.Handle(ctx, mq, func(broker.Publication) error {
+		attempts++
+		return errors.New("invalid order")
+	}, p)
+	if err != nil || attempts != 3 || !p.acked {
+		t.Fatalf("expected the message dead lettered, got %d %v", attempts, err)
+	}
+	dead := mq.published["orders.dlq"]
+	if len(dead) != 1 || string(dead[0].Body) != "1" || dead[0].Header["id"] != "1" ||
+		dead[0].Header[broker.HeaderOriginalTopic] != "orders" || dead[0].Header[broker.HeaderError] != "invalid order" ||
+		dead[0].Header[broker.HeaderAttempts] != "3" {
+		t.Fatalf("unexpected dead letters %v", dead)
+	}
+	if _, ok := p.msg.Header[broker.HeaderError]; ok {
+		t.Fatal("the header of the consumed message is modified")
+	}
+
+	// the message is not retried or dead lettered by default
+	attempts = 0
+	err = broker.NewSubscribeOptions().Handle(ctx, mq, func(broker.Publication) error {
+		attempts++
+		return errors.New("failed")
+	}, p)
+	if err == nil || attempts != 1 || len(mq.published["orders.dlq"]) != 1 {
+		t.Fatalf("expected one attempt, got %d %v", attempts, err)
+	}
+}
+
+func TestHandleCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mq := newMemoryMQ()
+	opts := broker.NewSubscribeOptions(broker.WithMaxAttempts(3), broker.WithBackoff(time.Minute, time.Minute))
+	p := &memoryPublication{topic: "orders", msg: &broker.Message{}}
+	if err := opts.Handle(ctx, mq, func(broker.Publication) error { return errors.New("failed") }, p); err == nil {
+		t.Fatal("expected the error of the handler")
+	}
+	if p.acked || len(mq.published) != 0 {
+		t.Fatal("the message of the canceled subscription is dead lettered")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	mq := newMemoryMQ()
+	if err := broker.Replay(ctx, mq, "orders", "replay"); err != nil {
+		t.Fatal(err)
+	}
+	h := mq.handlers["orders.dlq"]
+	if h == nil {
+		t.Fatal("the dead letter topic is not subscribed")
+	}
+	msg := &broker.Message{
+		Header: map[string]string{
+			"id":                       "1",
+			broker.HeaderOriginalTopic: "orders",
+			broker.HeaderError:         "failed",
+			broker.HeaderAttempts:      "3",
+		},
+		Body: []byte("1"),
+	}
+	if err := h(&memoryPublication{topic: "orders.dlq", msg: msg}); err != nil {
+		t.Fatal(err)
+	}
+	replayed := mq.published["orders"]
+	if len(replayed) != 1 || string(replayed[0].Body) != "1" || len(replayed[0].Header) != 1 ||
+		replayed[0].Header["id"] != "1" {
+		t.Fatalf("unexpected replayed messages %v", replayed)
+	}
+}
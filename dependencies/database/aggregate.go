@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Aggregator is an optional interface that Database implementations can satisfy to group the rows and
+// compute the aggregate functions, see [Aggregate].
+type Aggregator interface {
+	Aggregate(ctx context.Context, table string, in *AggregateRequest) (*AggregateResponse, error)
+}
+
+// Aggregate group the rows of the table and compute the aggregate functions, see [AggregateRequest]. It
+// returns an Unimplemented error if the db does not implement Aggregator.
+func Aggregate(ctx context.Context, db Database, table string, in *AggregateRequest) (*AggregateResponse, error) {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	a, ok := db.(Aggregator)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "aggregate unimplemented for %s",
+			reflect.TypeOf(db).String())
+	}
+	return a.Aggregate(ctx, table, in)
+}
+
+// AggregateFunc the aggregate function
+type AggregateFunc string
+
+const (
+	// Count the number of rows, or the number of non-null values when the key is set
+	Count AggregateFunc = "count"
+	// Sum the sum of the values
+	Sum AggregateFunc = "sum"
+	// Avg the average of the values
+	Avg AggregateFunc = "avg"
+	// Min the minimum value
+	Min AggregateFunc = "min"
+	// Max the maximum value
+	Max AggregateFunc = "max"
+)
+
+// Aggregation an aggregate function of a field
+type Aggregation struct {
+	Func AggregateFunc `json:"func,omitempty"`
+	// Key the field to aggregate, it can be empty for Count
+	Key string `json:"key,omitempty"`
+	// As the name of the result, it is "count" for the Count of all rows and "{func}_{key}" by default
+	As string `json:"as,omitempty"`
+}
+
+// Name the name of the aggregation in the result
+func (a Aggregation) Name() string {
+	switch {
+	case a.As != "":
+		return a.As
+	case a.Key == "":
+		return string(a.Func)
+	default:
+		return string(a.Func) + "_" + a.Key
+	}
+}
+
+// AggregateRequest the group by query, the rows matching the Filters are grouped by the GroupBy keys, then the
+// groups matching the Having conditions are sorted and limited. The keys of Having and Sort are the GroupBy
+// keys or the names of the Aggregations, the groups are sorted by the GroupBy keys when Sort is empty.
+type AggregateRequest struct {
+	Filters      C             `json:"filter,omitempty"`
+	GroupBy      []string      `json:"group_by,omitempty"`
+	Aggregations []Aggregation `json:"aggregations,omitempty"`
+	Having       C             `json:"having,omitempty"`
+	Sort         []string      `json:"sort,omitempty"`
+	Limit        int           `json:"limit,omitempty"`
+}
+
+// AggregateResponse the aggregate result, each row has the GroupBy keys and the names of the Aggregations.
+// The values of Count are int64, the values of Sum and Avg are float64, Min and Max keep the type of the field.
+type AggregateResponse struct {
+	Data []map[string]any `json:"data,omitempty"`
+}
+
+var fieldNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate check the request, the keys and names must be plain field names as the request may come from
+// an http query.
+func (r *AggregateRequest) Validate() error {
+	if len(r.GroupBy) == 0 && len(r.Aggregations) == 0 {
+		return fmt.Errorf("group_by or aggregations is required")
+	}
+	fields := make(map[string]bool)
+	for _, key := range r.GroupBy {
+		if !fieldNameRegexp.MatchString(key) {
+			return fmt.Errorf("invalid group by key %q", key)
+		}
+		if fields[key] {
+			return fmt.Errorf("duplicate group by key %q", key)
+		}
+		fields[key] = true
+	}
+	for _, a := range r.Aggregations {
+		switch a.Func {
+		case Count:
+		case Sum, Avg, Min, Max:
+			if a.Key == "" {
+				return fmt.Errorf("the key of %s is required", a.Func)
+			}
+		default:
+			return fmt.Errorf("invalid aggregate function %q", a.Func)
+		}
+		if a.Key != "" && !fieldNameRegexp.MatchString(a.Key) {
+			return fmt.Errorf("invalid aggregate key %q", a.Key)
+		}
+		name := a.Name()
+		if !fieldNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid aggregate name %q", name)
+		}
+		if fields[name] {
+			return fmt.Errorf("duplicate aggregate name %q", name)
+		}
+		fields[name] = true
+	}
+	for _, key := range r.Sort {
+		if !fields[strings.TrimPrefix(key, "-")] {
+			return fmt.Errorf("sort key %q is not a group by key or an aggregate name", key)
+		}
+	}
+	return validateHaving(r.Having, fields)
+}
+
+func validateHaving(having C, fields map[string]bool) error {
+	for _, cond := range having {
+		if cond.C.IsGroup() {
+			if err := validateHaving(cond.Group(), fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fields[cond.Key] {
+			return fmt.Errorf("having key %q is not a group by key or an aggregate name", cond.Key)
+		}
+	}
+	return nil
+}
+
+// OrderBy the sort of the groups, which is Sort or the GroupBy keys.
+func (r *AggregateRequest) OrderBy() []string {
+	if len(r.Sort) > 0 {
+		return r.Sort
+	}
+	return r.GroupBy
+}
+
+// AggregateValue convert the value of the aggregate function to the type of AggregateResponse,
+// it is used by the implementations of Database.
+func AggregateValue(fn AggregateFunc, value any) any {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	switch fn {
+	case Count:
+		if v, ok := toFloat64(value); ok {
+			return int64(v)
+		}
+		return int64(0)
+	case Sum:
+		if v, ok := toFloat64(value); ok {
+			return v
+		}
+		return float64(0)
+	case Avg:
+		if v, ok := toFloat64(value); ok {
+			return v
+		}
+		return nil
+	default:
+		return value
+	}
+}
+
+// GroupValue convert the value of a group by key, the []byte of sql drivers is converted to string.
+func GroupValue(value any) any {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+var floatType = reflect.TypeFor[float64]()
+
+func toFloat64(value any) (float64, bool) {
+	if s, ok := value.(string); ok {
+		// the DECIMAL values of sql drivers
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !slices.Contains(numberKinds, v.Kind()) {
+		return 0, false
+	}
+	return v.Convert(floatType).Float(), true
+}
+
+var numberKinds = []reflect.Kind{
+	reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+	reflect.Float32, reflect.Float64,
+}
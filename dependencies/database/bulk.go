@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WriteOp the operation of a WriteModel
+type WriteOp string
+
+// the operations of BulkWrite
+const (
+	// WriteInsert insert the Doc like InsertOne
+	WriteInsert WriteOp = "insert"
+	// WriteUpdate update the doc matched by the Filter like UpdateOne, the Doc can be a D or a pointer
+	WriteUpdate WriteOp = "update"
+	// WriteUpsert replace the doc matched by the Filter, or insert the Doc, like ReplaceOne
+	WriteUpsert WriteOp = "upsert"
+	// WriteDelete delete the doc matched by the Filter like DeleteOne
+	WriteDelete WriteOp = "delete"
+)
+
+// WriteModel one write of BulkWrite
+type WriteModel struct {
+	Op WriteOp
+	// Filter the condition of update, upsert and delete
+	Filter C
+	// Doc the data of insert, update and upsert
+	Doc any
+}
+
+// InsertModel returns the model which inserts the doc
+func InsertModel(doc any) WriteModel {
+	return WriteModel{Op: WriteInsert, Doc: doc}
+}
+
+// UpdateModel returns the model which updates the doc matched by the filter
+func UpdateModel(filter C, doc any) WriteModel {
+	return WriteModel{Op: WriteUpdate, Filter: filter, Doc: doc}
+}
+
+// UpsertModel returns the model which replaces the doc matched by the filter, or inserts the doc
+func UpsertModel(filter C, doc any) WriteModel {
+	return WriteModel{Op: WriteUpsert, Filter: filter, Doc: doc}
+}
+
+// DeleteModel returns the model which deletes the doc matched by the filter
+func DeleteModel(filter C) WriteModel {
+	return WriteModel{Op: WriteDelete, Filter: filter}
+}
+
+// Validate the model
+func (w WriteModel) Validate() error {
+	switch w.Op {
+	case WriteInsert, WriteUpdate, WriteUpsert:
+		if w.Doc == nil {
+			return fmt.Errorf("the doc of %s is required", w.Op)
+		}
+	case WriteDelete:
+	default:
+		return fmt.Errorf("unknown write op %q", w.Op)
+	}
+	return nil
+}
+
+// Write executes the model by InsertOne, UpdateOne, ReplaceOne or DeleteOne of the db, it returns the count
+// of the written docs, an update which matches nothing returns zero instead of a NotFound error.
+func (w WriteModel) Write(ctx context.Context, db Database, table string) (int, error) {
+	if err := w.Validate(); err != nil {
+		return 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+	switch w.Op {
+	case WriteInsert:
+		if err := db.InsertOne(ctx, table, w.Doc); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	case WriteUpdate, WriteUpsert:
+		write := db.UpdateOne
+		if w.Op == WriteUpsert {
+			write = db.ReplaceOne
+		}
+		count, err := write(ctx, table, w.Filter, w.Doc)
+		if status.Code(err) == codes.NotFound {
+			// matching nothing is not an error in a bulk write
+			return 0, nil
+		}
+		return count, err
+	default:
+		return db.DeleteOne(ctx, table, w.Filter)
+	}
+}
+
+// BulkWriteOptions the options of BulkWrite
+type BulkWriteOptions struct {
+	// Ordered stop at the first failed model, the rest models are skipped. It is true by default.
+	Ordered bool
+}
+
+// BulkWriteOption the option of BulkWrite
+type BulkWriteOption func(*BulkWriteOptions)
+
+// WithOrdered set whether BulkWrite stops at the first failed model, the models are always written in order.
+func WithOrdered(ordered bool) BulkWriteOption {
+	return func(o *BulkWriteOptions) {
+		o.Ordered = ordered
+	}
+}
+
+// NewBulkWriteOptions apply the options, it is used by the implementations of BulkWrite.
+func NewBulkWriteOptions(opts ...BulkWriteOption) *BulkWriteOptions {
+	o := &BulkWriteOptions{
+		Ordered: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WriteResult the outcome of a WriteModel
+type WriteResult struct {
+	// Err the error of the model, it is nil if the model is written or skipped
+	Err error
+	// Skipped the model is not written as a previous model failed in the ordered mode
+	Skipped bool
+}
+
+// BulkWriteResult the result of BulkWrite
+type BulkWriteResult struct {
+	// Inserted the count of the inserted docs
+	Inserted int
+	// Updated the count of the updated, replaced and upserted docs
+	Updated int
+	// Deleted the count of the deleted docs
+	Deleted int
+	// Results the outcome of each model by the index
+	Results []WriteResult
+}
+
+// NewBulkWriteResult returns the result of n models
+func NewBulkWriteResult(n int) *BulkWriteResult {
+	return &BulkWriteResult{
+		Results: make([]WriteResult, n),
+	}
+}
+
+// Add the count of the written docs of the op
+func (r *BulkWriteResult) Add(op WriteOp, count int) {
+	switch op {
+	case WriteInsert:
+		r.Inserted += count
+	case WriteUpdate, WriteUpsert:
+		r.Updated += count
+	case WriteDelete:
+		r.Deleted += count
+	}
+}
+
+// Fail set the error of the model
+func (r *BulkWriteResult) Fail(index int, err error) {
+	r.Results[index].Err = err
+}
+
+// Skip mark the models from the index as skipped
+func (r *BulkWriteResult) Skip(from int) {
+	for i := from; i < len(r.Results); i++ {
+		r.Results[i].Skipped = true
+	}
+}
+
+// Count the count of the written docs
+func (r *BulkWriteResult) Count() int {
+	return r.Inserted + r.Updated + r.Deleted
+}
+
+// Err returns a *BulkError of the failed models, it is nil if no model failed.
+func (r *BulkWriteResult) Err() error {
+	var bulkErr *BulkError
+	for i, result := range r.Results {
+		if result.Err == nil {
+			continue
+		}
+		if bulkErr == nil {
+			bulkErr = &BulkError{Err: result.Err}
+		}
+		bulkErr.Elements = append(bulkErr.Elements, &BulkElement{
+			Index:   i,
+			Message: result.Err.Error(),
+		})
+	}
+	if bulkErr == nil {
+		return nil
+	}
+	return bulkErr
+}
+
+// BulkWriter is an optional interface that Database implementations can satisfy to write the models with
+// the bulk API of the backend, see [BulkWrite].
+type BulkWriter interface {
+	BulkWrite(ctx context.Context, table string, models []WriteModel, opts ...BulkWriteOption) (*BulkWriteResult, error)
+}
+
+// BulkWrite the insert, update, upsert and delete models, see [WriteModel] and [BulkWriteResult]. The models
+// are written one by one by [WriteEach] if the db does not implement BulkWriter.
+func BulkWrite(ctx context.Context, db Database, table string, models []WriteModel, opts ...BulkWriteOption,
+) (*BulkWriteResult, error) {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	if w, ok := db.(BulkWriter); ok {
+		return w.BulkWrite(ctx, table, models, opts...)
+	}
+	return WriteEach(ctx, db, table, models, opts...)
+}
+
+// WriteEach write the models one by one with the db, it is the BulkWrite of the backends without a bulk API.
+func WriteEach(ctx context.Context, db Database, table string, models []WriteModel, opts ...BulkWriteOption,
+) (*BulkWriteResult, error) {
+	if len(models) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no write models found")
+	}
+	o := NewBulkWriteOptions(opts...)
+	result := NewBulkWriteResult(len(models))
+	for i, model := range models {
+		count, err := model.Write(ctx, db, table)
+		if err != nil {
+			result.Fail(i, err)
+			if o.Ordered {
+				result.Skip(i + 1)
+				break
+			}
+			continue
+		}
+		result.Add(model.Op, count)
+	}
+	return result, result.Err()
+}
@@ -0,0 +1,60 @@
+package database_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+type BulkUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestWriteModelValidate(t *testing.T) {
+	byID := database.C{{Key: "id", Value: int64(1)}}
+	for _, model := range []database.WriteModel{
+		database.InsertModel(&BulkUser{ID: 1}),
+		database.UpdateModel(byID, database.D{{Key: "name", Value: "Alice"}}),
+		database.UpsertModel(byID, &BulkUser{ID: 1}),
+		database.DeleteModel(byID),
+	} {
+		if err := model.Validate(); err != nil {
+			t.Errorf("Unexpected error of %s: %v", model.Op, err)
+		}
+	}
+	for _, model := range []database.WriteModel{
+		database.InsertModel(nil),
+		database.UpdateModel(byID, nil),
+		{Op: "merge"},
+	} {
+		if err := model.Validate(); err == nil {
+			t.Errorf("Expected the model %+v is invalid", model)
+		}
+	}
+}
+
+func TestBulkWriteResult(t *testing.T) {
+	result := database.NewBulkWriteResult(4)
+	result.Add(database.WriteInsert, 1)
+	result.Add(database.WriteUpsert, 1)
+	if err := result.Err(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	failed := errors.New("failed")
+	result.Fail(2, failed)
+	result.Skip(3)
+	if result.Inserted != 1 || result.Updated != 1 || result.Count() != 2 || !result.Results[3].Skipped ||
+		result.Results[1].Skipped {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	var bulkErr *database.BulkError
+	if err := result.Err(); !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 1 ||
+		bulkErr.Elements[0].Index != 2 || !errors.Is(bulkErr.Err, failed) {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if !database.NewBulkWriteOptions().Ordered || database.NewBulkWriteOptions(database.WithOrdered(false)).Ordered {
+		t.Error("Expected the bulk write is ordered by default")
+	}
+}
@@ -0,0 +1,487 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ti/common-go/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cache the cache of the cached decorator, the values are encoded by json, *mqlru.Lru and the Cache of
+// *redis.Redis implement it. Get returns a NotFound error when the key is missing, Set deletes the key
+// when the data is nil or the ttl is zero.
+type Cache interface {
+	Get(ctx context.Context, key string, data any) error
+	Set(ctx context.Context, key string, data any, ttl time.Duration) error
+}
+
+// LocalCache is an optional interface that the Cache can satisfy to store the values of this replica only,
+// the results are cached by it, so only the generations of the tables are sent to the other replicas.
+// *mqlru.Lru implements it.
+type LocalCache interface {
+	SetLocal(ctx context.Context, key string, data any, ttl time.Duration) error
+}
+
+// CacheOptions the options of the cached decorator
+type CacheOptions struct {
+	// TTL the ttl of the tables which are not in Tables, the tables are not cached if it is zero.
+	TTL time.Duration
+	// Tables the ttl of each table
+	Tables map[string]time.Duration
+	// Prefix the prefix of the cache keys, it is "db:" by default.
+	Prefix string
+}
+
+// CacheOption the option of NewCached
+type CacheOption func(*CacheOptions)
+
+// WithCacheTTL cache all the tables with the ttl, the ttl of WithTableCacheTTL takes precedence.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithTableCacheTTL cache the table with the ttl, a zero ttl disables the cache of the table.
+func WithTableCacheTTL(table string, ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) {
+		if o.Tables == nil {
+			o.Tables = make(map[string]time.Duration)
+		}
+		o.Tables[table] = ttl
+	}
+}
+
+// WithCachePrefix set the prefix of the cache keys, the databases which share a cache must use different prefixes.
+func WithCachePrefix(prefix string) CacheOption {
+	return func(o *CacheOptions) {
+		o.Prefix = prefix
+	}
+}
+
+// NewCached wrap the db with the read-through cache of FindOne, Exist and Count, for exp:
+//
+//	lru, _ := mqlru.New(ctx, "kafka://127.0.0.1:9092/cache?ttl=5m")
+//	db = database.NewCached(db, lru, database.WithTableCacheTTL("users", time.Minute))
+//
+// The results are cached by the table and the hash of the conditions. Every table has a generation in the
+// cache which is a part of the keys of its results, Insert, Update, Replace, Delete and the counters change
+// the generation of the table, so all the cached results of the table are invalidated at once. With
+// mqlru the new generation is sent to every replica over the broker and the results are cached locally
+// (see LocalCache), with redis it is shared.
+//
+// The reads in a transaction are not cached, and the writes in a transaction started by the decorator, or
+// in a transaction which implements AfterCommitter, invalidate the tables after the commit. The writes in
+// the other transactions invalidate the tables at once. A read which runs concurrently with a write may
+// cache the result before the write, it is stale until the ttl at most. The models with encrypted fields
+// (see EncryptedFields) are never cached, so the plaintext is not stored in the cache.
+func NewCached(db Database, cache Cache, opts ...CacheOption) Database {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	o := CacheOptions{
+		Prefix: "db:",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &cachedDB{
+		Database: db,
+		cache:    cache,
+		opts:     o,
+	}
+}
+
+// cachedDB the read-through cache decorator
+type cachedDB struct {
+	Database
+	cache    Cache
+	opts     CacheOptions
+	project  string
+	tx       Transaction
+	unscoped bool
+}
+
+// ttl the ttl of the table, it is zero if the table is not cached
+func (c *cachedDB) ttl(table string) time.Duration {
+	if ttl, ok := c.opts.Tables[table]; ok {
+		return ttl
+	}
+	return c.opts.TTL
+}
+
+// generationKey the key of the generation of the table
+func (c *cachedDB) generationKey(table string) string {
+	return c.opts.Prefix + c.project + ":" + table
+}
+
+// generation returns the generation of the table, a new generation is set if it is missing, so the results
+// cached before the generation is evicted are never used again.
+func (c *cachedDB) generation(ctx context.Context, table string, ttl time.Duration) (string, error) {
+	key := c.generationKey(table)
+	var generation string
+	err := c.cache.Get(ctx, key, &generation)
+	if err == nil && generation != "" {
+		return generation, nil
+	}
+	if status.Code(err) != codes.NotFound && err != nil {
+		return "", err
+	}
+	generation = strconv.FormatInt(time.Now().UnixNano(), 36)
+	return generation, c.cache.Set(ctx, key, generation, ttl)
+}
+
+// invalidate change the generation of the table after a write, the write in a transaction which is started
+// by the decorator or implements AfterCommitter invalidates the table after the commit, so the data before
+// the commit is not cached by the concurrent reads with the new generation.
+func (c *cachedDB) invalidate(ctx context.Context, table string) {
+	ttl := c.ttl(table)
+	if ttl <= 0 {
+		return
+	}
+	key := c.generationKey(table)
+	switch tx := c.tx.(type) {
+	case *cachedTx:
+		tx.mu.Lock()
+		tx.tables[key] = ttl
+		tx.mu.Unlock()
+	case AfterCommitter:
+		ctx = context.WithoutCancel(ctx)
+		tx.AfterCommit(func() {
+			c.setGeneration(ctx, key, ttl)
+		})
+	default:
+		c.setGeneration(ctx, key, ttl)
+	}
+}
+
+func (c *cachedDB) setGeneration(ctx context.Context, key string, ttl time.Duration) {
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := c.cache.Set(ctx, key, generation, ttl); err != nil {
+		log.Extract(ctx).Action("database.cache.invalidate").Error("%s: %s", key, err)
+	}
+}
+
+// cached get the result of the read from the cache, or read it from the database and cache it.
+func (c *cachedDB) cached(ctx context.Context, table, op string, condition C, data any,
+	read func() error,
+) error {
+	ttl := c.ttl(table)
+	if ttl <= 0 || c.tx != nil {
+		return read()
+	}
+	conds, err := json.Marshal(condition)
+	if err != nil {
+		return read()
+	}
+	generation, err := c.generation(ctx, table, ttl)
+	if err != nil {
+		log.Extract(ctx).Action("database.cache.get").Error("%s: %s", table, err)
+		return read()
+	}
+	if c.unscoped {
+		op += ":unscoped"
+	}
+	hash := sha256.Sum256(conds)
+	key := c.generationKey(table) + ":" + generation + ":" + op + ":" + hex.EncodeToString(hash[:])
+	if err = c.cache.Get(ctx, key, data); err == nil {
+		return nil
+	}
+	if err = read(); err != nil {
+		return err
+	}
+	if local, ok := c.cache.(LocalCache); ok {
+		err = local.SetLocal(ctx, key, data, ttl)
+	} else {
+		err = c.cache.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		log.Extract(ctx).Action("database.cache.set").Error("%s: %s", table, err)
+	}
+	return nil
+}
+
+// Unscoped implements Unscoper, the unscoped results are cached apart from the scoped ones.
+func (c *cachedDB) Unscoped() Database {
+	if _, ok := c.Database.(Unscoper); !ok {
+		return c
+	}
+	unscoped := *c
+	unscoped.Database = Unscoped(c.Database)
+	unscoped.unscoped = true
+	return &unscoped
+}
+
+// GetDatabase implements Database
+func (c *cachedDB) GetDatabase(ctx context.Context, project string) (Database, error) {
+	db, err := c.Database.GetDatabase(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedDB{
+		Database: db,
+		cache:    c.cache,
+		opts:     c.opts,
+		project:  project,
+		unscoped: c.unscoped,
+	}, nil
+}
+
+// StartTransaction implements Database, the tables written in the transaction are invalidated after the commit.
+func (c *cachedDB) StartTransaction(ctx context.Context) (Transaction, error) {
+	tx, err := c.Database.StartTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedTx{
+		Transaction: tx,
+		ctx:         context.WithoutCancel(ctx),
+		db:          c,
+		tables:      make(map[string]time.Duration),
+	}, nil
+}
+
+// WithTransaction implements Database, the reads in the transaction are not cached.
+func (c *cachedDB) WithTransaction(ctx context.Context, tx Transaction) Database {
+	txDB := *c
+	txDB.tx = tx
+	if cached, ok := tx.(*cachedTx); ok {
+		tx = cached.Transaction
+	}
+	txDB.Database = c.Database.WithTransaction(ctx, tx)
+	return &txDB
+}
+
+// cachedTx the transaction which invalidates the written tables after the commit
+type cachedTx struct {
+	Transaction
+	CommitHooks
+	ctx    context.Context
+	db     *cachedDB
+	mu     sync.Mutex
+	tables map[string]time.Duration
+}
+
+// Commit implements Transaction
+func (t *cachedTx) Commit() error {
+	if err := t.Transaction.Commit(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	for key, ttl := range t.tables {
+		t.db.setGeneration(t.ctx, key, ttl)
+	}
+	t.mu.Unlock()
+	t.Committed()
+	return nil
+}
+
+// FindOne implements Database, the models with encrypted fields are not cached, so the decrypted values are
+// never stored in the cache.
+func (c *cachedDB) FindOne(ctx context.Context, table string, condition C, data any) error {
+	if EncryptedFields(data) != nil {
+		return c.Database.FindOne(ctx, table, condition, data)
+	}
+	// the fields of the data are selected, so the type is a part of the key
+	op := "findOne:" + reflect.TypeOf(data).String()
+	return c.cached(ctx, table, op, condition, data, func() error {
+		return c.Database.FindOne(ctx, table, condition, data)
+	})
+}
+
+// Exist implements Database
+func (c *cachedDB) Exist(ctx context.Context, table string, condition C) (exist bool, err error) {
+	err = c.cached(ctx, table, "exist", condition, &exist, func() error {
+		exist, err = c.Database.Exist(ctx, table, condition)
+		return err
+	})
+	return exist, err
+}
+
+// Count implements Database
+func (c *cachedDB) Count(ctx context.Context, table string, condition C) (count int64, err error) {
+	err = c.cached(ctx, table, "count", condition, &count, func() error {
+		count, err = c.Database.Count(ctx, table, condition)
+		return err
+	})
+	return count, err
+}
+
+// Aggregate implements Aggregator, the aggregations are not cached
+func (c *cachedDB) Aggregate(ctx context.Context, table string, in *AggregateRequest) (*AggregateResponse, error) {
+	return Aggregate(ctx, c.Database, table, in)
+}
+
+// Insert implements Database
+func (c *cachedDB) Insert(ctx context.Context, table string, docs any) (int, error) {
+	count, err := c.Database.Insert(ctx, table, docs)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// InsertOne implements Database
+func (c *cachedDB) InsertOne(ctx context.Context, table string, data any) error {
+	err := c.Database.InsertOne(ctx, table, data)
+	if err == nil {
+		c.invalidate(ctx, table)
+	}
+	return err
+}
+
+// Update implements Database
+func (c *cachedDB) Update(ctx context.Context, table string, condition C, doc any) (int, error) {
+	count, err := c.Database.Update(ctx, table, condition, doc)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// UpdateOne implements Database
+func (c *cachedDB) UpdateOne(ctx context.Context, table string, condition C, doc any) (int, error) {
+	count, err := c.Database.UpdateOne(ctx, table, condition, doc)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// Replace implements Database
+func (c *cachedDB) Replace(ctx context.Context, table string, indexKeys []string, docs any) (int, error) {
+	count, err := c.Database.Replace(ctx, table, indexKeys, docs)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// ReplaceOne implements Database
+func (c *cachedDB) ReplaceOne(ctx context.Context, table string, condition C, data any) (int, error) {
+	count, err := c.Database.ReplaceOne(ctx, table, condition, data)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// Delete implements Database
+func (c *cachedDB) Delete(ctx context.Context, table string, condition C) (int, error) {
+	count, err := c.Database.Delete(ctx, table, condition)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// DeleteOne implements Database
+func (c *cachedDB) DeleteOne(ctx context.Context, table string, condition C) (int, error) {
+	count, err := c.Database.DeleteOne(ctx, table, condition)
+	if count > 0 {
+		c.invalidate(ctx, table)
+	}
+	return count, err
+}
+
+// BulkWrite implements BulkWriter
+func (c *cachedDB) BulkWrite(ctx context.Context, table string, models []WriteModel, opts ...BulkWriteOption,
+) (*BulkWriteResult, error) {
+	result, err := BulkWrite(ctx, c.Database, table, models, opts...)
+	if result != nil && result.Count() > 0 {
+		c.invalidate(ctx, table)
+	}
+	return result, err
+}
+
+// IncrCounter implements Database
+func (c *cachedDB) IncrCounter(ctx context.Context, counterTable, key string, start, count int64) error {
+	err := c.Database.IncrCounter(ctx, counterTable, key, start, count)
+	if err == nil {
+		c.invalidate(ctx, counterTable)
+	}
+	return err
+}
+
+// DecrCounter implements Database
+func (c *cachedDB) DecrCounter(ctx context.Context, counterTable, key string, count int64) error {
+	err := c.Database.DecrCounter(ctx, counterTable, key, count)
+	if err == nil {
+		c.invalidate(ctx, counterTable)
+	}
+	return err
+}
+
+// DoPageQuery implements PageQuerier
+func (c *cachedDB) DoPageQuery(ctx context.Context, table string, in *PageQueryRequest, result any) error {
+	q, ok := c.Database.(PageQuerier)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "PageQuery unimplemented for %s",
+			reflect.TypeOf(c.Database).String())
+	}
+	return q.DoPageQuery(ctx, table, in, result)
+}
+
+// DoStreamQuery implements StreamQuerier
+func (c *cachedDB) DoStreamQuery(ctx context.Context, table string, in *StreamQueryRequest, result any) error {
+	q, ok := c.Database.(StreamQuerier)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "StreamQuery unimplemented for %s",
+			reflect.TypeOf(c.Database).String())
+	}
+	return q.DoStreamQuery(ctx, table, in, result)
+}
+
+// Watch implements Watcher
+func (c *cachedDB) Watch(ctx context.Context, table string, filter C, opts ...WatchOption,
+) (<-chan ChangeEvent, error) {
+	w, ok := c.Database.(Watcher)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Watch unimplemented for %s",
+			reflect.TypeOf(c.Database).String())
+	}
+	return w.Watch(ctx, table, filter, opts...)
+}
+
+// ProvisionTenant implements TenantManager
+func (c *cachedDB) ProvisionTenant(ctx context.Context, project string) error {
+	return ProvisionTenant(ctx, c.Database, project)
+}
+
+// ListTenants implements TenantManager
+func (c *cachedDB) ListTenants(ctx context.Context) ([]string, error) {
+	return ListTenants(ctx, c.Database)
+}
+
+// Savepoint implements Savepointer
+func (c *cachedDB) Savepoint(ctx context.Context, name string) error {
+	return Savepoint(ctx, c.Database, name)
+}
+
+// RollbackToSavepoint implements Savepointer
+func (c *cachedDB) RollbackToSavepoint(ctx context.Context, name string) error {
+	return RollbackToSavepoint(ctx, c.Database, name)
+}
+
+// ReleaseSavepoint implements Savepointer
+func (c *cachedDB) ReleaseSavepoint(ctx context.Context, name string) error {
+	return ReleaseSavepoint(ctx, c.Database, name)
+}
+
+// IndexNames implements Indexer
+func (c *cachedDB) IndexNames(ctx context.Context, table string) ([]string, error) {
+	return IndexNames(ctx, c.Database, table)
+}
+
+// CreateIndex implements Indexer
+func (c *cachedDB) CreateIndex(ctx context.Context, table string, index *Index) error {
+	return CreateIndex(ctx, c.Database, table, index)
+}
@@ -13,11 +13,13 @@ type Database interface {
 	Close(ctx context.Context) error
 	Insert(ctx context.Context, table string, docs any) (count int, err error)
 	InsertOne(ctx context.Context, table string, data any) error
-	// Update the doc, the value of doc can be [database.D] or any other pointer.
+	// Update the doc, the value of doc can be [database.D] or any other pointer. The values of a [database.D]
+	// are written as they are, see [EncryptDocument] for the encrypted fields.
 	Update(ctx context.Context, table string, condition C, doc any) (count int, err error)
 	// UpdateOne the value of doc can be [database.D] or any other pointer.
 	UpdateOne(ctx context.Context, table string, condition C, doc any) (count int, err error)
-	// Replace update data in bulk
+	// Replace update data in bulk by the index keys, the missing docs are inserted by postgres and the mock,
+	// the other implementations update the existing docs only.
 	Replace(ctx context.Context, table string, indexKeys []string, docs any) (count int, err error)
 	// ReplaceOne replace
 	ReplaceOne(ctx context.Context, table string, condition C, data any) (count int, err error)
@@ -44,6 +46,12 @@ type Row interface {
 	Next() bool
 }
 
+// RowScanner is an optional interface of the Row whose Decode does not return the type of the oneData of
+// FindRows, for exp the mock decodes the rows as map[string]any. Scan decodes the current row into the data.
+type RowScanner interface {
+	Scan(data any) error
+}
+
 // New sql client.
 func New(ctx context.Context, uri string) (Database, error) {
 	u, err := url.Parse(uri)
@@ -70,20 +78,30 @@ type DB struct {
 	Database
 }
 
-// Init by uri
+// Init by uri, the softDelete, createdAt and updatedAt queries wrap the database with [NewScoped], for exp:
+// mysql://127.0.0.1:3306/db?softDelete=deleted_at&createdAt=created_at&updatedAt=updated_at
 func (d *DB) Init(ctx context.Context, u *url.URL) (err error) {
+	scopeOpts, scoped := parseScopeOptions(u)
 	for k, v := range implements {
 		if k == u.Scheme {
 			d.Database, err = v(ctx, u)
 			if err != nil {
 				return
 			}
+			if scoped {
+				d.Database = NewScoped(d.Database, scopeOpts)
+			}
 			return nil
 		}
 	}
 	return fmt.Errorf("%s not implement", u.Scheme)
 }
 
+// Unscoped returns the database without the soft delete filter and timestamps, see [Unscoped].
+func (d *DB) Unscoped() Database {
+	return Unscoped(d.Database)
+}
+
 // Close the db.
 func (d *DB) Close(ctx context.Context) error {
 	return d.Database.Close(ctx)
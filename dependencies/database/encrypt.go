@@ -0,0 +1,393 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// encryptedPrefix the prefix of the encrypted values, the format is enc:<key id>:<base64 of nonce and ciphertext>
+const encryptedPrefix = "enc:"
+
+// KeyProvider provides the AES keys of the encrypted fields, the keys are 16, 24 or 32 bytes to select
+// AES-128, AES-192 or AES-256. A key is identified by its id, which is stored with the encrypted value, so
+// the values encrypted by the previous keys are decrypted after the current key is rotated.
+type KeyProvider interface {
+	// CurrentKey returns the id and the key to encrypt the values
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key of the id to decrypt the values
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys the KeyProvider of the fixed keys, the keys are indexed by their ids.
+type StaticKeys struct {
+	// Current the id of the key to encrypt the values
+	Current string
+	Keys    map[string][]byte
+}
+
+// CurrentKey implements KeyProvider
+func (s *StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := s.Key(s.Current)
+	return s.Current, key, err
+}
+
+// Key implements KeyProvider
+func (s *StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "encryption key %s not found", id)
+	}
+	return key, nil
+}
+
+var keyProvider struct {
+	mu       sync.RWMutex
+	provider KeyProvider
+}
+
+// SetKeyProvider set the key provider of the encrypted fields of all the databases, for exp:
+//
+//	database.SetKeyProvider(&database.StaticKeys{Current: "k1", Keys: map[string][]byte{"k1": key}})
+func SetKeyProvider(provider KeyProvider) {
+	keyProvider.mu.Lock()
+	defer keyProvider.mu.Unlock()
+	keyProvider.provider = provider
+}
+
+func getKeyProvider() (KeyProvider, error) {
+	keyProvider.mu.RLock()
+	defer keyProvider.mu.RUnlock()
+	if keyProvider.provider == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the key provider of the encrypted fields is not set")
+	}
+	return keyProvider.provider, nil
+}
+
+// EncryptOf check if the field is encrypted by the db tag, the options of the tag are:
+//
+//   - encrypt: the value is encrypted by AES-GCM with a random nonce
+//   - encrypt=deterministic: the nonce is the HMAC-SHA256 of the value, so the equal values have the equal
+//     encrypted values, it is the blind index which keeps the equality conditions working
+//
+// Only the string and *string fields can be encrypted, for exp:
+//
+//	type User struct {
+//		Phone   string  `json:"phone" db:"encrypt=deterministic"`
+//		Address *string `json:"address" db:"encrypt"`
+//	}
+func EncryptOf(field reflect.StructField) (encrypted, deterministic bool) {
+	if value, ok := TagOptionValue(field, "encrypt"); ok {
+		return true, value == "deterministic"
+	}
+	return HasTagOption(field, "encrypt"), false
+}
+
+// Encrypt encrypts the value by the current key of the key provider, see [EncryptOf].
+func Encrypt(value string, deterministic bool) (string, error) {
+	provider, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	id, key, err := provider.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if deterministic {
+		// the mac key is derived from the key, so the nonce does not reveal the mac of the key
+		mac := hmac.New(sha256.New, blindKey(key))
+		mac.Write([]byte(value))
+		copy(nonce, mac.Sum(nil))
+	} else {
+		_, _ = rand.Read(nonce)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(id))
+	return encryptedPrefix + id + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the value encrypted by Encrypt, a value which is not encrypted is returned as it is,
+// so the plain values written before the field is encrypted are still read.
+func Decrypt(value string) (string, error) {
+	data, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, data, ok := strings.Cut(data, ":")
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return value, nil
+	}
+	provider, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	key, err := provider.Key(id)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", status.Errorf(codes.DataLoss, "invalid encrypted value of the key %s", id)
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", status.Errorf(codes.DataLoss, "decrypt the value of the key %s error %v", id, err)
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid encryption key %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func blindKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("blind index"))
+	return mac.Sum(nil)
+}
+
+// EncryptValue encrypts the string or *string value of an encrypted field, a nil *string is nil.
+func EncryptValue(v reflect.Value, deterministic bool) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.String {
+		return nil, status.Errorf(codes.InvalidArgument, "the encrypted field must be a string, got %s", v.Type())
+	}
+	return Encrypt(v.String(), deterministic)
+}
+
+// DecryptValue decrypts the value and sets it to the string or *string field.
+func DecryptValue(field reflect.Value, value string) error {
+	plain, err := Decrypt(value)
+	if err != nil {
+		return err
+	}
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(plain)
+	case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.String:
+		ptr := reflect.New(field.Type().Elem())
+		ptr.Elem().SetString(plain)
+		field.Set(ptr)
+	default:
+		return status.Errorf(codes.InvalidArgument, "the encrypted field must be a string, got %s", field.Type())
+	}
+	return nil
+}
+
+// DecryptFields decrypts the encrypted fields of the decoded model in place.
+func DecryptFields(data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return decryptFields(v.Elem())
+}
+
+func decryptFields(v reflect.Value) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous {
+			if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := decryptFields(fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if encrypted, _ := EncryptOf(sf); !encrypted {
+			continue
+		}
+		value := fv
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.String || value.Len() == 0 {
+			continue
+		}
+		if err := DecryptValue(fv, value.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncryptedFields returns the keys of the encrypted fields of the model, the values are the
+// deterministic modes, it is nil if the model has no encrypted field.
+func EncryptedFields(model any) map[string]bool {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	if fields, ok := encryptedFieldsCache.Load(t); ok {
+		return fields.(map[string]bool)
+	}
+	fields := make(map[string]bool)
+	appendEncryptedFields(fields, t)
+	if len(fields) == 0 {
+		fields = nil
+	}
+	encryptedFieldsCache.Store(t, fields)
+	return fields
+}
+
+// encryptedFieldsCache the encrypted fields of the model types
+var encryptedFieldsCache sync.Map
+
+func appendEncryptedFields(fields map[string]bool, t reflect.Type) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				appendEncryptedFields(fields, ft)
+			}
+			continue
+		}
+		if encrypted, deterministic := EncryptOf(sf); encrypted {
+			fields[fieldName(sf)] = deterministic
+		}
+	}
+}
+
+// EncryptConditions encrypts the values of the conditions of the encrypted fields of the model. The
+// deterministic fields support the Eq, Ne, In and Nin conditions, the other encrypted fields can not
+// be the conditions. The values are encrypted by the current key, so the rows encrypted by the previous
+// keys are not matched until they are written again.
+func EncryptConditions(conds C, model any) (C, error) {
+	fields := EncryptedFields(model)
+	if fields == nil {
+		return conds, nil
+	}
+	var encrypted C
+	for i, v := range conds {
+		deterministic, ok := fields[v.Key]
+		if !ok {
+			continue
+		}
+		if !deterministic {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"the field %s is encrypted, use encrypt=deterministic to query it", v.Key)
+		}
+		value, err := encryptCondValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if encrypted == nil {
+			encrypted = append(C(nil), conds...)
+		}
+		encrypted[i].Value = value
+	}
+	if encrypted == nil {
+		return conds, nil
+	}
+	return encrypted, nil
+}
+
+func encryptCondValue(cond CE) (any, error) {
+	switch cond.C {
+	case Eq, Ne:
+		return EncryptValue(reflect.ValueOf(cond.Value), true)
+	case In, Nin:
+		v := reflect.ValueOf(cond.Value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return EncryptValue(v, true)
+		}
+		values := make([]string, v.Len())
+		for i := range v.Len() {
+			value, err := EncryptValue(v.Index(i), true)
+			if err != nil {
+				return nil, err
+			}
+			values[i], _ = value.(string)
+		}
+		return values, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument,
+			"the encrypted field %s supports the Eq, Ne, In and Nin conditions only", cond.Key)
+	}
+}
+
+// EncryptDocument encrypts the values of the doc of the encrypted fields of the model, the backends write the
+// values of a [D] or a map as they are, so the partial updates of the encrypted fields are encrypted by it,
+// for exp:
+//
+//	doc, err := database.EncryptDocument(database.D{{Key: "phone", Value: phone}}, &User{})
+//	...
+//	_, err = db.Update(ctx, "users", conds, doc)
+func EncryptDocument(doc D, model any) (D, error) {
+	fields := EncryptedFields(model)
+	if fields == nil {
+		return doc, nil
+	}
+	var encrypted D
+	for i, v := range doc {
+		deterministic, ok := fields[v.Key]
+		if !ok {
+			continue
+		}
+		value, err := EncryptValue(reflect.ValueOf(v.Value), deterministic)
+		if err != nil {
+			return nil, err
+		}
+		if encrypted == nil {
+			encrypted = append(D(nil), doc...)
+		}
+		encrypted[i].Value = value
+	}
+	if encrypted == nil {
+		return doc, nil
+	}
+	return encrypted, nil
+}
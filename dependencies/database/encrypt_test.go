@@ -0,0 +1,105 @@
+package database_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type EncryptedUser struct {
+	Name    string  `json:"name"`
+	Phone   string  `json:"phone" db:"encrypt=deterministic"`
+	Address *string `json:"address" db:"encrypt"`
+}
+
+func setTestKeys(t *testing.T, current string) {
+	t.Helper()
+	database.SetKeyProvider(&database.StaticKeys{Current: current, Keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+		"k2": []byte("fedcba9876543210"),
+	}})
+	t.Cleanup(func() {
+		database.SetKeyProvider(nil)
+	})
+}
+
+func TestEncrypt(t *testing.T) {
+	if _, err := database.Encrypt("secret", false); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected the key provider is not set, got %v", err)
+	}
+	setTestKeys(t, "k1")
+	first, err := database.Encrypt("secret", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := database.Encrypt("secret", false)
+	if !strings.HasPrefix(first, "enc:k1:") || first == second {
+		t.Errorf("Expected the random encrypted values, got %s and %s", first, second)
+	}
+	first, _ = database.Encrypt("secret", true)
+	second, _ = database.Encrypt("secret", true)
+	if first != second {
+		t.Errorf("Expected the equal deterministic values, got %s and %s", first, second)
+	}
+
+	// the values of the previous key are decrypted after the rotation
+	setTestKeys(t, "k2")
+	if plain, err := database.Decrypt(first); err != nil || plain != "secret" {
+		t.Errorf("Expected secret, got %s error %v", plain, err)
+	}
+	// the values written before the field is encrypted are read as they are
+	if plain, err := database.Decrypt("plain"); err != nil || plain != "plain" {
+		t.Errorf("Expected plain, got %s error %v", plain, err)
+	}
+	tampered := first[:len(first)-2] + "AA"
+	if _, err = database.Decrypt(tampered); status.Code(err) != codes.DataLoss {
+		t.Errorf("Expected the tampered value is rejected, got %v", err)
+	}
+}
+
+func TestDecryptFields(t *testing.T) {
+	setTestKeys(t, "k1")
+	phone, _ := database.Encrypt("+1234567890", true)
+	address, _ := database.Encrypt("123 Main St", false)
+	user := &EncryptedUser{Name: "Alice", Phone: phone, Address: &address}
+	if err := database.DecryptFields(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Phone != "+1234567890" || *user.Address != "123 Main St" || user.Name != "Alice" {
+		t.Errorf("Unexpected decrypted user %+v", user)
+	}
+}
+
+func TestEncryptConditions(t *testing.T) {
+	setTestKeys(t, "k1")
+	conds := database.C{
+		{Key: "name", Value: "Alice"},
+		{Key: "phone", Value: []string{"1", "2"}, C: database.In},
+	}
+	encrypted, err := database.EncryptConditions(conds, &EncryptedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, _ := database.Encrypt("1", true)
+	two, _ := database.Encrypt("2", true)
+	if encrypted[0].Value != "Alice" || !slices.Equal(encrypted[1].Value.([]string), []string{one, two}) {
+		t.Errorf("Unexpected encrypted conditions %v", encrypted)
+	}
+	if !slices.Equal(conds[1].Value.([]string), []string{"1", "2"}) {
+		t.Errorf("Expected the conditions are not changed, got %v", conds)
+	}
+
+	for _, cond := range []database.CE{
+		{Key: "address", Value: "123 Main St"},
+		{Key: "phone", Value: "+1", C: database.Like},
+	} {
+		_, err = database.EncryptConditions(database.C{cond}, &EncryptedUser{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected the condition %s is invalid, got %v", cond.Key, err)
+		}
+	}
+}
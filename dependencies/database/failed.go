@@ -0,0 +1,94 @@
+package database
+
+import "context"
+
+// Failed returns a database whose calls all fail with the err, it is returned by WithTransaction of the
+// implementations for a transaction which is not started by them, so the misuse fails as an error instead of
+// a panic.
+func Failed(err error) Database {
+	return &failedDB{err: err}
+}
+
+type failedDB struct {
+	err error
+}
+
+func (f *failedDB) GetDatabase(context.Context, string) (Database, error) {
+	return nil, f.err
+}
+
+func (f *failedDB) Close(context.Context) error {
+	return f.err
+}
+
+func (f *failedDB) Insert(context.Context, string, any) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) InsertOne(context.Context, string, any) error {
+	return f.err
+}
+
+func (f *failedDB) Update(context.Context, string, C, any) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) UpdateOne(context.Context, string, C, any) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) Replace(context.Context, string, []string, any) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) ReplaceOne(context.Context, string, C, any) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) Delete(context.Context, string, C) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) DeleteOne(context.Context, string, C) (int, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) Find(context.Context, string, C, []string, int, any) error {
+	return f.err
+}
+
+func (f *failedDB) FindOne(context.Context, string, C, any) error {
+	return f.err
+}
+
+func (f *failedDB) FindRows(context.Context, string, C, []string, int, any) (Row, error) {
+	return nil, f.err
+}
+
+func (f *failedDB) Exist(context.Context, string, C) (bool, error) {
+	return false, f.err
+}
+
+func (f *failedDB) Count(context.Context, string, C) (int64, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) IncrCounter(context.Context, string, string, int64, int64) error {
+	return f.err
+}
+
+func (f *failedDB) DecrCounter(context.Context, string, string, int64) error {
+	return f.err
+}
+
+func (f *failedDB) GetCounter(context.Context, string, string) (int64, error) {
+	return 0, f.err
+}
+
+func (f *failedDB) StartTransaction(context.Context) (Transaction, error) {
+	return nil, f.err
+}
+
+func (f *failedDB) WithTransaction(context.Context, Transaction) Database {
+	return f
+}
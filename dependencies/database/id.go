@@ -0,0 +1,137 @@
+package database
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// IDKey the key of the primary key of the docs, it is the _id column of sql and the _id of mongo.
+const IDKey = "_id"
+
+// IDGenerator generates the ids of the inserted docs whose id fields are zero, for exp: snowflake.ID of
+// tools/snowflake, see [WithIDGenerator].
+type IDGenerator func() int64
+
+// ID the id field of a model, it is the field of the db:"id" tag, or the field whose json or bson name is _id,
+// for exp:
+//
+//	type User struct {
+//		ID   int64  `json:"_id"`
+//		Name string `json:"name"`
+//	}
+//
+// InsertOne and Insert fill the id generated by the database into the zero id field of the _id key, which is the
+// _id of sql, or the ObjectID of mongo, a string id field gets the ObjectIDToBase64 form of the ObjectID.
+// A db:"id" field of another key, for exp `json:"id" db:"id"`, is written as a field of the doc, so its id is
+// generated by the [IDGenerator] before the insert.
+type ID struct {
+	// Key the key of the id field in the doc
+	Key   string
+	field reflect.Value
+}
+
+// IDOf returns the id field of the pointer of struct, it is false if the model has no id field.
+func IDOf(data any) (*ID, bool) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	key, field, ok := findIDField(v.Elem())
+	if !ok {
+		key = IDKey
+		field, ok = fieldByName(v.Elem(), IDKey)
+	}
+	if !ok || !field.CanSet() {
+		return nil, false
+	}
+	return &ID{Key: key, field: field}, true
+}
+
+// IsGenerated check if the id is generated by the database, the id of the _id key is filled by the insert.
+func (id *ID) IsGenerated() bool {
+	return id.Key == IDKey
+}
+
+func findIDField(v reflect.Value) (string, reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous {
+			if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if key, field, ok := findIDField(fv); ok {
+					return key, field, true
+				}
+			}
+			continue
+		}
+		if HasTagOption(sf, "id") {
+			return fieldName(sf), fv, true
+		}
+	}
+	return "", reflect.Value{}, false
+}
+
+// IsZero check if the id is not set
+func (id *ID) IsZero() bool {
+	return id.field.IsZero()
+}
+
+// Value the value of the id field
+func (id *ID) Value() any {
+	return id.field.Interface()
+}
+
+// Set set the id field to the value, an integer can be set to the integer and string fields, the other
+// values must be assignable to the field. It is false if the value can not be set.
+func (id *ID) Set(value any) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return false
+	}
+	if v.Type().AssignableTo(id.field.Type()) {
+		id.field.Set(v)
+		return true
+	}
+	if !v.CanInt() {
+		return false
+	}
+	switch {
+	case id.field.CanInt():
+		id.field.SetInt(v.Int())
+	case id.field.CanUint():
+		id.field.SetUint(uint64(v.Int()))
+	case id.field.Kind() == reflect.String:
+		id.field.SetString(strconv.FormatInt(v.Int(), 10))
+	default:
+		return false
+	}
+	return true
+}
+
+// GenerateIDs set the zero id fields of the doc, or the slice of docs, to the ids of the generator.
+func GenerateIDs(docs any, generator IDGenerator) {
+	v := reflect.ValueOf(docs)
+	if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Slice {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		generateID(docs, generator)
+		return
+	}
+	for i := range v.Len() {
+		generateID(v.Index(i).Interface(), generator)
+	}
+}
+
+func generateID(doc any, generator IDGenerator) {
+	if id, ok := IDOf(doc); ok && id.IsZero() {
+		id.Set(generator())
+	}
+}
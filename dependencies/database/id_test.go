@@ -0,0 +1,72 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+// Entity the embedded id of the models
+type Entity struct {
+	ID int64 `json:"id" db:"id"`
+}
+
+func TestIDOf(t *testing.T) {
+	type underscore struct {
+		ID   int64  `json:"_id"`
+		Name string `json:"name"`
+	}
+	type tagged struct {
+		ID    string `json:"id" db:"id"`
+		RowID int64  `json:"_id"`
+	}
+	type embedded struct {
+		Entity
+		Name string `json:"name"`
+	}
+	type none struct {
+		Name string `json:"name"`
+	}
+
+	// the db:"id" field is preferred to the _id field
+	v := &tagged{}
+	id, ok := database.IDOf(v)
+	if !ok || id.Key != "id" || id.IsGenerated() || !id.IsZero() {
+		t.Fatalf("unexpected id of the tagged field %+v %v", id, ok)
+	}
+	if !id.Set(int64(7)) || v.ID != "7" || v.RowID != 0 {
+		t.Fatalf("unexpected tagged model %+v", v)
+	}
+
+	id, ok = database.IDOf(&underscore{ID: 3})
+	if !ok || id.Key != database.IDKey || !id.IsGenerated() || id.Value() != int64(3) {
+		t.Fatalf("unexpected id of the _id field %+v %v", id, ok)
+	}
+	if id, ok = database.IDOf(&embedded{}); !ok || id.Key != "id" {
+		t.Fatalf("unexpected id of the embedded field %+v %v", id, ok)
+	}
+	if _, ok = database.IDOf(&none{}); ok {
+		t.Fatal("expected no id field")
+	}
+	if _, ok = database.IDOf(underscore{}); ok {
+		t.Fatal("expected no id field of a struct value")
+	}
+}
+
+func TestGenerateIDs(t *testing.T) {
+	var next int64
+	generator := func() int64 {
+		next++
+		return next
+	}
+	docs := []*Entity{{}, {ID: 9}, {}}
+	database.GenerateIDs(docs, generator)
+	if docs[0].ID != 1 || docs[1].ID != 9 || docs[2].ID != 2 {
+		t.Fatalf("unexpected ids %d %d %d", docs[0].ID, docs[1].ID, docs[2].ID)
+	}
+	doc := &Entity{}
+	database.GenerateIDs(doc, generator)
+	if doc.ID != 3 {
+		t.Fatalf("unexpected id %d", doc.ID)
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Index the index of a model declared by the db tags of its fields, the options of the tag are:
+//
+//   - index: the field is indexed
+//   - unique: the field is indexed by a unique index
+//   - desc: the field is indexed in the reverse order
+//   - name=uid_email: the name of the index, the fields of the same name make a compound index in the order
+//     of the fields, the name is the field name by default
+//   - ttl=720h: the rows are removed when the time of the field is older than the ttl, it is the TTL index of
+//     mongo and the scheduled event of mysql, a ttl index has only one field
+//
+// for exp:
+//
+//	type User struct {
+//		OrgID     string    `json:"org_id" db:"unique,name=uid_email"`
+//		Email     string    `json:"email" db:"unique,name=uid_email"`
+//		Name      string    `json:"name" db:"index"`
+//		CreatedAt time.Time `json:"created_at" db:"ttl=720h"`
+//	}
+type Index struct {
+	// Name the name of the index
+	Name string
+	// Fields the fields of the index
+	Fields       []string
+	Unique       bool
+	ReverseOrder bool
+	// TTL the expiration of the rows, 0 means the rows never expire
+	TTL time.Duration
+}
+
+// Indexer is an optional interface that Database implementations can satisfy to create the indexes of
+// the models, see [EnsureModel].
+type Indexer interface {
+	// IndexNames returns the names of the indexes of the table, the primary key and the indexes of the
+	// implementation, for exp the project index of sql, are not returned.
+	IndexNames(ctx context.Context, table string) ([]string, error)
+	// CreateIndex creates the index of the table if it does not exist
+	CreateIndex(ctx context.Context, table string, index *Index) error
+}
+
+// IndexReport the result of EnsureModel
+type IndexReport struct {
+	// Created the names of the indexes which have been created
+	Created []string
+	// Extra the names of the indexes of the table which are not declared by the model, they are kept.
+	Extra []string
+}
+
+// IndexesOf returns the indexes declared by the db tags of the fields of the model, see [Index].
+func IndexesOf(model any) ([]*Index, error) {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, status.Errorf(codes.InvalidArgument, "the model must be a struct, got %T", model)
+	}
+	var indexes []*Index
+	if err := appendIndexes(&indexes, t); err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if index.TTL > 0 && len(index.Fields) > 1 {
+			return nil, status.Errorf(codes.InvalidArgument, "the ttl index %s must have only one field",
+				index.Name)
+		}
+	}
+	return indexes, nil
+}
+
+func appendIndexes(indexes *[]*Index, t reflect.Type) error {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := appendIndexes(indexes, ft); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		ttlValue, hasTTL := TagOptionValue(sf, "ttl")
+		unique := HasTagOption(sf, "unique")
+		if !hasTTL && !unique && !HasTagOption(sf, "index") {
+			continue
+		}
+		field := fieldName(sf)
+		name, ok := TagOptionValue(sf, "name")
+		if !ok {
+			name = field
+		}
+		var ttl time.Duration
+		if hasTTL {
+			var err error
+			if ttl, err = time.ParseDuration(ttlValue); err != nil || ttl <= 0 {
+				return status.Errorf(codes.InvalidArgument, "invalid ttl %q of the field %s", ttlValue, field)
+			}
+		}
+		at := slices.IndexFunc(*indexes, func(index *Index) bool { return index.Name == name })
+		if at < 0 {
+			*indexes = append(*indexes, &Index{Name: name})
+			at = len(*indexes) - 1
+		}
+		index := (*indexes)[at]
+		index.Fields = append(index.Fields, field)
+		index.Unique = index.Unique || unique
+		index.ReverseOrder = index.ReverseOrder || HasTagOption(sf, "desc")
+		index.TTL = max(index.TTL, ttl)
+	}
+	return nil
+}
+
+// EnsureModel creates the indexes declared by the model (see [Index]) which the table does not have, the
+// indexes are matched by their names. The indexes of the table which are not declared by the model are
+// reported, but never dropped. It returns an Unimplemented error if the db does not implement Indexer.
+func EnsureModel(ctx context.Context, db Database, table string, model any) (*IndexReport, error) {
+	indexer, err := indexerOf(db)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := IndexesOf(model)
+	if err != nil {
+		return nil, err
+	}
+	names, err := indexer.IndexNames(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	report := &IndexReport{}
+	for _, index := range indexes {
+		if slices.Contains(names, index.Name) {
+			continue
+		}
+		if err = indexer.CreateIndex(ctx, table, index); err != nil {
+			return report, err
+		}
+		report.Created = append(report.Created, index.Name)
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(indexes, func(index *Index) bool { return index.Name == name }) {
+			report.Extra = append(report.Extra, name)
+		}
+	}
+	return report, nil
+}
+
+// IndexNames returns the names of the indexes of the table, it returns an Unimplemented error if the db does
+// not implement Indexer.
+func IndexNames(ctx context.Context, db Database, table string) ([]string, error) {
+	indexer, err := indexerOf(db)
+	if err != nil {
+		return nil, err
+	}
+	return indexer.IndexNames(ctx, table)
+}
+
+// CreateIndex creates the index of the table, it returns an Unimplemented error if the db does not
+// implement Indexer.
+func CreateIndex(ctx context.Context, db Database, table string, index *Index) error {
+	indexer, err := indexerOf(db)
+	if err != nil {
+		return err
+	}
+	return indexer.CreateIndex(ctx, table, index)
+}
+
+func indexerOf(db Database) (Indexer, error) {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	indexer, ok := db.(Indexer)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "indexer unimplemented for %s",
+			reflect.TypeOf(db).String())
+	}
+	return indexer, nil
+}
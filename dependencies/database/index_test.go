@@ -0,0 +1,45 @@
+package database_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type IndexedUser struct {
+	OrgID     string    `json:"org_id" db:"unique,name=uid_email"`
+	Email     string    `json:"email" db:"unique,name=uid_email"`
+	Name      string    `json:"name" db:"index,desc"`
+	CreatedAt time.Time `json:"created_at" db:"ttl=720h"`
+}
+
+func TestIndexesOf(t *testing.T) {
+	indexes, err := database.IndexesOf(&IndexedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 3 {
+		t.Fatalf("Expected 3 indexes, got %d", len(indexes))
+	}
+	if v := indexes[0]; v.Name != "uid_email" || !slices.Equal(v.Fields, []string{"org_id", "email"}) || !v.Unique {
+		t.Errorf("Unexpected compound index %+v", v)
+	}
+	if v := indexes[1]; v.Name != "name" || v.Unique || !v.ReverseOrder {
+		t.Errorf("Unexpected name index %+v", v)
+	}
+	if v := indexes[2]; v.Name != "created_at" || v.TTL != 720*time.Hour {
+		t.Errorf("Unexpected ttl index %+v", v)
+	}
+
+	type invalidTTL struct {
+		A time.Time `json:"a" db:"ttl=1h,name=a_b"`
+		B time.Time `json:"b" db:"index,name=a_b"`
+	}
+	if _, err = database.IndexesOf(&invalidTTL{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected the compound ttl index is invalid, got %v", err)
+	}
+}
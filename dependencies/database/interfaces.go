@@ -1,14 +1,17 @@
 package database
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
 
 // PageQuerier is an optional interface that Database implementations can satisfy
 // to support page queries without central dispatch modification.
 //
 // The result parameter will always be a *PageQueryResponse[T] where T is the
-// element type chosen by the caller. Implementers must perform a type assertion
-// (e.g. result.(*PageQueryResponse[MyModel])) to populate it. A type mismatch
-// between the caller's T and the implementer's assertion will cause a runtime panic.
+// element type chosen by the caller. Implementers should use [NewQueryResult] to
+// populate it, which reports an error instead of panicking on an unexpected result type.
 type PageQuerier interface {
 	DoPageQuery(ctx context.Context, table string, in *PageQueryRequest, result any) error
 }
@@ -17,9 +20,80 @@ type PageQuerier interface {
 // to support stream queries without central dispatch modification.
 //
 // The result parameter will always be a *StreamResponse[T] where T is the
-// element type chosen by the caller. Implementers must perform a type assertion
-// (e.g. result.(*StreamResponse[MyModel])) to populate it. A type mismatch
-// between the caller's T and the implementer's assertion will cause a runtime panic.
+// element type chosen by the caller. Implementers should use [NewQueryResult] to
+// populate it, which reports an error instead of panicking on an unexpected result type.
 type StreamQuerier interface {
 	DoStreamQuery(ctx context.Context, table string, in *StreamQueryRequest, result any) error
 }
+
+// QueryResult the reflected *PageQueryResponse[T] or *StreamResponse[T], it fills the result
+// of any element type T.
+type QueryResult struct {
+	// ElemType the element type T
+	ElemType  reflect.Type
+	data      reflect.Value
+	total     reflect.Value
+	pageToken reflect.Value
+}
+
+// NewQueryResult reflect the result of PageQuerier or StreamQuerier.
+func NewQueryResult(result any) (*QueryResult, error) {
+	v := reflect.ValueOf(result)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("the result must be a *PageQueryResponse[T] or *StreamResponse[T], got %T", result)
+	}
+	v = v.Elem()
+	r := &QueryResult{
+		data:      v.FieldByName("Data"),
+		total:     v.FieldByName("Total"),
+		pageToken: v.FieldByName("PageToken"),
+	}
+	if !r.data.IsValid() || r.data.Kind() != reflect.Slice || r.data.Type().Elem().Kind() != reflect.Pointer ||
+		!r.total.IsValid() || r.total.Kind() != reflect.Int64 {
+		return nil, fmt.Errorf("the result must be a *PageQueryResponse[T] or *StreamResponse[T], got %T", result)
+	}
+	r.ElemType = r.data.Type().Elem().Elem()
+	return r, nil
+}
+
+// New returns a new *T
+func (r *QueryResult) New() any {
+	return reflect.New(r.ElemType).Interface()
+}
+
+// Append appends the *T to the data
+func (r *QueryResult) Append(data any) {
+	r.data.Set(reflect.Append(r.data, reflect.ValueOf(data)))
+}
+
+// Data returns the []*T data
+func (r *QueryResult) Data() reflect.Value {
+	return r.data
+}
+
+// SetData sets the []*T data
+func (r *QueryResult) SetData(data reflect.Value) {
+	r.data.Set(data)
+}
+
+// Len the length of the data
+func (r *QueryResult) Len() int {
+	return r.data.Len()
+}
+
+// Total returns the total
+func (r *QueryResult) Total() int64 {
+	return r.total.Int()
+}
+
+// SetTotal sets the total
+func (r *QueryResult) SetTotal(total int64) {
+	r.total.SetInt(total)
+}
+
+// SetPageToken sets the page token of a StreamResponse, it is ignored by a PageQueryResponse.
+func (r *QueryResult) SetPageToken(pageToken string) {
+	if r.pageToken.IsValid() && r.pageToken.Kind() == reflect.String {
+		r.pageToken.SetString(pageToken)
+	}
+}
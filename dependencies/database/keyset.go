@@ -0,0 +1,253 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SortKeys returns the keys and the directions of the sort, ["-created_at", "score"] means created_at DESC,
+// score ASC.
+func SortKeys(sort []string) (keys []string, desc []bool) {
+	keys = make([]string, len(sort))
+	desc = make([]bool, len(sort))
+	for i, v := range sort {
+		keys[i], desc[i] = strings.CutPrefix(v, "-")
+	}
+	return keys, desc
+}
+
+// WithTieBreaker append the unique key to the sort in the direction of the last sort key,
+// so the rows of the same sort values are ordered too. The sort is returned as is if it has the key.
+func WithTieBreaker(sort []string, key string) []string {
+	if keys, _ := SortKeys(sort); slices.Contains(keys, key) {
+		return sort
+	}
+	if len(sort) > 0 && strings.HasPrefix(sort[len(sort)-1], "-") {
+		key = "-" + key
+	}
+	return append(slices.Clip(sort), key)
+}
+
+// SelectSortKeys add the sort keys to the select fields if the fields are selected by names,
+// and remove the sort keys from the excluded fields, so the sort values of the rows are read.
+func SelectSortKeys(selects, sort []string) []string {
+	if len(selects) == 0 {
+		return selects
+	}
+	keys, _ := SortKeys(sort)
+	out := make([]string, 0, len(selects)+len(keys))
+	var included bool
+	for _, v := range selects {
+		if name, excluded := strings.CutPrefix(v, "-"); excluded {
+			if slices.Contains(keys, name) {
+				continue
+			}
+		} else {
+			included = true
+		}
+		out = append(out, v)
+	}
+	if included {
+		for _, key := range keys {
+			if !slices.Contains(out, key) {
+				out = append(out, key)
+			}
+		}
+	}
+	return out
+}
+
+// KeysetCondition the condition of the rows after the sort values of the last row, for exp, the sort
+// ["-a", "b"] returns a < ? OR (a = ? AND b > ?). The nil values are compared by Exists, the nulls are
+// sorted before the other values in the ascending order, or after them if nullsLast, as postgres does.
+func KeysetCondition(sort []string, values []any, nullsLast bool) CE {
+	keys, desc := SortKeys(sort)
+	or := make(C, 0, len(keys))
+	for i, key := range keys {
+		and := make(C, 0, i+1)
+		for j := range i {
+			if values[j] == nil {
+				and = append(and, CE{Key: keys[j], Value: false, C: Exists})
+			} else {
+				and = append(and, CE{Key: keys[j], Value: values[j]})
+			}
+		}
+		// the nulls are after the values in the direction of the key
+		nullsAfter := desc[i] != nullsLast
+		var after CE
+		switch {
+		case values[i] == nil && !nullsAfter:
+			after = CE{Key: key, Value: true, C: Exists}
+		case values[i] == nil:
+			// nothing is after the nulls
+			continue
+		default:
+			after = CE{Key: key, Value: values[i], C: Gt}
+			if desc[i] {
+				after.C = Lt
+			}
+			if nullsAfter {
+				after = CE{C: Or, Value: C{after, {Key: key, Value: false, C: Exists}}}
+			}
+		}
+		or = append(or, CE{C: And, Value: append(and, after)})
+	}
+	return CE{C: Or, Value: or}
+}
+
+// SortValues returns the values of the sort keys of the struct pointer, the field of a key is found by
+// its json or bson tag, or the snake case or the case-insensitive name of the field.
+func SortValues(data any, sort []string) ([]any, error) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("the data must be a struct pointer, got %T", data)
+	}
+	keys, _ := SortKeys(sort)
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		field, ok := fieldByName(v, key)
+		if !ok {
+			field = v.FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, key)
+			})
+			if !field.IsValid() {
+				return nil, fmt.Errorf("the sort key %s is not found in %T", key, data)
+			}
+		}
+		values[i] = field.Interface()
+	}
+	return values, nil
+}
+
+// sortToken the page token of the stream query sorted by keys, it holds the sort and the sort values of
+// the last row, the values keep their types.
+type sortToken struct {
+	Sort   []string     `json:"s"`
+	Values []tokenValue `json:"v"`
+}
+
+type tokenValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+// the types of the token values
+const (
+	tokenNull   = "n"
+	tokenInt    = "i"
+	tokenUint   = "u"
+	tokenFloat  = "f"
+	tokenString = "s"
+	tokenBool   = "b"
+	tokenTime   = "t"
+	tokenBytes  = "x"
+)
+
+// EncodeSortToken encode the sort and the sort values of the last row to an opaque page token,
+// the values can be nil, integers, floats, strings, bools, []byte, time.Time or *timestamppb.Timestamp.
+func EncodeSortToken(sort []string, values []any) (string, error) {
+	token := sortToken{
+		Sort:   sort,
+		Values: make([]tokenValue, len(values)),
+	}
+	for i, value := range values {
+		tv, err := encodeTokenValue(value)
+		if err != nil {
+			return "", fmt.Errorf("encode the sort key %s: %w", sort[i], err)
+		}
+		token.Values[i] = tv
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeSortToken decode the sort values of the page token, the token must be encoded with the same sort.
+func DecodeSortToken(src string, sort []string) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(src)
+	if err != nil {
+		return nil, errors.New("invalid page token")
+	}
+	var token sortToken
+	if err = json.Unmarshal(b, &token); err != nil {
+		return nil, errors.New("invalid page token")
+	}
+	if !slices.Equal(token.Sort, sort) || len(token.Values) != len(sort) {
+		return nil, errors.New("the page token does not match the sort")
+	}
+	values := make([]any, len(token.Values))
+	for i, tv := range token.Values {
+		if values[i], err = decodeTokenValue(tv); err != nil {
+			return nil, errors.New("invalid page token")
+		}
+	}
+	return values, nil
+}
+
+func encodeTokenValue(value any) (tokenValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return tokenValue{Type: tokenNull}, nil
+	case time.Time:
+		return tokenValue{Type: tokenTime, Value: v.Format(time.RFC3339Nano)}, nil
+	case *timestamppb.Timestamp:
+		if v == nil {
+			return tokenValue{Type: tokenNull}, nil
+		}
+		return tokenValue{Type: tokenTime, Value: v.AsTime().Format(time.RFC3339Nano)}, nil
+	case []byte:
+		return tokenValue{Type: tokenBytes, Value: base64.RawURLEncoding.EncodeToString(v)}, nil
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanInt():
+		return tokenValue{Type: tokenInt, Value: strconv.FormatInt(rv.Int(), 10)}, nil
+	case rv.CanUint():
+		return tokenValue{Type: tokenUint, Value: strconv.FormatUint(rv.Uint(), 10)}, nil
+	case rv.CanFloat():
+		return tokenValue{Type: tokenFloat, Value: strconv.FormatFloat(rv.Float(), 'g', -1, 64)}, nil
+	case rv.Kind() == reflect.String:
+		return tokenValue{Type: tokenString, Value: rv.String()}, nil
+	case rv.Kind() == reflect.Bool:
+		return tokenValue{Type: tokenBool, Value: strconv.FormatBool(rv.Bool())}, nil
+	case rv.Kind() == reflect.Pointer:
+		if rv.IsNil() {
+			return tokenValue{Type: tokenNull}, nil
+		}
+		return encodeTokenValue(rv.Elem().Interface())
+	}
+	return tokenValue{}, fmt.Errorf("unsupported type %T", value)
+}
+
+func decodeTokenValue(tv tokenValue) (any, error) {
+	switch tv.Type {
+	case tokenNull:
+		return nil, nil
+	case tokenInt:
+		return strconv.ParseInt(tv.Value, 10, 64)
+	case tokenUint:
+		return strconv.ParseUint(tv.Value, 10, 64)
+	case tokenFloat:
+		return strconv.ParseFloat(tv.Value, 64)
+	case tokenString:
+		return tv.Value, nil
+	case tokenBool:
+		return strconv.ParseBool(tv.Value)
+	case tokenTime:
+		return time.Parse(time.RFC3339Nano, tv.Value)
+	case tokenBytes:
+		return base64.RawURLEncoding.DecodeString(tv.Value)
+	}
+	return nil, fmt.Errorf("unknown type %s", tv.Type)
+}
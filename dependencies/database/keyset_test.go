@@ -0,0 +1,108 @@
+package database_test
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+func TestWithTieBreaker(t *testing.T) {
+	for _, tc := range []struct {
+		sort, want []string
+	}{
+		{nil, []string{"_id"}},
+		{[]string{"age"}, []string{"age", "_id"}},
+		{[]string{"age", "-name"}, []string{"age", "-name", "-_id"}},
+		{[]string{"-_id"}, []string{"-_id"}},
+		{[]string{"_id", "age"}, []string{"_id", "age"}},
+	} {
+		if got := database.WithTieBreaker(tc.sort, "_id"); !slices.Equal(got, tc.want) {
+			t.Errorf("WithTieBreaker(%v) = %v, want %v", tc.sort, got, tc.want)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	gt := func(key string, value any) database.CE { return database.CE{Key: key, Value: value, C: database.Gt} }
+	lt := func(key string, value any) database.CE { return database.CE{Key: key, Value: value, C: database.Lt} }
+	exists := func(key string, exist bool) database.CE {
+		return database.CE{Key: key, Value: exist, C: database.Exists}
+	}
+	and := func(conds ...database.CE) database.CE { return database.CE{C: database.And, Value: database.C(conds)} }
+	or := func(conds ...database.CE) database.CE { return database.CE{C: database.Or, Value: database.C(conds)} }
+
+	for _, tc := range []struct {
+		name      string
+		sort      []string
+		values    []any
+		nullsLast bool
+		want      database.CE
+	}{
+		{
+			name:   "values",
+			sort:   []string{"a", "b"},
+			values: []any{1, 2},
+			want:   or(and(gt("a", 1)), and(database.CE{Key: "a", Value: 1}, gt("b", 2))),
+		},
+		{
+			name:   "null ascending",
+			sort:   []string{"a", "_id"},
+			values: []any{nil, 2},
+			want:   or(and(exists("a", true)), and(exists("a", false), gt("_id", 2))),
+		},
+		{
+			name:   "null descending",
+			sort:   []string{"-a", "-_id"},
+			values: []any{nil, 2},
+			want:   or(and(exists("a", false), or(lt("_id", 2), exists("_id", false)))),
+		},
+		{
+			// the nulls are after the values in the descending order
+			name:   "value descending",
+			sort:   []string{"-a"},
+			values: []any{1},
+			want:   or(and(or(lt("a", 1), exists("a", false)))),
+		},
+		{
+			name:      "nulls last",
+			sort:      []string{"a", "-b"},
+			values:    []any{1, nil},
+			nullsLast: true,
+			want:      or(and(or(gt("a", 1), exists("a", false))), and(database.CE{Key: "a", Value: 1}, exists("b", true))),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := database.KeysetCondition(tc.sort, tc.values, tc.nullsLast); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSortToken(t *testing.T) {
+	sort := []string{"-created_at", "name", "score", "_id"}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 500, time.FixedZone("CST", 8*3600))
+	token, err := database.EncodeSortToken(sort, []any{created, "alice", nil, int64(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := database.DecodeSortToken(token, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm, ok := values[0].(time.Time); !ok || !tm.Equal(created) {
+		t.Fatalf("unexpected time %v", values[0])
+	}
+	if values[1] != "alice" || values[2] != nil || values[3] != int64(7) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if _, err = database.DecodeSortToken(token, sort[1:]); err == nil {
+		t.Fatal("expected the error of the token of another sort")
+	}
+	if _, err = database.DecodeSortToken("invalid", sort); err == nil {
+		t.Fatal("expected the error of the invalid token")
+	}
+}
@@ -0,0 +1,144 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+// aggregateGroup the accumulated values of a group
+type aggregateGroup struct {
+	keys   []any
+	counts []int64
+	sums   []float64
+	values []any
+}
+
+// Aggregate implements database.Aggregator, the groups are computed in memory.
+func (m *Mock) Aggregate(ctx context.Context, tableName string, in *database.AggregateRequest,
+) (*database.AggregateResponse, error) {
+	if err := in.Validate(); err != nil {
+		return nil, NewInvalidArgumentError("aggregate", err.Error())
+	}
+	if _, err := m.inject(ctx, tableName, FaultAggregate); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	table := m.getOrCreateTable(tableName)
+	groups := make(map[string]*aggregateGroup)
+	var order []string
+	for _, row := range table.data {
+		if len(in.Filters) > 0 && !matchConditions(row, in.Filters) {
+			continue
+		}
+		keys := make([]any, len(in.GroupBy))
+		var id strings.Builder
+		for i, key := range in.GroupBy {
+			keys[i], _ = lookupValue(row, key)
+			fmt.Fprintf(&id, "%T:%v|", keys[i], keys[i])
+		}
+		group, ok := groups[id.String()]
+		if !ok {
+			group = &aggregateGroup{
+				keys:   keys,
+				counts: make([]int64, len(in.Aggregations)),
+				sums:   make([]float64, len(in.Aggregations)),
+				values: make([]any, len(in.Aggregations)),
+			}
+			groups[id.String()] = group
+			order = append(order, id.String())
+		}
+		group.accumulate(row, in.Aggregations)
+	}
+	if len(in.GroupBy) == 0 && len(groups) == 0 {
+		// aggregating without group by always returns one row like sql
+		groups[""] = &aggregateGroup{
+			counts: make([]int64, len(in.Aggregations)),
+			sums:   make([]float64, len(in.Aggregations)),
+			values: make([]any, len(in.Aggregations)),
+		}
+		order = append(order, "")
+	}
+
+	// the rows use the normalized keys, so the Having and Sort work like the other queries of mock
+	names := make(map[string]string)
+	var rows []map[string]any
+	for _, id := range order {
+		group := groups[id]
+		row := make(map[string]any, len(in.GroupBy)+len(in.Aggregations))
+		for i, key := range in.GroupBy {
+			names[normalizeKey(key)] = key
+			row[normalizeKey(key)] = group.keys[i]
+		}
+		for i, a := range in.Aggregations {
+			names[normalizeKey(a.Name())] = a.Name()
+			row[normalizeKey(a.Name())] = group.result(i, a.Func)
+		}
+		if len(in.Having) == 0 || matchConditions(row, in.Having) {
+			rows = append(rows, row)
+		}
+	}
+	sortRows(rows, in.OrderBy())
+	if in.Limit > 0 && len(rows) > in.Limit {
+		rows = rows[:in.Limit]
+	}
+
+	out := &database.AggregateResponse{}
+	for _, row := range rows {
+		data := make(map[string]any, len(row))
+		for k, v := range row {
+			data[names[k]] = v
+		}
+		out.Data = append(out.Data, data)
+	}
+	return out, nil
+}
+
+// accumulate the values of the row, the null values are ignored like sql
+func (g *aggregateGroup) accumulate(row map[string]any, aggregations []database.Aggregation) {
+	for i, a := range aggregations {
+		if a.Key == "" {
+			g.counts[i]++
+			continue
+		}
+		value, ok := lookupValue(row, a.Key)
+		if !ok || isNil(value) {
+			continue
+		}
+		g.counts[i]++
+		switch a.Func {
+		case database.Sum, database.Avg:
+			if v, ok := toFloat64(value); ok {
+				g.sums[i] += v
+			}
+		case database.Min:
+			if g.values[i] == nil || compareValues(value, g.values[i]) < 0 {
+				g.values[i] = value
+			}
+		case database.Max:
+			if g.values[i] == nil || compareValues(value, g.values[i]) > 0 {
+				g.values[i] = value
+			}
+		}
+	}
+}
+
+func (g *aggregateGroup) result(i int, fn database.AggregateFunc) any {
+	switch fn {
+	case database.Count:
+		return g.counts[i]
+	case database.Sum:
+		return g.sums[i]
+	case database.Avg:
+		if g.counts[i] == 0 {
+			return nil
+		}
+		return g.sums[i] / float64(g.counts[i])
+	default:
+		return g.values[i]
+	}
+}
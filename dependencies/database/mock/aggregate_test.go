@@ -0,0 +1,125 @@
+package mock_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+type AggregateOrder struct {
+	ID     int64   `json:"id"`
+	UserID string  `json:"user_id"`
+	Status string  `json:"status"`
+	Amount float64 `json:"amount"`
+}
+
+func TestAggregate(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/aggregatetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+
+	orders := []*AggregateOrder{
+		{ID: 1, UserID: "alice", Status: "paid", Amount: 10},
+		{ID: 2, UserID: "alice", Status: "paid", Amount: 30},
+		{ID: 3, UserID: "bob", Status: "paid", Amount: 5},
+		{ID: 4, UserID: "bob", Status: "refunded", Amount: 100},
+		{ID: 5, UserID: "carol", Status: "paid", Amount: 50},
+	}
+	if _, err = db.Insert(ctx, "orders", orders); err != nil {
+		t.Fatal("Insert failed:", err)
+	}
+
+	tests := []struct {
+		name     string
+		request  *database.AggregateRequest
+		expected []map[string]any
+	}{
+		{
+			name: "Group by with sort",
+			request: &database.AggregateRequest{
+				Filters: database.C{{Key: "status", Value: "paid"}},
+				GroupBy: []string{"user_id"},
+				Aggregations: []database.Aggregation{
+					{Func: database.Count},
+					{Func: database.Sum, Key: "amount", As: "total"},
+					{Func: database.Max, Key: "amount"},
+				},
+				Sort: []string{"-total"},
+			},
+			expected: []map[string]any{
+				{"user_id": "carol", "count": int64(1), "total": float64(50), "max_amount": float64(50)},
+				{"user_id": "alice", "count": int64(2), "total": float64(40), "max_amount": float64(30)},
+				{"user_id": "bob", "count": int64(1), "total": float64(5), "max_amount": float64(5)},
+			},
+		},
+		{
+			name: "Having and limit",
+			request: &database.AggregateRequest{
+				GroupBy:      []string{"user_id"},
+				Aggregations: []database.Aggregation{{Func: database.Avg, Key: "amount"}},
+				Having:       database.C{{Key: "avg_amount", Value: 20, C: database.Gt}},
+				Limit:        1,
+			},
+			expected: []map[string]any{
+				{"user_id": "bob", "avg_amount": float64(52.5)},
+			},
+		},
+		{
+			name: "Without group by",
+			request: &database.AggregateRequest{
+				Aggregations: []database.Aggregation{
+					{Func: database.Count},
+					{Func: database.Min, Key: "amount"},
+				},
+			},
+			expected: []map[string]any{
+				{"count": int64(5), "min_amount": float64(5)},
+			},
+		},
+		{
+			name: "Without group by matches nothing",
+			request: &database.AggregateRequest{
+				Filters:      database.C{{Key: "status", Value: "unknown"}},
+				Aggregations: []database.Aggregation{{Func: database.Count}, {Func: database.Sum, Key: "amount"}},
+			},
+			expected: []map[string]any{
+				{"count": int64(0), "sum_amount": float64(0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := database.Aggregate(ctx, db, "orders", tt.request)
+			if err != nil {
+				t.Fatal("Aggregate failed:", err)
+			}
+			if !reflect.DeepEqual(result.Data, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Data)
+			}
+		})
+	}
+
+	t.Run("Invalid request", func(t *testing.T) {
+		_, err := database.Aggregate(ctx, db, "orders", &database.AggregateRequest{
+			GroupBy: []string{"user_id`; DROP TABLE orders"},
+		})
+		if err == nil {
+			t.Error("Expected error for the invalid group by key")
+		}
+		_, err = database.Aggregate(ctx, db, "orders", &database.AggregateRequest{
+			GroupBy: []string{"user_id"},
+			Sort:    []string{"amount"},
+		})
+		if err == nil {
+			t.Error("Expected error for the sort key which is not in the result")
+		}
+	})
+}
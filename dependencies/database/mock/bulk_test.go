@@ -0,0 +1,85 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+type BulkUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestBulkWrite(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/bulktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	byID := func(id int64) database.C {
+		return database.C{{Key: "id", Value: id}}
+	}
+	models := []database.WriteModel{
+		database.InsertModel(&BulkUser{ID: 1, Name: "Alice"}),
+		database.UpsertModel(byID(2), &BulkUser{ID: 2, Name: "Bob"}),
+		{Op: "merge"},
+		database.UpdateModel(byID(1), database.D{{Key: "name", Value: "Carol"}}),
+		database.UpdateModel(byID(3), database.D{{Key: "name", Value: "Dave"}}),
+		database.DeleteModel(byID(2)),
+	}
+
+	t.Run("Ordered stops at the failed model", func(t *testing.T) {
+		result, err := database.BulkWrite(ctx, db, "ordered", models)
+		var bulkErr *database.BulkError
+		if !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 1 || bulkErr.Elements[0].Index != 2 {
+			t.Fatalf("Expected the model 2 fails, got %v", err)
+		}
+		if result.Inserted != 1 || result.Updated != 1 || !result.Results[3].Skipped || result.Results[1].Skipped {
+			t.Errorf("Unexpected result %+v", result)
+		}
+		if count, _ := db.Count(ctx, "ordered", nil); count != 2 {
+			t.Errorf("Expected 2 docs, got %d", count)
+		}
+	})
+
+	t.Run("Unordered writes all the models", func(t *testing.T) {
+		result, err := database.BulkWrite(ctx, db, "unordered", models, database.WithOrdered(false))
+		if err == nil || result.Results[2].Err == nil {
+			t.Fatalf("Expected the model 2 fails, got %v", err)
+		}
+		// the update of the missing doc 3 writes nothing but does not fail
+		if result.Inserted != 1 || result.Updated != 2 || result.Deleted != 1 || result.Results[4].Err != nil {
+			t.Errorf("Unexpected result %+v", result)
+		}
+		var user BulkUser
+		if err = db.FindOne(ctx, "unordered", byID(1), &user); err != nil || user.Name != "Carol" {
+			t.Errorf("Expected Carol, got %+v %v", user, err)
+		}
+	})
+
+	t.Run("Soft delete", func(t *testing.T) {
+		scoped, err := database.New(ctx, "mock://local/bulkscoped?softDelete=deleted_at")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = database.BulkWrite(ctx, scoped, "users", models[:2]); err != nil {
+			t.Fatal("BulkWrite failed:", err)
+		}
+		if _, err = database.BulkWrite(ctx, scoped, "users", models[5:]); err != nil {
+			t.Fatal("BulkWrite failed:", err)
+		}
+		if exist, _ := scoped.Exist(ctx, "users", byID(2)); exist {
+			t.Error("Expected the doc 2 is soft deleted")
+		}
+		if exist, _ := database.Unscoped(scoped).Exist(ctx, "users", byID(2)); !exist {
+			t.Error("Expected the soft deleted doc 2 exists")
+		}
+	})
+}
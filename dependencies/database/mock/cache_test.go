@@ -0,0 +1,259 @@
+package mock_test
+
+import (
+	"context"
+	"encoding/json/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+	"github.com/ti/common-go/dependencies/mqlru"
+)
+
+type CachedUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/cachetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	lru, err := mqlru.New(ctx, "cache://memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := database.NewCached(db, lru, database.WithTableCacheTTL("users", time.Minute))
+	if err = cached.InsertOne(ctx, "users", &CachedUser{ID: 1, Name: "Alice"}); err != nil {
+		t.Fatal("InsertOne failed:", err)
+	}
+	conds := database.C{{Key: "id", Value: int64(1)}}
+	findName := func(db database.Database) string {
+		var user CachedUser
+		if err := db.FindOne(ctx, "users", conds, &user); err != nil {
+			t.Fatal("FindOne failed:", err)
+		}
+		return user.Name
+	}
+	if name := findName(cached); name != "Alice" {
+		t.Fatalf("Expected Alice, got %s", name)
+	}
+
+	t.Run("FindOne reads the cache", func(t *testing.T) {
+		// the write to the db bypasses the decorator, so the cache is not invalidated
+		if _, err := db.UpdateOne(ctx, "users", conds, database.D{{Key: "name", Value: "Bob"}}); err != nil {
+			t.Fatal("UpdateOne failed:", err)
+		}
+		if name := findName(cached); name != "Alice" {
+			t.Errorf("Expected the cached Alice, got %s", name)
+		}
+	})
+
+	t.Run("Update invalidates the table", func(t *testing.T) {
+		if _, err := cached.UpdateOne(ctx, "users", conds, database.D{{Key: "name", Value: "Carol"}}); err != nil {
+			t.Fatal("UpdateOne failed:", err)
+		}
+		if name := findName(cached); name != "Carol" {
+			t.Errorf("Expected Carol, got %s", name)
+		}
+	})
+
+	t.Run("Count and Exist", func(t *testing.T) {
+		if count, err := cached.Count(ctx, "users", nil); err != nil || count != 1 {
+			t.Fatalf("Expected count 1, got %d %v", count, err)
+		}
+		if _, err := db.Insert(ctx, "users", []*CachedUser{{ID: 2, Name: "Dave"}}); err != nil {
+			t.Fatal("Insert failed:", err)
+		}
+		if count, _ := cached.Count(ctx, "users", nil); count != 1 {
+			t.Errorf("Expected the cached count 1, got %d", count)
+		}
+		if _, err := cached.DeleteOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}}); err != nil {
+			t.Fatal("DeleteOne failed:", err)
+		}
+		exist, err := cached.Exist(ctx, "users", database.C{{Key: "id", Value: int64(2)}})
+		if err != nil || exist {
+			t.Errorf("Expected the deleted user not exist, got %v %v", exist, err)
+		}
+	})
+
+	t.Run("Commit invalidates the table", func(t *testing.T) {
+		tx, err := cached.StartTransaction(ctx)
+		if err != nil {
+			t.Fatal("StartTransaction failed:", err)
+		}
+		txDB := cached.WithTransaction(ctx, tx)
+		if _, err = txDB.UpdateOne(ctx, "users", conds, database.D{{Key: "name", Value: "Erin"}}); err != nil {
+			t.Fatal("UpdateOne failed:", err)
+		}
+		if name := findName(cached); name != "Carol" {
+			t.Errorf("Expected Carol before the commit, got %s", name)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		if name := findName(cached); name != "Erin" {
+			t.Errorf("Expected Erin after the commit, got %s", name)
+		}
+	})
+
+	t.Run("Commit of another transaction invalidates the table", func(t *testing.T) {
+		// the transaction is started by the db rather than the decorator
+		tx, err := db.StartTransaction(ctx)
+		if err != nil {
+			t.Fatal("StartTransaction failed:", err)
+		}
+		txDB := cached.WithTransaction(ctx, tx)
+		if _, err = txDB.UpdateOne(ctx, "users", conds, database.D{{Key: "name", Value: "Frank"}}); err != nil {
+			t.Fatal("UpdateOne failed:", err)
+		}
+		// the read before the commit does not cache the data before the commit for the next generation
+		if name := findName(cached); name != "Erin" {
+			t.Errorf("Expected Erin before the commit, got %s", name)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		if name := findName(cached); name != "Frank" {
+			t.Errorf("Expected Frank after the commit, got %s", name)
+		}
+	})
+
+	t.Run("Tables without ttl are not cached", func(t *testing.T) {
+		if err := cached.InsertOne(ctx, "groups", &CachedUser{ID: 1, Name: "admin"}); err != nil {
+			t.Fatal("InsertOne failed:", err)
+		}
+		if count, _ := cached.Count(ctx, "groups", nil); count != 1 {
+			t.Fatalf("Expected count 1, got %d", count)
+		}
+		if _, err := db.Insert(ctx, "groups", []*CachedUser{{ID: 2, Name: "dev"}}); err != nil {
+			t.Fatal("Insert failed:", err)
+		}
+		if count, _ := cached.Count(ctx, "groups", nil); count != 2 {
+			t.Errorf("Expected count 2, got %d", count)
+		}
+	})
+}
+
+// sentCache records the keys sent to the other replicas
+type sentCache struct {
+	*mqlru.Lru
+	sent []string
+}
+
+func (c *sentCache) Set(ctx context.Context, key string, data any, ttl time.Duration) error {
+	c.sent = append(c.sent, key)
+	return c.Lru.Set(ctx, key, data, ttl)
+}
+
+func TestCachedLocal(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/cachelocal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	lru, err := mqlru.New(ctx, "cache://memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &sentCache{Lru: lru}
+	cached := database.NewCached(db, cache, database.WithCacheTTL(time.Minute))
+	if err = cached.InsertOne(ctx, "users", &CachedUser{ID: 1, Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	cache.sent = nil
+	for range 2 {
+		if count, err := cached.Count(ctx, "users", nil); err != nil || count != 1 {
+			t.Fatalf("Expected count 1, got %d %v", count, err)
+		}
+	}
+	// the results are cached locally
+	if len(cache.sent) != 0 {
+		t.Fatalf("Expected the results cached locally, got %v", cache.sent)
+	}
+	// only the generation of the table is sent
+	if err = cached.InsertOne(ctx, "users", &CachedUser{ID: 2, Name: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.sent) != 1 {
+		t.Fatalf("Expected the generation sent, got %v", cache.sent)
+	}
+}
+
+// storedCache records the json of the values stored in the cache
+type storedCache struct {
+	*mqlru.Lru
+	stored []string
+}
+
+func (c *storedCache) Set(ctx context.Context, key string, data any, ttl time.Duration) error {
+	c.record(data)
+	return c.Lru.Set(ctx, key, data, ttl)
+}
+
+func (c *storedCache) SetLocal(ctx context.Context, key string, data any, ttl time.Duration) error {
+	c.record(data)
+	return c.Lru.SetLocal(ctx, key, data, ttl)
+}
+
+func (c *storedCache) record(data any) {
+	b, _ := json.Marshal(data)
+	c.stored = append(c.stored, string(b))
+}
+
+type EncryptedCachedUser struct {
+	ID    int64  `json:"id"`
+	Phone string `json:"phone" db:"encrypt=deterministic"`
+}
+
+func TestCachedEncrypted(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/cacheencrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	lru, err := mqlru.New(ctx, "cache://memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &storedCache{Lru: lru}
+	cached := database.NewCached(db, cache, database.WithCacheTTL(time.Minute))
+	if err = cached.InsertOne(ctx, "users", &EncryptedCachedUser{ID: 1, Phone: "+1234567890"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = cached.InsertOne(ctx, "users", &CachedUser{ID: 2, Name: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		var user EncryptedCachedUser
+		err = cached.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}}, &user)
+		if err != nil || user.Phone != "+1234567890" {
+			t.Fatalf("Unexpected user %+v %v", user, err)
+		}
+	}
+	var user CachedUser
+	if err = cached.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}}, &user); err != nil {
+		t.Fatal(err)
+	}
+	stored := strings.Join(cache.stored, "\n")
+	if strings.Contains(stored, "+1234567890") {
+		t.Fatalf("Expected the plaintext is not cached, got %s", stored)
+	}
+	// the models without encrypted fields are cached
+	if !strings.Contains(stored, "Bob") {
+		t.Fatalf("Expected the user 2 is cached, got %s", stored)
+	}
+}
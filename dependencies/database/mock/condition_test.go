@@ -0,0 +1,83 @@
+package mock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+type ConditionProfile struct {
+	City string `json:"city"`
+}
+
+type ConditionUser struct {
+	ID      int64             `json:"id"`
+	Name    string            `json:"name"`
+	Age     int               `json:"age"`
+	Tags    []string          `json:"tags"`
+	Profile *ConditionProfile `json:"profile"`
+}
+
+func TestConditionOperators(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/condtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+
+	users := []*ConditionUser{
+		{ID: 1, Name: "Alice", Age: 25, Tags: []string{"admin", "dev"}, Profile: &ConditionProfile{City: "beijing"}},
+		{ID: 2, Name: "Bob", Age: 30, Tags: []string{"dev"}, Profile: &ConditionProfile{City: "shanghai"}},
+		{ID: 3, Name: "Albert", Age: 35},
+	}
+	if _, err = db.Insert(ctx, "users", users); err != nil {
+		t.Fatal("Insert failed:", err)
+	}
+
+	tests := []struct {
+		name  string
+		conds database.C
+		want  int64
+	}{
+		{"Like prefix", database.C{{Key: "name", Value: "Al%", C: database.Like}}, 2},
+		{"Like single char", database.C{{Key: "name", Value: "B_b", C: database.Like}}, 1},
+		{"Regex", database.C{{Key: "name", Value: "^(Bob|Albert)$", C: database.Regex}}, 2},
+		{"Exists", database.C{{Key: "profile", Value: true, C: database.Exists}}, 2},
+		{"Not exists", database.C{{Key: "profile", Value: false, C: database.Exists}}, 1},
+		{"Between", database.C{{Key: "age", Value: []int{25, 30}, C: database.Between}}, 2},
+		{"ArrayContains", database.C{{Key: "tags", Value: "admin", C: database.ArrayContains}}, 1},
+		{"Nested key", database.C{{Key: "profile.city", Value: "shanghai"}}, 1},
+		{"JSONMatch", database.C{{Key: "profile", Value: map[string]any{"city": "beijing"}, C: database.JSONMatch}}, 1},
+		{"Or", database.C{{C: database.Or, Value: database.C{
+			{Key: "name", Value: "Bob"},
+			{Key: "age", Value: 35},
+		}}}, 2},
+		{"Or with nested And", database.C{{C: database.Or, Value: database.C{
+			{Key: "name", Value: "Bob"},
+			{C: database.And, Value: database.C{
+				{Key: "name", Value: "Al%", C: database.Like},
+				{Key: "age", Value: 30, C: database.Gt},
+			}},
+		}}}, 2},
+		{"Not", database.C{{C: database.Not, Value: database.C{
+			{Key: "tags", Value: "dev", C: database.ArrayContains},
+		}}}, 1},
+		{"Empty Or", database.C{{C: database.Or, Value: database.C{}}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := db.Count(ctx, "users", tt.conds)
+			if err != nil {
+				t.Fatal("Count failed:", err)
+			}
+			if count != tt.want {
+				t.Errorf("Expected %d users, got %d", tt.want, count)
+			}
+		})
+	}
+}
@@ -7,8 +7,14 @@ import (
 
 // IncrCounter increments a counter
 func (m *Mock) IncrCounter(ctx context.Context, counterTable, key string, start, count int64) error {
+	if _, err := m.inject(ctx, counterTable, FaultCounter); err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err := m.checkTransaction(); err != nil {
+		return err
+	}
 
 	counterKey := fmt.Sprintf("%s:%s", counterTable, key)
 
@@ -17,13 +23,20 @@ func (m *Mock) IncrCounter(ctx context.Context, counterTable, key string, start,
 	}
 
 	m.counters[counterKey] += count
+	m.touch(counterVersionPrefix + counterKey)
 	return nil
 }
 
 // DecrCounter decrements a counter
 func (m *Mock) DecrCounter(ctx context.Context, counterTable, key string, count int64) error {
+	if _, err := m.inject(ctx, counterTable, FaultCounter); err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err := m.checkTransaction(); err != nil {
+		return err
+	}
 
 	counterKey := fmt.Sprintf("%s:%s", counterTable, key)
 
@@ -32,11 +45,15 @@ func (m *Mock) DecrCounter(ctx context.Context, counterTable, key string, count
 	}
 
 	m.counters[counterKey] -= count
+	m.touch(counterVersionPrefix + counterKey)
 	return nil
 }
 
 // GetCounter gets the current counter value
 func (m *Mock) GetCounter(ctx context.Context, counterTable, key string) (int64, error) {
+	if _, err := m.inject(ctx, counterTable, FaultCounter); err != nil {
+		return 0, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
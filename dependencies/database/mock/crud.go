@@ -3,7 +3,9 @@ package mock
 import (
 	"context"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 
@@ -12,38 +14,57 @@ import (
 
 // Insert inserts one or more documents
 func (m *Mock) Insert(ctx context.Context, tableName string, docs any) (count int, err error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	table := m.getOrCreateTable(tableName)
-
 	// Check if docs is a slice
 	v := reflect.ValueOf(docs)
 	if v.Kind() == reflect.Pointer {
 		v = v.Elem()
 	}
+	size := 1
+	if v.Kind() == reflect.Slice {
+		size = v.Len()
+	}
+	partial, err := m.injectDocs(ctx, tableName, FaultInsert, size)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
+
+	table := m.getOrCreateTable(tableName)
 
+	items := []any{docs}
 	if v.Kind() == reflect.Slice {
-		// Multiple documents
+		items = make([]any, v.Len())
 		for i := range v.Len() {
-			doc := v.Index(i).Interface()
-			row, err := structToMap(doc)
-			if err != nil {
-				return count, err
-			}
-			table.data = append(table.data, row)
-			count++
+			items[i] = v.Index(i).Interface()
 		}
-	} else {
-		// Single document
-		row, err := structToMap(docs)
-		if err != nil {
+	}
+	// the documents from the index of a partial insert fault fail, a single document fails from the index 0
+	n := len(items)
+	if partial != nil {
+		n = min(n, partial.FailFrom)
+	}
+	rows := make([]map[string]any, n)
+	for i := range n {
+		if rows[i], err = structToMap(items[i]); err != nil {
 			return 0, err
 		}
+	}
+	if err = checkUnique(tableName, m.uniqueIndexes(tableName), table.data, nil, rows); err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
 		table.data = append(table.data, row)
-		count = 1
+		m.emit(tableName, database.OpInsert, row)
+	}
+	count = n
+	if n < len(items) {
+		return count, partial.insertError(tableName, len(items))
 	}
-
 	return count, nil
 }
 
@@ -55,8 +76,15 @@ func (m *Mock) InsertOne(ctx context.Context, tableName string, data any) error
 
 // Update updates documents matching the condition
 func (m *Mock) Update(ctx context.Context, tableName string, condition database.C, doc any) (count int, err error) {
+	if _, err = m.inject(ctx, tableName, FaultUpdate); err != nil {
+		return 0, err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
 
 	table := m.getOrCreateTable(tableName)
 
@@ -78,11 +106,24 @@ func (m *Mock) Update(ctx context.Context, tableName string, condition database.
 	}
 
 	// Update matching rows
+	replaced := make(map[int]map[string]any)
 	for i := range table.data {
 		if matchConditions(table.data[i], condition) {
+			// rows are shared with transaction snapshots, never modify them in place
+			row := maps.Clone(table.data[i])
 			for key, value := range updates {
-				table.data[i][key] = value
+				row[key] = value
 			}
+			replaced[i] = row
+		}
+	}
+	if err = checkUnique(tableName, m.uniqueIndexes(tableName), table.data, replaced, nil); err != nil {
+		return 0, err
+	}
+	for i := range table.data {
+		if row, ok := replaced[i]; ok {
+			table.data[i] = row
+			m.emit(tableName, database.OpUpdate, row)
 			count++
 		}
 	}
@@ -92,11 +133,31 @@ func (m *Mock) Update(ctx context.Context, tableName string, condition database.
 
 // UpdateOne updates a single document matching the condition
 func (m *Mock) UpdateOne(ctx context.Context, tableName string, condition database.C, doc any) (count int, err error) {
+	if _, err = m.inject(ctx, tableName, FaultUpdate); err != nil {
+		return 0, err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
 
 	table := m.getOrCreateTable(tableName)
 
+	// the version of a versioned model is checked and incremented
+	filter := condition
+	version, versioned := database.VersionOf(doc)
+	if versioned {
+		filter = version.Scope(condition)
+		version.Increment()
+		defer func() {
+			if count == 0 {
+				version.Reset()
+			}
+		}()
+	}
+
 	// Convert doc to map
 	var updates map[string]any
 	switch v := doc.(type) {
@@ -116,14 +177,25 @@ func (m *Mock) UpdateOne(ctx context.Context, tableName string, condition databa
 
 	// Update first matching row
 	for i := range table.data {
-		if matchConditions(table.data[i], condition) {
+		if matchConditions(table.data[i], filter) {
+			// rows are shared with transaction snapshots, never modify them in place
+			row := maps.Clone(table.data[i])
 			for key, value := range updates {
-				table.data[i][key] = value
+				row[key] = value
+			}
+			err = checkUnique(tableName, m.uniqueIndexes(tableName), table.data, map[int]map[string]any{i: row}, nil)
+			if err != nil {
+				return 0, err
 			}
+			table.data[i] = row
+			m.emit(tableName, database.OpUpdate, row)
 			return 1, nil
 		}
 	}
 
+	if versioned && table.exists(condition) {
+		return 0, database.ErrVersionConflict
+	}
 	return 0, nil
 }
 
@@ -131,8 +203,15 @@ func (m *Mock) UpdateOne(ctx context.Context, tableName string, condition databa
 // indexKeys specifies which fields to use as the match condition.
 // If indexKeys is empty, "_id" / "id" is used as default.
 func (m *Mock) Replace(ctx context.Context, tableName string, indexKeys []string, docs any) (count int, err error) {
+	if _, err = m.inject(ctx, tableName, FaultReplace); err != nil {
+		return 0, err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
 
 	if len(indexKeys) == 0 {
 		indexKeys = []string{"id"}
@@ -158,10 +237,17 @@ func (m *Mock) Replace(ctx context.Context, tableName string, indexKeys []string
 		items = []any{docs}
 	}
 
+	// the rows are replaced in a copy, which is checked by the unique indexes before it is applied
+	data := slices.Clone(table.data)
+	type change struct {
+		op  database.ChangeOp
+		row map[string]any
+	}
+	var changes []change
 	for _, item := range items {
 		newRow, convErr := structToMap(item)
 		if convErr != nil {
-			return count, convErr
+			return 0, convErr
 		}
 
 		// Build condition from index keys
@@ -175,51 +261,103 @@ func (m *Mock) Replace(ctx context.Context, tableName string, indexKeys []string
 
 		// Find and replace, or insert if not found
 		replaced := false
-		for i := range table.data {
-			if matchConditions(table.data[i], cond) {
-				table.data[i] = newRow
+		for i := range data {
+			if matchConditions(data[i], cond) {
+				data[i] = newRow
+				changes = append(changes, change{database.OpReplace, newRow})
 				replaced = true
 				break
 			}
 		}
 		if !replaced {
-			table.data = append(table.data, newRow)
+			data = append(data, newRow)
+			changes = append(changes, change{database.OpInsert, newRow})
 		}
-		count++
 	}
+	if err = checkUnique(tableName, m.uniqueIndexes(tableName), data, nil, nil); err != nil {
+		return 0, err
+	}
+	table.data = data
+	for _, c := range changes {
+		m.emit(tableName, c.op, c.row)
+	}
+	count = len(changes)
 
 	return count, nil
 }
 
 // ReplaceOne replaces a single document matching condition (upsert: insert if not found).
 func (m *Mock) ReplaceOne(ctx context.Context, tableName string, condition database.C, data any) (count int, err error) {
+	if _, err = m.inject(ctx, tableName, FaultReplace); err != nil {
+		return 0, err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
 
 	table := m.getOrCreateTable(tableName)
 
+	// a versioned model is never inserted, and its version is checked and incremented
+	filter := condition
+	version, versioned := database.VersionOf(data)
+	if versioned {
+		filter = version.Scope(condition)
+		version.Increment()
+		defer func() {
+			if count == 0 {
+				version.Reset()
+			}
+		}()
+	}
+
 	newRow, err := structToMap(data)
 	if err != nil {
 		return 0, err
 	}
 
 	// Replace first matching row
+	unique := m.uniqueIndexes(tableName)
 	for i := range table.data {
-		if matchConditions(table.data[i], condition) {
+		if matchConditions(table.data[i], filter) {
+			if err = checkUnique(tableName, unique, table.data, map[int]map[string]any{i: newRow}, nil); err != nil {
+				return 0, err
+			}
 			table.data[i] = newRow
+			m.emit(tableName, database.OpReplace, newRow)
 			return 1, nil
 		}
 	}
 
+	if versioned {
+		if table.exists(condition) {
+			return 0, database.ErrVersionConflict
+		}
+		return 0, NewNotFoundError(tableName)
+	}
+
 	// Not found: insert (upsert semantics, matching mongo SetUpsert(true))
+	if err = checkUnique(tableName, unique, table.data, nil, []map[string]any{newRow}); err != nil {
+		return 0, err
+	}
 	table.data = append(table.data, newRow)
+	m.emit(tableName, database.OpInsert, newRow)
 	return 1, nil
 }
 
 // Delete deletes documents matching the condition
 func (m *Mock) Delete(ctx context.Context, tableName string, condition database.C) (count int, err error) {
+	if _, err = m.inject(ctx, tableName, FaultDelete); err != nil {
+		return 0, err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
 
 	table := m.getOrCreateTable(tableName)
 
@@ -229,6 +367,7 @@ func (m *Mock) Delete(ctx context.Context, tableName string, condition database.
 		if !matchConditions(row, condition) {
 			newData = append(newData, row)
 		} else {
+			m.emit(tableName, database.OpDelete, row)
 			count++
 		}
 	}
@@ -239,8 +378,15 @@ func (m *Mock) Delete(ctx context.Context, tableName string, condition database.
 
 // DeleteOne deletes a single document matching the condition
 func (m *Mock) DeleteOne(ctx context.Context, tableName string, condition database.C) (count int, err error) {
+	if _, err = m.inject(ctx, tableName, FaultDelete); err != nil {
+		return 0, err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err = m.checkTransaction(); err != nil {
+		return 0, err
+	}
+	defer m.touchIfChanged(tableName, &count)
 
 	table := m.getOrCreateTable(tableName)
 
@@ -248,6 +394,7 @@ func (m *Mock) DeleteOne(ctx context.Context, tableName string, condition databa
 	for i, row := range table.data {
 		if matchConditions(row, condition) {
 			table.data = append(table.data[:i], table.data[i+1:]...)
+			m.emit(tableName, database.OpDelete, row)
 			return 1, nil
 		}
 	}
@@ -257,6 +404,9 @@ func (m *Mock) DeleteOne(ctx context.Context, tableName string, condition databa
 
 // Find finds documents matching the condition
 func (m *Mock) Find(ctx context.Context, tableName string, condition database.C, sortBy []string, limit int, arrayPtr any) error {
+	if _, err := m.inject(ctx, tableName, FaultFind); err != nil {
+		return err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -277,6 +427,9 @@ func (m *Mock) Find(ctx context.Context, tableName string, condition database.C,
 
 	// Sort if needed
 	if len(sortBy) > 0 {
+		if err := setSearchScores(matches, condition, sortBy); err != nil {
+			return err
+		}
 		sortRows(matches, sortBy)
 	}
 
@@ -291,6 +444,9 @@ func (m *Mock) Find(ctx context.Context, tableName string, condition database.C,
 
 // FindOne finds a single document matching the condition
 func (m *Mock) FindOne(ctx context.Context, tableName string, condition database.C, data any) error {
+	if _, err := m.inject(ctx, tableName, FaultFind); err != nil {
+		return err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -307,6 +463,9 @@ func (m *Mock) FindOne(ctx context.Context, tableName string, condition database
 
 // FindRows returns a row iterator for streaming
 func (m *Mock) FindRows(ctx context.Context, tableName string, condition database.C, sortBy []string, limit int, oneData any) (database.Row, error) {
+	if _, err := m.inject(ctx, tableName, FaultFind); err != nil {
+		return nil, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -326,6 +485,9 @@ func (m *Mock) FindRows(ctx context.Context, tableName string, condition databas
 
 	// Sort if needed
 	if len(sortBy) > 0 {
+		if err := setSearchScores(matches, condition, sortBy); err != nil {
+			return nil, err
+		}
 		sortRows(matches, sortBy)
 	}
 
@@ -342,6 +504,9 @@ func (m *Mock) FindRows(ctx context.Context, tableName string, condition databas
 
 // Exist checks if any document matches the condition
 func (m *Mock) Exist(ctx context.Context, tableName string, condition database.C) (bool, error) {
+	if _, err := m.inject(ctx, tableName, FaultFind); err != nil {
+		return false, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -358,6 +523,9 @@ func (m *Mock) Exist(ctx context.Context, tableName string, condition database.C
 
 // Count counts documents matching the condition
 func (m *Mock) Count(ctx context.Context, tableName string, condition database.C) (int64, error) {
+	if _, err := m.inject(ctx, tableName, FaultCount); err != nil {
+		return 0, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -412,14 +580,23 @@ func sliceToArray(matches []map[string]any, arrayPtr any) error {
 		return fmt.Errorf("arrayPtr must be a pointer to slice")
 	}
 
+	// the slice may be []T or []*T
 	elemType := v.Type().Elem()
+	isPointer := elemType.Kind() == reflect.Pointer
+	if isPointer {
+		elemType = elemType.Elem()
+	}
 
 	for _, match := range matches {
 		elem := reflect.New(elemType)
 		if err := mapToStruct(match, elem.Interface()); err != nil {
 			return err
 		}
-		v.Set(reflect.Append(v, elem.Elem()))
+		if isPointer {
+			v.Set(reflect.Append(v, elem))
+		} else {
+			v.Set(reflect.Append(v, elem.Elem()))
+		}
 	}
 
 	return nil
@@ -443,6 +620,14 @@ func (r *mockRow) Decode() (any, error) {
 	return r.data[r.current], nil
 }
 
+// Scan implements database.RowScanner, it decodes the map of the current row into the struct pointer
+func (r *mockRow) Scan(data any) error {
+	if r.current < 0 || r.current >= len(r.data) {
+		return NewInvalidArgumentError("row_position", "position out of range")
+	}
+	return mapToStruct(r.data[r.current], data)
+}
+
 func (r *mockRow) Close() error {
 	r.data = nil
 	return nil
@@ -11,6 +11,7 @@
 //
 //	mock://local/testdb
 //	mock://memory/myapp
+//	mock://local/testdb?fixtures=testdata/*.yaml
 //
 // Basic Usage:
 //
@@ -40,10 +41,15 @@
 //
 //   - In-memory storage
 //   - Full CRUD operations
-//   - Conditional queries (Eq, Ne, Gt, Gte, Lt, Lte, In, Nin)
+//   - Conditional queries (Eq, Ne, Gt, Gte, Lt, Lte, In, Nin, Like, Regex, Exists, Between, ArrayContains, JSONMatch)
+//   - Or, And, Not condition groups and nested keys such as profile.city
 //   - Sorting and limiting
+//   - Aggregations with group by and having
 //   - Counter operations
-//   - Transaction support (simulated)
+//   - Transactions with snapshot isolation
+//   - Change streams with resume tokens (database.Watcher)
+//   - Fixtures, snapshots and JSON dumps (LoadFixtures, Snapshot, Restore, Dump)
+//   - Fault injection of errors, latency and partial inserts (AddFault, FaultsFired)
 //   - Thread-safe with sync.RWMutex
 //   - Perfect for unit testing
 //
@@ -3,6 +3,9 @@ package mock
 import (
 	"encoding/json/v2"
 	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Error represents a structured error with snake_case JSON format
@@ -17,6 +20,31 @@ func (e *Error) Error() string {
 	return e.ErrorMessage
 }
 
+// GRPCStatus returns the grpc status of the error, so that status.Code works the same as the other databases
+func (e *Error) GRPCStatus() *status.Status {
+	code, ok := errorCodes[e.ErrorCode]
+	if !ok {
+		code = codes.Unknown
+	}
+	msg := e.ErrorMessage
+	if e.ErrorDescription != "" {
+		msg += ": " + e.ErrorDescription
+	}
+	return status.New(code, msg)
+}
+
+// errorCodes the mapping of error codes to grpc codes
+var errorCodes = map[string]codes.Code{
+	"not_found":          codes.NotFound,
+	"invalid_argument":   codes.InvalidArgument,
+	"already_exists":     codes.AlreadyExists,
+	"invalid_operation":  codes.FailedPrecondition,
+	"transaction_closed": codes.FailedPrecondition,
+	"transaction_error":  codes.FailedPrecondition,
+	"aborted":            codes.Aborted,
+	"database_error":     codes.Internal,
+}
+
 // MarshalJSON returns the JSON encoding with snake_case format
 func (e *Error) MarshalJSON() ([]byte, error) {
 	type Alias Error
@@ -67,6 +95,15 @@ func NewInvalidArgumentError(argument, reason string) *Error {
 	}
 }
 
+// NewInvalidOperationError creates an invalid operation error
+func NewInvalidOperationError(operation, reason string) *Error {
+	return &Error{
+		ErrorCode:        "invalid_operation",
+		ErrorMessage:     "invalid operation",
+		ErrorDescription: fmt.Sprintf("%s: %s", operation, reason),
+	}
+}
+
 // NewTransactionError creates a transaction error
 func NewTransactionError(operation, reason string) *Error {
 	return &Error{
@@ -76,6 +113,24 @@ func NewTransactionError(operation, reason string) *Error {
 	}
 }
 
+// NewAlreadyExistsError creates an error for the rows which conflict by the unique index of the table
+func NewAlreadyExistsError(tableName, index string) *Error {
+	return &Error{
+		ErrorCode:        "already_exists",
+		ErrorMessage:     "record already exists",
+		ErrorDescription: fmt.Sprintf("duplicate key of the unique index '%s' in table '%s'", index, tableName),
+	}
+}
+
+// NewAbortedError creates an error for the transaction conflicts, the transaction can be retried
+func NewAbortedError(tableName string) *Error {
+	return &Error{
+		ErrorCode:        "aborted",
+		ErrorMessage:     "transaction aborted",
+		ErrorDescription: fmt.Sprintf("'%s' was modified by another transaction", tableName),
+	}
+}
+
 // NewDatabaseError creates a generic database error
 func NewDatabaseError(message string) *Error {
 	return &Error{
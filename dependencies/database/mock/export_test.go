@@ -1,6 +1,6 @@
 package mock
 
-// ExportToSnakeCase exports toSnakeCase for testing
+// ExportToSnakeCase exports normalizeKey for testing
 func ExportToSnakeCase(s string) string {
-	return toSnakeCase(s)
+	return normalizeKey(s)
 }
@@ -0,0 +1,287 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FaultOp the operations of the mock which the faults are injected into
+type FaultOp string
+
+const (
+	// FaultInsert Insert and InsertOne
+	FaultInsert FaultOp = "insert"
+	// FaultUpdate Update and UpdateOne
+	FaultUpdate FaultOp = "update"
+	// FaultReplace Replace and ReplaceOne
+	FaultReplace FaultOp = "replace"
+	// FaultDelete Delete and DeleteOne
+	FaultDelete FaultOp = "delete"
+	// FaultFind Find, FindOne, FindRows and Exist, the page and stream queries are the find and count operations
+	FaultFind FaultOp = "find"
+	// FaultCount Count
+	FaultCount FaultOp = "count"
+	// FaultAggregate Aggregate
+	FaultAggregate FaultOp = "aggregate"
+	// FaultCounter IncrCounter, DecrCounter and GetCounter, the table is the counter table
+	FaultCounter FaultOp = "counter"
+	// FaultCommit the commit of the transactions, the table is empty
+	FaultCommit FaultOp = "commit"
+)
+
+// Fault the rule to make the mock misbehave, for exp the first two inserts of users are unavailable:
+//
+//	m.AddFault(mock.Fault{Table: "users", Ops: []mock.FaultOp{mock.FaultInsert}, Code: codes.Unavailable, Times: 2})
+//
+// The rules are checked in the order of adding, the first matching rule fires. The latency is waited before
+// the error is returned, it returns the error of the ctx if the ctx is done.
+type Fault struct {
+	// Name the name of the fired counter, it is fault<n> by default, n is the order of the rule from 1
+	Name string
+	// Table the table of the operations, empty matches all the tables
+	Table string
+	// Ops the operations, empty matches all the operations
+	Ops []FaultOp
+	// Code the grpc code of the error, for exp codes.AlreadyExists is the unique key conflict
+	Code codes.Code
+	// Err the error to return, it overrides Code, for exp a *database.BulkError
+	Err error
+	// Latency the latency of the operations
+	Latency time.Duration
+	// Jitter the random latency in [0, Jitter) added to Latency
+	Jitter time.Duration
+	// Partial makes the rule a partial insert, the documents of Insert from the index FailFrom fail, the
+	// documents before it are inserted and a *database.BulkError with an element of every failed index is
+	// returned, the error is codes.AlreadyExists if Code and Err are not set. The rule matches the Insert of
+	// more documents than FailFrom only.
+	Partial bool
+	// FailFrom the index of the first document which fails in the Insert of a Partial rule, 0 fails them all
+	FailFrom int
+	// Times the max times the rule fires, 0 means unlimited. A rule fires when it injects its error or latency.
+	Times int
+	// Probability the probability in (0, 1) the rule fires, 0 means always
+	Probability float64
+}
+
+// faults the fault rules shared by the mock and the snapshots of its transactions
+type faults struct {
+	mu    sync.Mutex
+	rules []*Fault
+	fired map[string]int
+}
+
+// AddFault adds the fault rule, see [Fault].
+func (m *Mock) AddFault(fault Fault) {
+	m.getFaults().add(fault)
+}
+
+func (f *faults) add(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fault.Name == "" {
+		fault.Name = "fault" + strconv.Itoa(len(f.rules)+1)
+	}
+	f.rules = append(f.rules, &fault)
+}
+
+// ClearFaults removes the fault rules and resets the fired counters.
+func (m *Mock) ClearFaults() {
+	f := m.getFaults()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = nil
+	clear(f.fired)
+}
+
+// FaultsFired returns the times the fault rules fired by their names, a rule which injects nothing, for exp a
+// Partial rule of an Insert of fewer documents, is not fired.
+func (m *Mock) FaultsFired() map[string]int {
+	f := m.getFaults()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.fired)
+}
+
+func (m *Mock) getFaults() *faults {
+	if m.tx != nil {
+		return m.tx.parent.getFaults()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.faults == nil {
+		m.faults = &faults{fired: make(map[string]int)}
+	}
+	return m.faults
+}
+
+// loadFaults returns the fault rules, it is nil if no rule is added.
+func (m *Mock) loadFaults() *faults {
+	if m.tx != nil {
+		return m.tx.parent.loadFaults()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.faults
+}
+
+// fault returns the fired rule of the operation, it is nil if no rule fires. The docs is the count of the
+// documents of Insert.
+func (f *faults) fault(table string, op FaultOp, docs int) *Fault {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rule := range f.rules {
+		if rule.Table != "" && rule.Table != table {
+			continue
+		}
+		if len(rule.Ops) > 0 && !slices.Contains(rule.Ops, op) {
+			continue
+		}
+		if rule.Partial && (op != FaultInsert || docs <= rule.FailFrom) {
+			continue
+		}
+		if !rule.Partial && rule.Err == nil && rule.Code == codes.OK && rule.Latency <= 0 && rule.Jitter <= 0 {
+			// the rule injects nothing
+			continue
+		}
+		if rule.Times > 0 && f.fired[rule.Name] >= rule.Times {
+			continue
+		}
+		if rule.Probability > 0 && rand.Float64() >= rule.Probability {
+			continue
+		}
+		f.fired[rule.Name]++
+		return rule
+	}
+	return nil
+}
+
+// inject waits the latency and returns the error of the fired rule, the rule of a partial insert is returned
+// without the error, see insertError. It must be called before the lock is held.
+func (m *Mock) inject(ctx context.Context, table string, op FaultOp) (*Fault, error) {
+	return m.injectDocs(ctx, table, op, 0)
+}
+
+// injectDocs inject the faults of the Insert of the count of docs, see inject.
+func (m *Mock) injectDocs(ctx context.Context, table string, op FaultOp, docs int) (*Fault, error) {
+	rule := m.loadFaults().fault(table, op, docs)
+	if rule == nil {
+		return nil, nil
+	}
+	if latency := rule.Latency + randDuration(rule.Jitter); latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if rule.Partial {
+		return rule, nil
+	}
+	return nil, rule.error(table, op)
+}
+
+// insertError returns the *database.BulkError of the partial insert rule of the count of docs, every
+// document from FailFrom fails.
+func (f *Fault) insertError(table string, docs int) error {
+	err := f.error(table, FaultInsert)
+	if err == nil {
+		err = status.Errorf(codes.AlreadyExists, "%s may already exists for the fault %s", table, f.Name)
+	}
+	bulkErr := &database.BulkError{Err: err}
+	for i := f.FailFrom; i < docs; i++ {
+		bulkErr.Elements = append(bulkErr.Elements, &database.BulkElement{Index: i, Message: err.Error()})
+	}
+	return bulkErr
+}
+
+func (f *Fault) error(table string, op FaultOp) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	if f.Code == codes.OK {
+		return nil
+	}
+	return status.Errorf(f.Code, "the fault %s of %s %s", f.Name, op, table)
+}
+
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+// parseFault parses the fault of the uri, the format is the comma separated key:value pairs, for exp:
+//
+//	table:users,op:insert|update,code:unavailable,latency:10ms,jitter:5ms,from:1,times:2,probability:0.5,name:x
+//
+// The code is the name of the grpc code in snake_case or the number of the code, the from makes the rule
+// Partial.
+func parseFault(value string) (Fault, error) {
+	var fault Fault
+	for pair := range strings.SplitSeq(value, ",") {
+		key, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fault, NewInvalidArgumentError("fault", fmt.Sprintf("invalid pair %q", pair))
+		}
+		var err error
+		switch key {
+		case "name":
+			fault.Name = v
+		case "table":
+			fault.Table = v
+		case "op":
+			for op := range strings.SplitSeq(v, "|") {
+				fault.Ops = append(fault.Ops, FaultOp(op))
+			}
+		case "code":
+			fault.Code, err = parseCode(v)
+		case "latency":
+			fault.Latency, err = time.ParseDuration(v)
+		case "jitter":
+			fault.Jitter, err = time.ParseDuration(v)
+		case "from":
+			fault.Partial = true
+			fault.FailFrom, err = strconv.Atoi(v)
+		case "times":
+			fault.Times, err = strconv.Atoi(v)
+		case "probability":
+			fault.Probability, err = strconv.ParseFloat(v, 64)
+		default:
+			err = fmt.Errorf("unknown key %s", key)
+		}
+		if err != nil {
+			return fault, NewInvalidArgumentError("fault", fmt.Sprintf("invalid %s: %v", key, err))
+		}
+	}
+	return fault, nil
+}
+
+func parseCode(v string) (codes.Code, error) {
+	if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return codes.Code(n), nil
+	}
+	name := strings.ReplaceAll(v, "_", "")
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(c.String(), name) {
+			return c, nil
+		}
+	}
+	return codes.OK, fmt.Errorf("unknown code %s", v)
+}
@@ -0,0 +1,174 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/database/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFaultErrors(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb")
+	m.AddFault(mock.Fault{Table: "users", Ops: []mock.FaultOp{mock.FaultInsert}, Code: codes.Unavailable, Times: 2})
+
+	for i := range 2 {
+		if err := m.InsertOne(ctx, "users", &TestUser{ID: 1}); status.Code(err) != codes.Unavailable {
+			t.Fatalf("insert %d: expected unavailable, got %v", i, err)
+		}
+	}
+	if err := m.InsertOne(ctx, "users", &TestUser{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// the other tables and operations are not matched
+	if err := m.InsertOne(ctx, "orders", &TestUser{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Count(ctx, "users", nil); err != nil || n != 1 {
+		t.Fatalf("expected 1 user, got %d %v", n, err)
+	}
+	if fired := m.FaultsFired(); fired["fault1"] != 2 {
+		t.Fatalf("unexpected fired %v", fired)
+	}
+
+	// the writes of the bulk models fail as the bulk errors
+	m.AddFault(mock.Fault{Name: "conflict", Ops: []mock.FaultOp{mock.FaultUpdate}, Code: codes.AlreadyExists})
+	_, err := database.BulkWrite(ctx, m, "users", []database.WriteModel{
+		database.InsertModel(&TestUser{ID: 2}),
+		database.UpdateModel(database.C{{Key: "id", Value: int64(1)}}, database.D{{Key: "age", Value: 1}}),
+	})
+	var bulkErr *database.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 1 || bulkErr.Elements[0].Index != 1 ||
+		status.Code(bulkErr.Err) != codes.AlreadyExists {
+		t.Fatalf("unexpected bulk error %v", err)
+	}
+
+	m.ClearFaults()
+	if _, err = m.Update(ctx, "users", nil, database.D{{Key: "age", Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if fired := m.FaultsFired(); len(fired) != 0 {
+		t.Fatalf("unexpected fired %v", fired)
+	}
+}
+
+func TestFaultPartialInsert(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb")
+	m.AddFault(mock.Fault{Name: "partial", Ops: []mock.FaultOp{mock.FaultInsert}, Partial: true, FailFrom: 2})
+
+	// the insert of two documents is not failed and the rule is not fired
+	if _, err := m.Insert(ctx, "users", []*TestUser{{ID: 1}, {ID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if fired := m.FaultsFired(); len(fired) != 0 {
+		t.Fatalf("unexpected fired %v", fired)
+	}
+	count, err := m.Insert(ctx, "users", []*TestUser{{ID: 3}, {ID: 4}, {ID: 5}})
+	var bulkErr *database.BulkError
+	if count != 2 || !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 1 || bulkErr.Elements[0].Index != 2 ||
+		status.Code(bulkErr.Err) != codes.AlreadyExists {
+		t.Fatalf("unexpected partial insert %d %v", count, err)
+	}
+	if n, _ := m.Count(ctx, "users", nil); n != 4 {
+		t.Fatalf("expected 4 users, got %d", n)
+	}
+	if fired := m.FaultsFired(); fired["partial"] != 1 {
+		t.Fatalf("unexpected fired %v", fired)
+	}
+
+	// all the documents fail from the index 0
+	m.ClearFaults()
+	m.AddFault(mock.Fault{Ops: []mock.FaultOp{mock.FaultInsert}, Partial: true, Code: codes.Unavailable})
+	if err = m.InsertOne(ctx, "users", &TestUser{ID: 6}); !errors.As(err, &bulkErr) ||
+		bulkErr.Elements[0].Index != 0 || status.Code(bulkErr.Err) != codes.Unavailable {
+		t.Fatalf("unexpected failed insert %v", err)
+	}
+	// every failed document is an element of the bulk error
+	count, err = m.Insert(ctx, "users", []*TestUser{{ID: 6}, {ID: 7}})
+	if count != 0 || !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 2 || bulkErr.Elements[1].Index != 1 {
+		t.Fatalf("unexpected failed insert %d %v", count, err)
+	}
+	if n, _ := m.Count(ctx, "users", nil); n != 4 {
+		t.Fatalf("expected 4 users, got %d", n)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	m := newFixturesMock(t, "mock://local/testdb")
+	m.AddFault(mock.Fault{Ops: []mock.FaultOp{mock.FaultFind}, Latency: 20 * time.Millisecond})
+
+	start := time.Now()
+	var users []*TestUser
+	if err := m.Find(context.Background(), "users", nil, nil, 0, &users); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected the latency, got %s", elapsed)
+	}
+
+	// the latency honours the ctx
+	m.ClearFaults()
+	m.AddFault(mock.Fault{Latency: time.Minute, Jitter: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Find(ctx, "users", nil, nil, 0, &users); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestFaultCommit(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb")
+	m.AddFault(mock.Fault{Ops: []mock.FaultOp{mock.FaultCommit}, Code: codes.Aborted, Times: 1})
+
+	var attempts int
+	err := database.RunInTransaction(ctx, m, func(ctx context.Context, tx database.Database) error {
+		attempts++
+		return tx.InsertOne(ctx, "users", &TestUser{ID: 1})
+	}, database.WithBackoff(-time.Millisecond, 0)) // the non-positive backoff retries at once
+	if err != nil || attempts != 2 {
+		t.Fatalf("expected the transaction retried, got %d %v", attempts, err)
+	}
+	if n, _ := m.Count(ctx, "users", nil); n != 1 {
+		t.Fatalf("expected 1 user, got %d", n)
+	}
+}
+
+func TestFaultURI(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb?fault=name:nf,table:users,op:find|count,code:not_found,times:1"+
+		"&fault=table:orders,code:16&fault=table:items,from:0")
+
+	var user TestUser
+	if err := m.FindOne(ctx, "users", nil, &user); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := m.InsertOne(ctx, "orders", &TestUser{ID: 1}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated, got %v", err)
+	}
+	var bulkErr *database.BulkError
+	if err := m.InsertOne(ctx, "items", &TestUser{ID: 1}); !errors.As(err, &bulkErr) {
+		t.Fatalf("expected the bulk error, got %v", err)
+	}
+	if fired := m.FaultsFired(); fired["nf"] != 1 || fired["fault2"] != 1 || fired["fault3"] != 1 {
+		t.Fatalf("unexpected fired %v", fired)
+	}
+
+	for _, uri := range []string{
+		"mock://local/testdb?fault=table",
+		"mock://local/testdb?fault=code:unknown_code",
+		"mock://local/testdb?fault=latency:1x",
+		"mock://local/testdb?fault=size:1",
+		"mock://local/testdb?fault=from:x",
+	} {
+		if _, err := mock.New(ctx, uri); err == nil {
+			t.Fatalf("expected error for %s", uri)
+		}
+	}
+}
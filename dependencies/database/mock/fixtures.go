@@ -0,0 +1,262 @@
+package mock
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixtures the rows of the tables and the values of the counters, it is the format of the fixture files
+// and the output of Dump, for exp:
+//
+//	tables:
+//	  users:
+//	    - {id: 1, name: Alice, created_at: 2024-01-01T00:00:00Z}
+//	counters:
+//	  ids:
+//	    user: 1
+//
+// The integers are loaded as int64 and the other numbers as float64, the keys of the rows are normalized
+// to snake_case.
+type Fixtures struct {
+	Tables map[string][]map[string]any `json:"tables,omitempty" yaml:"tables"`
+	// Counters the values of the counters by the counter tables and the keys
+	Counters map[string]map[string]int64 `json:"counters,omitempty" yaml:"counters"`
+}
+
+// LoadFixtures seeds the tables and counters from the fixture files of fsys matched by the patterns (see
+// fs.Glob), the files are loaded in the order of their names, for exp:
+//
+//	m.LoadFixtures(os.DirFS("testdata"), "*.yaml", "*.json")
+//
+// The .json, .yaml and .yml files are supported, see [Fixtures]. The rows are appended to the tables and
+// the counters are overwritten, no change events are emitted.
+func (m *Mock) LoadFixtures(fsys fs.FS, patterns ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tx != nil {
+		return NewInvalidOperationError("load fixtures", "fixtures can not be loaded in a transaction")
+	}
+	return m.loadFixtures(fsys, patterns...)
+}
+
+// loadFixtures loads the fixture files, the caller must hold the lock.
+func (m *Mock) loadFixtures(fsys fs.FS, patterns ...string) error {
+	if m.tables == nil {
+		return NewInvalidOperationError("load fixtures", "database is closed")
+	}
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return NewInvalidArgumentError("fixtures", err.Error())
+		}
+		if len(names) == 0 {
+			return NewInvalidArgumentError("fixtures", fmt.Sprintf("no fixture file matches %s", pattern))
+		}
+		for _, name := range names {
+			fixtures, err := readFixtures(fsys, name)
+			if err != nil {
+				return err
+			}
+			m.seed(fixtures)
+		}
+	}
+	return nil
+}
+
+func readFixtures(fsys fs.FS, name string) (*Fixtures, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, NewInvalidArgumentError("fixtures", err.Error())
+	}
+	fixtures := &Fixtures{}
+	switch path.Ext(name) {
+	case ".json":
+		err = json.Unmarshal(data, fixtures, fixtureNumbers)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, fixtures)
+	default:
+		return nil, NewInvalidArgumentError("fixtures", fmt.Sprintf("unsupported fixture file %s", name))
+	}
+	if err != nil {
+		return nil, NewInvalidArgumentError("fixtures", fmt.Sprintf("decode %s error %v", name, err))
+	}
+	return fixtures, nil
+}
+
+// fixtureNumbers decodes the integers of the json fixtures as int64, the other numbers as float64
+var fixtureNumbers = json.WithUnmarshalers(json.UnmarshalFromFunc(func(dec *jsontext.Decoder, v *any) error {
+	if dec.PeekKind() != '0' {
+		return errors.ErrUnsupported
+	}
+	value, err := dec.ReadValue()
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		*v = n
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(value), 64)
+	*v = f
+	return err
+}))
+
+// seed appends the rows and sets the counters of the fixtures, the caller must hold the lock.
+func (m *Mock) seed(fixtures *Fixtures) {
+	for name, rows := range fixtures.Tables {
+		t := m.getOrCreateTable(name)
+		for _, row := range rows {
+			normalized := make(map[string]any, len(row))
+			for key, value := range row {
+				normalized[normalizeKey(key)] = fixtureValue(value)
+			}
+			t.data = append(t.data, normalized)
+		}
+		m.touch(name)
+	}
+	for counterTable, counters := range fixtures.Counters {
+		for key, value := range counters {
+			counterKey := fmt.Sprintf("%s:%s", counterTable, key)
+			m.counters[counterKey] = value
+			m.touch(counterVersionPrefix + counterKey)
+		}
+	}
+}
+
+// fixtureValue converts the integers decoded by yaml to int64, so the fixtures of json and yaml are equal
+func fixtureValue(value any) any {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case map[string]any:
+		for key, elem := range v {
+			v[key] = fixtureValue(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = fixtureValue(elem)
+		}
+	}
+	return value
+}
+
+// loadFixtureFiles loads the fixture files of the paths relative to the working directory, the base names
+// of the paths are the patterns, for exp testdata/*.yaml.
+func (m *Mock) loadFixtureFiles(paths []string) error {
+	for _, p := range paths {
+		if err := m.loadFixtures(os.DirFS(filepath.Dir(p)), filepath.Base(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot the state of the tables, counters and indexes of a mock, see Mock.Snapshot.
+type Snapshot struct {
+	tables   map[string][]map[string]any
+	counters map[string]int64
+	indexes  map[string][]*database.Index
+}
+
+// Snapshot takes the snapshot of the tables, counters and indexes, it is restored by Restore to reset the
+// state between the subtests, for exp:
+//
+//	base := m.Snapshot()
+//	t.Run("delete", func(t *testing.T) {
+//		defer m.Restore(base)
+//		...
+//	})
+func (m *Mock) Snapshot() *Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// rows are never modified in place, so the snapshot only copies the row references
+	s := &Snapshot{
+		tables:   make(map[string][]map[string]any, len(m.tables)),
+		counters: maps.Clone(m.counters),
+		indexes:  make(map[string][]*database.Index, len(m.indexes)),
+	}
+	for name, t := range m.tables {
+		s.tables[name] = slices.Clone(t.data)
+	}
+	for name, indexes := range m.indexes {
+		s.indexes[name] = slices.Clone(indexes)
+	}
+	return s
+}
+
+// Restore restores the tables, counters and indexes of the snapshot taken by Snapshot, no change events are
+// emitted, the transactions started before the restore are aborted on commit.
+func (m *Mock) Restore(s *Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tx != nil {
+		return NewInvalidOperationError("restore", "snapshot can not be restored in a transaction")
+	}
+	if m.tables == nil {
+		return NewInvalidOperationError("restore", "database is closed")
+	}
+	for name := range m.tables {
+		m.touch(name)
+	}
+	for key := range m.counters {
+		m.touch(counterVersionPrefix + key)
+	}
+	m.tables = make(map[string]*table, len(s.tables))
+	for name, data := range s.tables {
+		m.tables[name] = &table{data: slices.Clone(data)}
+		m.touch(name)
+	}
+	m.counters = make(map[string]int64, len(s.counters))
+	maps.Copy(m.counters, s.counters)
+	for key := range m.counters {
+		m.touch(counterVersionPrefix + key)
+	}
+	m.indexes = make(map[string][]*database.Index, len(s.indexes))
+	for name, indexes := range s.indexes {
+		m.indexes[name] = slices.Clone(indexes)
+	}
+	return nil
+}
+
+// Dump writes the tables and counters as the indented json of Fixtures, the keys are sorted, so the output is
+// stable for the golden files. Only the rows of the tables are written if the tables are specified.
+func (m *Mock) Dump(w io.Writer, tables ...string) error {
+	m.mu.RLock()
+	fixtures := &Fixtures{Tables: make(map[string][]map[string]any)}
+	for name, t := range m.tables {
+		if len(tables) == 0 || slices.Contains(tables, name) {
+			fixtures.Tables[name] = slices.Clone(t.data)
+		}
+	}
+	if len(tables) == 0 && len(m.counters) > 0 {
+		fixtures.Counters = make(map[string]map[string]int64)
+		for counterKey, value := range m.counters {
+			counterTable, key, _ := strings.Cut(counterKey, ":")
+			if fixtures.Counters[counterTable] == nil {
+				fixtures.Counters[counterTable] = make(map[string]int64)
+			}
+			fixtures.Counters[counterTable][key] = value
+		}
+	}
+	m.mu.RUnlock()
+	data, err := json.Marshal(fixtures, json.Deterministic(true), jsontext.WithIndent("  "))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,194 @@
+package mock_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/database/mock"
+)
+
+type fixtureProfile struct {
+	City string `json:"city"`
+}
+
+type fixtureUser struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Age       int             `json:"age"`
+	Profile   *fixtureProfile `json:"profile"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type fixtureOrder struct {
+	ID     int64   `json:"id"`
+	UserID int64   `json:"user_id"`
+	Amount float64 `json:"amount"`
+}
+
+func newFixturesMock(t *testing.T, uri string) *mock.Mock {
+	t.Helper()
+	m, err := mock.New(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close(context.Background()) })
+	return m
+}
+
+func TestLoadFixtures(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb")
+	if err := m.LoadFixtures(os.DirFS("testdata"), "*.yaml", "*.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	var user fixtureUser
+	if err := m.FindOne(ctx, "users", database.C{{Key: "profile.city", Value: "bj"}}, &user); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if user.ID != 2 || user.Name != "Bob" || user.Age != 25 || user.Profile == nil || user.Profile.City != "bj" ||
+		!user.CreatedAt.Equal(want) {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	// the integers of json are loaded as int64 exactly
+	var order fixtureOrder
+	if err := m.FindOne(ctx, "orders", database.C{{Key: "user_id", Value: int64(1)}}, &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 9007199254740993 || order.Amount != 12.5 {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	if n, _ := m.GetCounter(ctx, "ids", "user"); n != 2 {
+		t.Fatalf("expected counter 2, got %d", n)
+	}
+	if err := m.IncrCounter(ctx, "ids", "user", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := m.GetCounter(ctx, "ids", "user"); n != 3 {
+		t.Fatalf("expected counter 3, got %d", n)
+	}
+
+	if err := m.LoadFixtures(os.DirFS("testdata"), "*.toml"); err == nil {
+		t.Fatal("expected error for the pattern matching no file")
+	}
+	fsys := fstest.MapFS{"bad.json": {Data: []byte(`{"tables": [`)}}
+	if err := m.LoadFixtures(fsys, "*.json"); err == nil {
+		t.Fatal("expected error for the invalid fixture file")
+	}
+}
+
+func TestFixturesURI(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb?fixtures=testdata/*.yaml,testdata/orders.json")
+	if n, _ := m.Count(ctx, "users", nil); n != 2 {
+		t.Fatalf("expected 2 users, got %d", n)
+	}
+	if n, _ := m.Count(ctx, "orders", nil); n != 1 {
+		t.Fatalf("expected 1 order, got %d", n)
+	}
+
+	if _, err := mock.New(ctx, "mock://local/testdb?fixtures=testdata/none.json"); err == nil {
+		t.Fatal("expected error for the missing fixture file")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb?fixtures=testdata/users.yaml")
+	base := m.Snapshot()
+
+	t.Run("write", func(t *testing.T) {
+		if _, err := m.Delete(ctx, "users", database.C{{Key: "id", Value: int64(1)}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Update(ctx, "users", nil, database.D{{Key: "age", Value: 40}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.InsertOne(ctx, "orders", &fixtureOrder{ID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.IncrCounter(ctx, "ids", "user", 0, 10); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	tx, err := m.StartTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.WithTransaction(ctx, tx).Delete(ctx, "users", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Restore(base); err != nil {
+		t.Fatal(err)
+	}
+	// the transaction started before the restore is aborted
+	if err = tx.Commit(); err == nil {
+		t.Fatal("expected the transaction to be aborted")
+	}
+
+	var user fixtureUser
+	if err = m.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}}, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Age != 30 {
+		t.Fatalf("expected the restored age 30, got %d", user.Age)
+	}
+	if n, _ := m.Count(ctx, "users", nil); n != 2 {
+		t.Fatalf("expected 2 users, got %d", n)
+	}
+	if n, _ := m.Count(ctx, "orders", nil); n != 0 {
+		t.Fatalf("expected no order, got %d", n)
+	}
+	if n, _ := m.GetCounter(ctx, "ids", "user"); n != 2 {
+		t.Fatalf("expected counter 2, got %d", n)
+	}
+}
+
+func TestDump(t *testing.T) {
+	ctx := context.Background()
+	m := newFixturesMock(t, "mock://local/testdb?fixtures=testdata/*.yaml")
+	if err := m.InsertOne(ctx, "orders", &fixtureOrder{ID: 1, UserID: 2, Amount: 9.9}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := m.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	golden := buf.String()
+	for _, v := range []string{`"amount": 9.9`, `"city": "sh"`, `"user": 2`} {
+		if !strings.Contains(golden, v) {
+			t.Fatalf("%s is not in the dump\n%s", v, golden)
+		}
+	}
+
+	// the dump is stable and is loaded as the fixtures
+	loaded := newFixturesMock(t, "mock://local/testdb")
+	if err := loaded.LoadFixtures(fstest.MapFS{"dump.json": {Data: buf.Bytes()}}, "*.json"); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := loaded.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != golden {
+		t.Fatalf("unstable dump\n got: %s\nwant: %s", buf.String(), golden)
+	}
+
+	buf.Reset()
+	if err := m.Dump(&buf, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "users") || strings.Contains(buf.String(), "counters") {
+		t.Fatalf("unexpected tables in the dump\n%s", buf.String())
+	}
+}
@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+// CreateIndex implements database.Indexer, the mock records the indexes and enforces the unique ones, the
+// indexes created in a transaction are created on the database at once.
+func (m *Mock) CreateIndex(ctx context.Context, tableName string, index *database.Index) error {
+	if m.tx != nil {
+		return m.tx.parent.CreateIndex(ctx, tableName, index)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.indexes == nil {
+		return NewInvalidOperationError("create index", "database is closed")
+	}
+	indexes := m.indexes[tableName]
+	if slices.ContainsFunc(indexes, func(v *database.Index) bool { return v.Name == index.Name }) {
+		return nil
+	}
+	if t, ok := m.tables[tableName]; ok && index.Unique {
+		// the unique index of the duplicate rows is not created, like mongo and sql
+		if err := checkUnique(tableName, []*database.Index{index}, t.data, nil, nil); err != nil {
+			return err
+		}
+	}
+	m.indexes[tableName] = append(indexes, index)
+	return nil
+}
+
+// uniqueIndexes returns the unique indexes of the table, the snapshot of a transaction uses the indexes of
+// the database. The caller must hold the lock.
+func (m *Mock) uniqueIndexes(tableName string) []*database.Index {
+	if m.tx != nil {
+		parent := m.tx.parent
+		parent.mu.RLock()
+		defer parent.mu.RUnlock()
+		return parent.uniqueIndexes(tableName)
+	}
+	var unique []*database.Index
+	for _, index := range m.indexes[tableName] {
+		if index.Unique {
+			unique = append(unique, index)
+		}
+	}
+	return unique
+}
+
+// checkUnique returns an already exists error if the rows of the table conflict by the unique indexes after
+// a write, which replaces the rows at the indexes of replaced and appends the inserted rows. The rows which
+// miss a field of the index never conflict, like the null values of sql.
+func checkUnique(tableName string, indexes []*database.Index, data []map[string]any,
+	replaced map[int]map[string]any, inserted []map[string]any,
+) error {
+	for _, index := range indexes {
+		seen := make(map[string]bool, len(data)+len(inserted))
+		conflict := func(row map[string]any) bool {
+			values := make([]string, len(index.Fields))
+			for i, field := range index.Fields {
+				value, ok := lookupValue(row, field)
+				if !ok || isNil(value) {
+					return false
+				}
+				values[i] = fmt.Sprint(value)
+			}
+			key := strings.Join(values, "\x00")
+			if seen[key] {
+				return true
+			}
+			seen[key] = true
+			return false
+		}
+		for i, row := range data {
+			if r, ok := replaced[i]; ok {
+				row = r
+			}
+			if conflict(row) {
+				return NewAlreadyExistsError(tableName, index.Name)
+			}
+		}
+		for _, row := range inserted {
+			if conflict(row) {
+				return NewAlreadyExistsError(tableName, index.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// IndexNames implements database.Indexer
+func (m *Mock) IndexNames(ctx context.Context, tableName string) ([]string, error) {
+	if m.tx != nil {
+		return m.tx.parent.IndexNames(ctx, tableName)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var names []string
+	for _, index := range m.indexes[tableName] {
+		names = append(names, index.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,52 @@
+package mock_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+type IndexedUser struct {
+	OrgID     string    `json:"org_id" db:"unique,name=uid_email"`
+	Email     string    `json:"email" db:"unique,name=uid_email"`
+	Name      string    `json:"name" db:"index,desc"`
+	CreatedAt time.Time `json:"created_at" db:"ttl=720h"`
+}
+
+func TestEnsureModel(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/indextest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	err = database.CreateIndex(ctx, db, "users", &database.Index{Name: "legacy", Fields: []string{"legacy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := database.EnsureModel(ctx, db, "users", &IndexedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Created, []string{"uid_email", "name", "created_at"}) {
+		t.Errorf("Unexpected created indexes %v", report.Created)
+	}
+	if !slices.Equal(report.Extra, []string{"legacy"}) {
+		t.Errorf("Unexpected extra indexes %v", report.Extra)
+	}
+
+	// the indexes are created once
+	report, err = database.EnsureModel(ctx, db, "users", &IndexedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Created) != 0 {
+		t.Errorf("Expected no index is created, got %v", report.Created)
+	}
+}
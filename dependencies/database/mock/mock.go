@@ -2,12 +2,17 @@
 package mock
 
 import (
+	"cmp"
 	"context"
+	"encoding/json/v2"
 	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ti/common-go/dependencies/database"
 )
@@ -25,12 +30,29 @@ type Mock struct {
 	tables          map[string]*table
 	defaultDatabase string
 	counters        map[string]int64
+	// versions the write versions of tables and counters, used to detect transaction conflicts
+	versions map[string]uint64
+	// tx is set when the mock is the snapshot of a transaction
+	tx *mockTransaction
+	// feed fans out the writes to the watchers
+	feed *changeFeed
+	// indexes the indexes of the tables created by CreateIndex
+	indexes map[string][]*database.Index
+	// faults the fault rules, see AddFault
+	faults *faults
 }
 
 type table struct {
 	data []map[string]any
 }
 
+// exists checks if any row matches the condition
+func (t *table) exists(condition database.C) bool {
+	return slices.ContainsFunc(t.data, func(row map[string]any) bool {
+		return matchConditions(row, condition)
+	})
+}
+
 // New creates a new mock database instance
 func New(ctx context.Context, uri string) (*Mock, error) {
 	m := &Mock{}
@@ -43,12 +65,22 @@ func New(ctx context.Context, uri string) (*Mock, error) {
 
 // Init initializes the mock database from URL
 // URL format: mock://host/database?option=value
+//
+// Options:
+//   - fixtures: the fixture files to seed the database, see LoadFixtures. The paths are relative to the working
+//     directory, the base names of the paths can be patterns, for exp fixtures=testdata/*.yaml, the option can
+//     be repeated or separated by commas.
+//   - fault: the fault rule, see AddFault and parseFault for the format, the option can be repeated.
 func (m *Mock) Init(ctx context.Context, u *url.URL) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.tables = make(map[string]*table)
 	m.counters = make(map[string]int64)
+	m.versions = make(map[string]uint64)
+	m.feed = newChangeFeed()
+	m.indexes = make(map[string][]*database.Index)
+	m.faults = &faults{fired: make(map[string]int)}
 
 	// Parse database name from path
 	if u.Path == "" || u.Path == "/" {
@@ -56,7 +88,19 @@ func (m *Mock) Init(ctx context.Context, u *url.URL) error {
 	}
 	m.defaultDatabase = strings.TrimPrefix(u.Path, "/")
 
-	return nil
+	for _, v := range u.Query()["fault"] {
+		fault, err := parseFault(v)
+		if err != nil {
+			return err
+		}
+		m.faults.add(fault)
+	}
+
+	var fixtures []string
+	for _, v := range u.Query()["fixtures"] {
+		fixtures = append(fixtures, strings.Split(v, ",")...)
+	}
+	return m.loadFixtureFiles(fixtures)
 }
 
 // GetDatabase returns a database instance for the specified project
@@ -73,6 +117,12 @@ func (m *Mock) Close(ctx context.Context) error {
 	// Clear all data
 	m.tables = nil
 	m.counters = nil
+	m.versions = nil
+	m.indexes = nil
+	if m.feed != nil {
+		m.feed.close()
+		m.feed = nil
+	}
 
 	return nil
 }
@@ -87,40 +137,9 @@ func (m *Mock) getOrCreateTable(tableName string) *table {
 	return m.tables[tableName]
 }
 
-// toSnakeCase converts camelCase or PascalCase to snake_case
-// Examples:
-//   - userId -> user_id
-//   - UserName -> user_name
-//   - HTTPResponse -> http_response
-//   - user_id -> user_id (already snake_case)
-func toSnakeCase(s string) string {
-	if s == "" {
-		return s
-	}
-
-	var result strings.Builder
-	result.Grow(len(s) + 5) // preallocate some extra space
-
-	for i, r := range s {
-		if i > 0 && r >= 'A' && r <= 'Z' {
-			// Check if previous char is lowercase or if next char is lowercase
-			// This handles cases like "HTTPResponse" -> "http_response"
-			prevLower := s[i-1] >= 'a' && s[i-1] <= 'z'
-			nextLower := i+1 < len(s) && s[i+1] >= 'a' && s[i+1] <= 'z'
-
-			if prevLower || nextLower {
-				result.WriteByte('_')
-			}
-		}
-		result.WriteRune(r)
-	}
-
-	return strings.ToLower(result.String())
-}
-
 // normalizeKey converts a key to snake_case for consistent storage
 func normalizeKey(key string) string {
-	return toSnakeCase(key)
+	return database.SnakeCase(key)
 }
 
 // structToMap converts a struct to map
@@ -217,6 +236,14 @@ func mapToStruct(m map[string]any, dest any) error {
 				} else if val.Type().ConvertibleTo(fieldValue.Type()) {
 					// Handle numeric type mismatches (e.g. int stored, int64 field)
 					fieldValue.Set(val.Convert(fieldValue.Type()))
+				} else if fieldValue.Kind() == reflect.Pointer && val.Type().AssignableTo(fieldValue.Type().Elem()) {
+					// Handle the value stored for a pointer field (e.g. time.Time stored, *time.Time field)
+					ptr := reflect.New(fieldValue.Type().Elem())
+					ptr.Elem().Set(val)
+					fieldValue.Set(ptr)
+				} else if data, err := json.Marshal(value); err == nil {
+					// Handle the values loaded from fixtures (e.g. time string, map of a struct field)
+					_ = json.Unmarshal(data, fieldValue.Addr().Interface())
 				}
 			}
 		}
@@ -227,13 +254,55 @@ func mapToStruct(m map[string]any, dest any) error {
 
 // matchCondition checks if a row matches the given condition
 func matchCondition(row map[string]any, cond database.CE) bool {
-	// Normalize condition key to snake_case for matching
-	normalizedKey := normalizeKey(cond.Key)
-	value, ok := row[normalizedKey]
+	switch {
+	case cond.C.IsGroup():
+		return matchGroup(row, cond)
+	case cond.C == database.JSONMatch:
+		return matchConditions(row, cond.JSONMatches())
+	case cond.C == database.Search:
+		return searchScore(row, cond) > 0
+	}
+	if arrayKey, subKey, ok := strings.Cut(cond.Key, "[*]"); ok {
+		// groups[*].id = admin means any element of groups has the id admin
+		values := lookupArrayValues(row, arrayKey, strings.TrimPrefix(subKey, "."))
+		return matchArray(values, cond)
+	}
+	value, ok := lookupValue(row, cond.Key)
+	if cond.C == database.Exists {
+		return (ok && !isNil(value)) == cond.ShouldExist()
+	}
 	if !ok {
 		return false
 	}
+	return matchValue(value, cond)
+}
 
+// matchArray checks if the elements of an array match the condition, Ne and Nin match the arrays which have
+// none of the values, the other conditions match the arrays which have any element matched.
+func matchArray(values []any, cond database.CE) bool {
+	switch cond.C {
+	case database.Ne:
+		return !containsValue(cond.Value, values)
+	case database.Nin:
+		for _, v := range values {
+			if containsValue(v, cond.Value) {
+				return false
+			}
+		}
+		return true
+	case database.ArrayContains:
+		return containsValue(cond.Value, values)
+	}
+	for _, v := range values {
+		if matchValue(v, cond) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue checks if the value of the field matches the condition
+func matchValue(value any, cond database.CE) bool {
 	switch cond.C {
 	case database.Eq:
 		return reflect.DeepEqual(value, cond.Value)
@@ -251,13 +320,133 @@ func matchCondition(row map[string]any, cond database.CE) bool {
 		return containsValue(value, cond.Value)
 	case database.Nin:
 		return !containsValue(value, cond.Value)
+	case database.Like:
+		return matchRegex(database.LikeToRegex(fmt.Sprint(cond.Value)), value)
+	case database.Regex:
+		return matchRegex(fmt.Sprint(cond.Value), value)
+	case database.Between:
+		from, to, err := cond.Range()
+		if err != nil {
+			return false
+		}
+		return compareValues(value, from) >= 0 && compareValues(value, to) <= 0
+	case database.ArrayContains:
+		return containsValue(cond.Value, value)
+	default:
+		return false
+	}
+}
+
+// matchGroup checks if a row matches the Or, And or Not group, an empty Or or Not group matches nothing
+// and an empty And group matches everything.
+func matchGroup(row map[string]any, cond database.CE) bool {
+	group := cond.Group()
+	switch cond.C {
+	case database.Or:
+		for _, sub := range group {
+			if matchCondition(row, sub) {
+				return true
+			}
+		}
+		return false
+	case database.Not:
+		return len(group) > 0 && !matchConditions(row, group)
 	default:
+		return matchConditions(row, group)
+	}
+}
+
+// matchRegex checks if the string value of the field matches the pattern, null values never match.
+func matchRegex(pattern string, value any) bool {
+	if isNil(value) {
 		return false
 	}
+	matched, err := regexp.MatchString(pattern, fmt.Sprint(value))
+	return err == nil && matched
 }
 
-// compareValues compares two values (for Gt, Gte, Lt, Lte)
+// lookupValue gets the value of the key from the row, the key may be a dotted path of nested maps or structs,
+// for exp: profile.city
+func lookupValue(row map[string]any, key string) (any, bool) {
+	path := strings.Split(key, ".")
+	value, ok := row[normalizeKey(path[0])]
+	for _, name := range path[1:] {
+		if !ok {
+			return nil, false
+		}
+		value, ok = lookupField(value, name)
+	}
+	return value, ok
+}
+
+// lookupField gets the field of a map or struct value by its json name
+func lookupField(value any, name string) (any, bool) {
+	if isNil(value) {
+		return nil, false
+	}
+	if m, ok := value.(map[string]any); ok {
+		v, ok := m[name]
+		if !ok {
+			v, ok = m[normalizeKey(name)]
+		}
+		return v, ok
+	}
+	m, err := structToMap(value)
+	if err != nil {
+		return nil, false
+	}
+	v, ok := m[normalizeKey(name)]
+	return v, ok
+}
+
+// lookupArrayValues gets the values of the sub key from all elements of the array field,
+// the elements themselves are returned when the sub key is empty.
+func lookupArrayValues(row map[string]any, arrayKey, subKey string) []any {
+	array, ok := lookupValue(row, arrayKey)
+	if !ok {
+		return nil
+	}
+	arrayValue := reflect.ValueOf(array)
+	if arrayValue.Kind() != reflect.Slice && arrayValue.Kind() != reflect.Array {
+		return nil
+	}
+	var values []any
+	for i := range arrayValue.Len() {
+		elem := arrayValue.Index(i).Interface()
+		if subKey == "" {
+			values = append(values, elem)
+			continue
+		}
+		if v, ok := lookupField(elem, subKey); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// isNil checks if the value is nil or a nil pointer, map, slice or interface
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// compareValues compares two values (for Gt, Gte, Lt, Lte), numbers of different types are compared as float64
 func compareValues(a, b any) int {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		x, okA := toFloat64(a)
+		y, okB := toFloat64(b)
+		if okA && okB {
+			return cmp.Compare(x, y)
+		}
+	}
 	switch v1 := a.(type) {
 	case int:
 		v2, ok := b.(int)
@@ -298,11 +487,32 @@ func compareValues(a, b any) int {
 			return 0
 		}
 		return strings.Compare(v1, v2)
+	case time.Time:
+		v2, ok := b.(time.Time)
+		if !ok {
+			return 0
+		}
+		return v1.Compare(v2)
 	default:
 		return 0
 	}
 }
 
+// toFloat64 converts the number to float64
+func toFloat64(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
 // containsValue checks if value is in the slice
 func containsValue(value any, slice any) bool {
 	sliceValue := reflect.ValueOf(slice)
@@ -3,25 +3,38 @@ package mock
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strconv"
 
 	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // PageQuery implements pagination query for mock database
 func PageQuery[T any](ctx context.Context, m *Mock, table string,
 	in *database.PageQueryRequest,
 ) (*database.PageQueryResponse[T], error) {
-	// Initialize response
 	out := &database.PageQueryResponse[T]{}
+	if err := m.DoPageQuery(ctx, table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DoPageQuery implements database.PageQuerier, the result is a *database.PageQueryResponse[T]
+func (m *Mock) DoPageQuery(ctx context.Context, table string, in *database.PageQueryRequest, result any) error {
+	out, err := database.NewQueryResult(result)
+	if err != nil {
+		return NewInvalidArgumentError("result", err.Error())
+	}
 
 	// Get total count if not disabled
 	if !in.NoCount {
 		total, err := m.Count(ctx, table, in.Filters)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		out.Total = total
+		out.SetTotal(total)
 	}
 
 	// Set default limit if not specified
@@ -43,51 +56,65 @@ func PageQuery[T any](ctx context.Context, m *Mock, table string,
 	}
 
 	// Query data using Find
-	var results []T
-	err := m.Find(ctx, table, in.Filters, in.Sort, queryLimit, &results)
+	results := reflect.New(out.Data().Type())
+	err = m.Find(ctx, table, in.Filters, in.Sort, queryLimit, results.Interface())
 	if err != nil {
-		return nil, err
+		return err
 	}
+	data := results.Elem()
 
 	// Apply offset manually (skip first N items)
 	if offset > 0 {
-		if offset >= len(results) {
-			results = []T{}
+		if offset >= data.Len() {
+			data = data.Slice(0, 0)
 		} else {
-			results = results[offset:]
+			data = data.Slice(offset, data.Len())
 			// Limit the results to the requested page size
-			if len(results) > limit {
-				results = results[:limit]
+			if data.Len() > limit {
+				data = data.Slice(0, limit)
 			}
 		}
-	} else if len(results) > limit {
+	} else if data.Len() > limit {
 		// No offset but we might have queried more than limit
-		results = results[:limit]
-	}
-
-	// Convert to pointer slice
-	out.Data = make([]*T, 0, len(results))
-	for i := range results {
-		out.Data = append(out.Data, &results[i])
+		data = data.Slice(0, limit)
 	}
 
-	return out, nil
+	out.SetData(data)
+	return nil
 }
 
 // StreamQuery implements stream query for mock database
 func StreamQuery[T any](ctx context.Context, m *Mock, table string,
 	in *database.StreamQueryRequest,
 ) (*database.StreamResponse[T], error) {
-	// Initialize response
 	out := &database.StreamResponse[T]{}
+	if err := m.DoStreamQuery(ctx, table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DoStreamQuery implements database.StreamQuerier, the result is a *database.StreamResponse[T]
+func (m *Mock) DoStreamQuery(ctx context.Context, table string, in *database.StreamQueryRequest, result any) error {
+	out, err := database.NewQueryResult(result)
+	if err != nil {
+		return NewInvalidArgumentError("result", err.Error())
+	}
+
+	if database.HasSearchScore(in.Sort) {
+		return NewInvalidArgumentError("sort", database.SearchScore+" can not be a sort key of the stream query")
+	}
+	if len(in.Sort) > 0 {
+		return m.doSortedStreamQuery(ctx, table, in, out)
+	}
 
 	// Get total count if not disabled
 	if !in.NoCount {
 		total, err := m.Count(ctx, table, in.Filters)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		out.Total = total
+		out.SetTotal(total)
 	}
 
 	// Build filter conditions
@@ -140,39 +167,120 @@ func StreamQuery[T any](ctx context.Context, m *Mock, table string,
 	}
 
 	// Query data
-	var results []T
-	err := m.Find(ctx, table, filters, sortFields, queryLimit, &results)
+	results := reflect.New(out.Data().Type())
+	err = m.Find(ctx, table, filters, sortFields, queryLimit, results.Interface())
 	if err != nil {
-		return nil, err
+		return err
 	}
+	data := results.Elem()
 
 	// Check if there are more pages
-	hasMore := len(results) > limit
+	hasMore := data.Len() > limit
 	if hasMore {
 		// Remove the extra item
-		results = results[:limit]
+		data = data.Slice(0, limit)
 
 		// Set next page token from the last item
-		if len(results) > 0 {
-			lastItem := &results[len(results)-1]
+		if data.Len() > 0 {
+			lastItem := data.Index(data.Len() - 1).Interface()
 			if in.PageField != "" {
 				// Extract page token value from the last item
 				pageTokenValue := extractFieldValue(lastItem, in.PageField)
 				if pageTokenValue != nil {
 					// Convert to string for page token
-					out.PageToken = convertToString(pageTokenValue)
+					out.SetPageToken(convertToString(pageTokenValue))
 				}
 			}
 		}
 	}
 
-	// Convert to pointer slice
-	out.Data = make([]*T, 0, len(results))
-	for i := range results {
-		out.Data = append(out.Data, &results[i])
+	out.SetData(data)
+	return nil
+}
+
+// doSortedStreamQuery the stream query sorted by the keys of in.Sort, the page token holds the sort values
+// of the last item, the sort keys must be unique.
+func (m *Mock) doSortedStreamQuery(ctx context.Context, table string, in *database.StreamQueryRequest,
+	out *database.QueryResult,
+) error {
+	var after []any
+	if in.PageToken != "" {
+		var err error
+		if after, err = database.DecodeSortToken(in.PageToken, in.Sort); err != nil {
+			return NewInvalidArgumentError("page_token", err.Error())
+		}
+	}
+	if !in.NoCount {
+		total, err := m.Count(ctx, table, in.Filters)
+		if err != nil {
+			return err
+		}
+		out.SetTotal(total)
+	}
+	limit := in.Limit
+	if limit <= 0 || limit > 2000 {
+		limit = 2000
+	}
+	results := reflect.New(out.Data().Type())
+	if err := m.Find(ctx, table, in.Filters, in.Sort, 0, results.Interface()); err != nil {
+		return err
+	}
+	_, desc := database.SortKeys(in.Sort)
+	var last []any
+	for _, item := range results.Elem().Seq2() {
+		values, err := database.SortValues(item.Interface(), in.Sort)
+		if err != nil {
+			return NewInvalidArgumentError("sort", err.Error())
+		}
+		if after != nil && !isAfter(values, after, desc) {
+			continue
+		}
+		if out.Len() == limit {
+			pageToken, err := database.EncodeSortToken(in.Sort, last)
+			if err != nil {
+				return NewInvalidArgumentError("sort", err.Error())
+			}
+			out.SetPageToken(pageToken)
+			break
+		}
+		out.Append(item.Interface())
+		last = values
+	}
+	return nil
+}
+
+// isAfter check if the sort values are after the sort values of the page token
+func isAfter(values, after []any, desc []bool) bool {
+	for i := range values {
+		c := compareValues(normalizeSortValue(values[i]), after[i])
+		if desc[i] {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
 	}
+	return false
+}
 
-	return out, nil
+// normalizeSortValue convert the value of the field to the type of the decoded page token value
+func normalizeSortValue(value any) any {
+	if ts, ok := value.(*timestamppb.Timestamp); ok && ts != nil {
+		return ts.AsTime()
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		return nil
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return normalizeSortValue(v.Elem().Interface())
+	case v.Kind() == reflect.String:
+		return v.String()
+	}
+	return value
 }
 
 // extractFieldValue extracts field value from struct
@@ -0,0 +1,143 @@
+package mock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/repotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	users := database.NewRepository[TestUser](db, "users")
+
+	count, err := users.Insert(ctx,
+		&TestUser{ID: 1, Name: "Alice", Age: 25},
+		&TestUser{ID: 2, Name: "Bob", Age: 30},
+		&TestUser{ID: 3, Name: "Charlie", Age: 35},
+	)
+	if err != nil || count != 3 {
+		t.Fatalf("Insert count %d error %v", count, err)
+	}
+
+	user, err := users.FindOne(ctx, database.C{{Key: "name", Value: "Bob"}})
+	if err != nil {
+		t.Fatal("FindOne failed:", err)
+	}
+	if user.ID != 2 {
+		t.Errorf("Expected user 2, got %d", user.ID)
+	}
+
+	found, err := users.Find(ctx, database.C{{Key: "age", Value: 30, C: database.Gte}}, []string{"-age"}, 0)
+	if err != nil {
+		t.Fatal("Find failed:", err)
+	}
+	if len(found) != 2 || found[0].Name != "Charlie" {
+		t.Errorf("Expected Charlie first of 2 users, got %+v", found)
+	}
+
+	user.Age = 31
+	if count, err = users.UpdateOne(ctx, database.C{{Key: "id", Value: int64(2)}}, user); err != nil || count != 1 {
+		t.Fatalf("UpdateOne count %d error %v", count, err)
+	}
+
+	page, err := users.PageQuery(ctx, &database.PageQueryRequest{Sort: []string{"id"}, Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatal("PageQuery failed:", err)
+	}
+	if page.Total != 3 || len(page.Data) != 1 || page.Data[0].ID != 3 {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	stream, err := users.StreamQuery(ctx, &database.StreamQueryRequest{PageField: "id", Ascending: true, Limit: 2})
+	if err != nil {
+		t.Fatal("StreamQuery failed:", err)
+	}
+	if len(stream.Data) != 2 || stream.PageToken == "" {
+		t.Errorf("Unexpected stream %+v", stream)
+	}
+
+	var ages []int
+	for user, err := range users.Iterate(ctx, nil, []string{"id"}, 0) {
+		if err != nil {
+			t.Fatal("Iterate failed:", err)
+		}
+		ages = append(ages, user.Age)
+	}
+	if len(ages) != 3 || ages[1] != 31 {
+		t.Errorf("Unexpected ages %v", ages)
+	}
+	// the rows of FindRows are decoded as maps, Iterate scans them into the models
+	rows, err := db.FindRows(ctx, "users", nil, []string{"id"}, 1, &TestUser{})
+	if err != nil {
+		t.Fatal("FindRows failed:", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if !rows.Next() {
+		t.Fatal("Expected a row")
+	}
+	if row, err := rows.Decode(); err != nil || row.(map[string]any)["name"] != "Alice" {
+		t.Errorf("Unexpected row %v %v", row, err)
+	}
+
+	if count, err = users.Delete(ctx, database.C{{Key: "age", Value: 31, C: database.Gt}}); err != nil || count != 1 {
+		t.Fatalf("Delete count %d error %v", count, err)
+	}
+}
+
+func TestRepositoryIDGenerator(t *testing.T) {
+	type event struct {
+		ID   int64  `json:"_id"`
+		Name string `json:"name"`
+	}
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/idtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	var next int64 = 100
+	events := database.NewRepository[event](db, "events", database.WithIDGenerator(func() int64 {
+		next++
+		return next
+	}))
+	docs := []*event{{Name: "a"}, {ID: 7, Name: "b"}}
+	if _, err = events.Insert(ctx, docs...); err != nil {
+		t.Fatal(err)
+	}
+	if docs[0].ID != 101 || docs[1].ID != 7 {
+		t.Fatalf("unexpected ids %d %d", docs[0].ID, docs[1].ID)
+	}
+	found, err := events.FindOne(ctx, database.C{{Key: "_id", Value: int64(101)}})
+	if err != nil || found.Name != "a" {
+		t.Fatalf("the generated id is not stored %+v %v", found, err)
+	}
+
+	// the field of the db:"id" tag gets the generated id
+	type order struct {
+		ID   int64  `json:"id" db:"id"`
+		Name string `json:"name"`
+	}
+	orders := database.NewRepository[order](db, "orders", database.WithIDGenerator(func() int64 {
+		next++
+		return next
+	}))
+	if _, err = orders.Insert(ctx, &order{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := orders.FindOne(ctx, database.C{{Key: "id", Value: int64(102)}}); err != nil || found.Name != "c" {
+		t.Fatalf("the generated id of the tagged field is not stored %+v %v", found, err)
+	}
+}
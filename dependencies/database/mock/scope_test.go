@@ -0,0 +1,135 @@
+package mock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+type ScopedUser struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func TestScoped(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/scopetest?softDelete=deleted_at&createdAt=created_at&updatedAt=updated_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+
+	before := time.Now()
+	alice := &ScopedUser{ID: 1, Name: "Alice"}
+	if _, err = db.Insert(ctx, "users", []*ScopedUser{alice, {ID: 2, Name: "Bob"}}); err != nil {
+		t.Fatal("Insert failed:", err)
+	}
+	if alice.CreatedAt.Before(before) || !alice.UpdatedAt.Equal(alice.CreatedAt) {
+		t.Errorf("Expected the timestamps are stamped, got %+v", alice)
+	}
+
+	t.Run("Update stamps updated_at", func(t *testing.T) {
+		time.Sleep(time.Millisecond)
+		_, err := db.UpdateOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}},
+			database.D{{Key: "name", Value: "Alicia"}})
+		if err != nil {
+			t.Fatal("UpdateOne failed:", err)
+		}
+		var user ScopedUser
+		if err = db.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}}, &user); err != nil {
+			t.Fatal("FindOne failed:", err)
+		}
+		if user.Name != "Alicia" || !user.UpdatedAt.After(user.CreatedAt) {
+			t.Errorf("Expected the updated_at is stamped, got %+v", user)
+		}
+	})
+
+	t.Run("Delete is soft", func(t *testing.T) {
+		count, err := db.Delete(ctx, "users", database.C{{Key: "id", Value: int64(1)}})
+		if err != nil || count != 1 {
+			t.Fatalf("Delete count %d error %v", count, err)
+		}
+		var user ScopedUser
+		if err = db.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}}, &user); err == nil {
+			t.Error("Expected the soft deleted user is not found")
+		}
+		if total, _ := db.Count(ctx, "users", nil); total != 1 {
+			t.Errorf("Expected 1 user, got %d", total)
+		}
+		page, err := database.NewRepository[ScopedUser](db, "users").PageQuery(ctx, &database.PageQueryRequest{})
+		if err != nil || page.Total != 1 || len(page.Data) != 1 || page.Data[0].Name != "Bob" {
+			t.Errorf("Expected the page of Bob, got %+v error %v", page, err)
+		}
+		// deleting again changes nothing
+		if count, _ = db.Delete(ctx, "users", database.C{{Key: "id", Value: int64(1)}}); count != 0 {
+			t.Errorf("Expected 0 deleted, got %d", count)
+		}
+	})
+
+	t.Run("Unscoped", func(t *testing.T) {
+		unscoped := database.Unscoped(db)
+		var user ScopedUser
+		if err := unscoped.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}}, &user); err != nil {
+			t.Fatal("FindOne failed:", err)
+		}
+		if user.DeletedAt == nil {
+			t.Errorf("Expected the deleted_at is set, got %+v", user)
+		}
+		count, err := unscoped.Delete(ctx, "users", database.C{{Key: "id", Value: int64(1)}})
+		if err != nil || count != 1 {
+			t.Fatalf("Delete count %d error %v", count, err)
+		}
+		if total, _ := unscoped.Count(ctx, "users", nil); total != 1 {
+			t.Errorf("Expected 1 user, got %d", total)
+		}
+	})
+
+	t.Run("Transaction is scoped", func(t *testing.T) {
+		tx, err := db.StartTransaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.WithTransaction(ctx, tx).DeleteOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}}); err != nil {
+			t.Fatal("DeleteOne failed:", err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		if total, _ := db.Count(ctx, "users", nil); total != 0 {
+			t.Errorf("Expected 0 users, got %d", total)
+		}
+		if total, _ := database.Unscoped(db).Count(ctx, "users", nil); total != 1 {
+			t.Errorf("Expected 1 soft deleted user, got %d", total)
+		}
+	})
+
+	t.Run("Replace skips the soft deleted rows", func(t *testing.T) {
+		count, err := db.Replace(ctx, "users", []string{"id"},
+			[]*ScopedUser{{ID: 2, Name: "Bobby"}, {ID: 3, Name: "Carol"}})
+		if err != nil || count != 1 {
+			t.Fatalf("Replace count %d error %v", count, err)
+		}
+		var bob ScopedUser
+		if err = database.Unscoped(db).FindOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}}, &bob); err != nil {
+			t.Fatal("FindOne failed:", err)
+		}
+		if bob.Name != "Bob" || bob.DeletedAt == nil {
+			t.Errorf("Expected the soft deleted Bob is untouched, got %+v", bob)
+		}
+		if total, _ := db.Count(ctx, "users", nil); total != 1 {
+			t.Errorf("Expected 1 user, got %d", total)
+		}
+		count, err = db.Replace(ctx, "users", []string{"id"}, []*ScopedUser{{ID: 2, Name: "Bobby"}})
+		if err != nil || count != 0 {
+			t.Errorf("Expected nothing is replaced, got count %d error %v", count, err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package mock
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+// searchScore the count of the words of the Search condition in the words of the fields, a row matches
+// the condition if any word is found.
+func searchScore(row map[string]any, cond database.CE) int {
+	words := database.SearchTokens(cond.SearchText())
+	var score int
+	for _, field := range cond.SearchFields() {
+		value, ok := lookupValue(row, field)
+		if !ok || isNil(value) {
+			continue
+		}
+		fieldWords := database.SearchTokens(fmt.Sprint(value))
+		for _, word := range words {
+			if slices.Contains(fieldWords, word) {
+				score++
+			}
+		}
+	}
+	return score
+}
+
+// setSearchScores set the database.SearchScore of the rows if it is a sort key, the rows are the copies
+// of the table data.
+func setSearchScores(rows []map[string]any, conds database.C, sortBy []string) error {
+	if !database.HasSearchScore(sortBy) {
+		return nil
+	}
+	cond, ok := database.SearchCondition(conds)
+	if !ok {
+		return NewInvalidArgumentError("sort", database.SearchScore+" requires a search condition")
+	}
+	for _, row := range rows {
+		row[database.SearchScore] = searchScore(row, cond)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package mock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/database/mock"
+)
+
+type SearchItem struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	m, err := mock.New(ctx, "mock://local/search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []*SearchItem{
+		{Name: "Alice", Description: "likes go and rust"},
+		{Name: "Bob", Description: "likes Go"},
+		{Name: "Carol", Description: "likes java"},
+	}
+	for _, item := range items {
+		if err = m.InsertOne(ctx, "items", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := database.C{{Key: "name, description", Value: "Go, Rust!", C: database.Search}}
+	count, err := m.Count(ctx, "items", search)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 matches, got %d %v", count, err)
+	}
+	var found []*SearchItem
+	if err = m.Find(ctx, "items", search, []string{"-" + database.SearchScore}, 0, &found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Name != "Alice" || found[1].Name != "Bob" {
+		t.Fatalf("Expected Alice and Bob by relevance, got %v", found)
+	}
+	// the words are matched as a whole
+	exist, err := m.Exist(ctx, "items", database.C{{Key: "description", Value: "jav", C: database.Search}})
+	if err != nil || exist {
+		t.Fatalf("Expected no match of a partial word, got %v %v", exist, err)
+	}
+	if err = m.Find(ctx, "items", nil, []string{database.SearchScore}, 0, &found); err == nil {
+		t.Fatal("Expected an error of the score without a search condition")
+	}
+	_, err = mock.StreamQuery[SearchItem](ctx, m, "items", &database.StreamQueryRequest{
+		Filters: search,
+		Sort:    []string{"-" + database.SearchScore},
+	})
+	if err == nil {
+		t.Fatal("Expected an error of the stream query sorted by the score")
+	}
+}
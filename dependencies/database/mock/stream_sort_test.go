@@ -0,0 +1,65 @@
+package mock_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/database/mock"
+)
+
+type SortedItem struct {
+	Name      string    `json:"name"`
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func TestSortedStreamQuery(t *testing.T) {
+	ctx := context.Background()
+	m, err := mock.New(ctx, "mock://local/sortedstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		item := &SortedItem{Name: name, Score: i % 2, CreatedAt: created.Add(time.Duration(i) * time.Hour)}
+		if err = m.InsertOne(ctx, "items", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := func(sort ...string) string {
+		var names []string
+		in := &database.StreamQueryRequest{Sort: sort, Limit: 2}
+		for {
+			out, err := mock.StreamQuery[SortedItem](ctx, m, "items", in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Total != 5 {
+				t.Fatalf("Expected total 5, got %d", out.Total)
+			}
+			for _, item := range out.Data {
+				names = append(names, item.Name)
+			}
+			if out.PageToken == "" {
+				return strings.Join(names, "")
+			}
+			in.PageToken = out.PageToken
+		}
+	}
+	if names := stream("-score", "name"); names != "bdace" {
+		t.Errorf("Expected bdace, got %s", names)
+	}
+	if names := stream("-created_at"); names != "edcba" {
+		t.Errorf("Expected edcba, got %s", names)
+	}
+	_, err = mock.StreamQuery[SortedItem](ctx, m, "items", &database.StreamQueryRequest{
+		Sort:      []string{"name"},
+		PageToken: "invalid",
+	})
+	if err == nil {
+		t.Error("Expected the invalid page token fails")
+	}
+}
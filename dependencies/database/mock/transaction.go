@@ -2,47 +2,188 @@ package mock
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/ti/common-go/dependencies/database"
 )
 
-// mockTransaction implements database.Transaction interface
+// counterVersionPrefix the prefix of counter keys in the versions map, it never conflicts with table names.
+const counterVersionPrefix = "\x00counter:"
+
+// mockTransaction implements database.Transaction and database.AfterCommitter interfaces
+//
+// The transaction works on a copy-on-write snapshot of the tables and counters. Rows are never modified
+// in place, so the snapshot only copies the row references. On commit, the written tables and counters
+// are applied to the parent atomically, a table or counter changed by others since the snapshot was taken
+// aborts the commit. The unique indexes of the database are enforced in the snapshot and at the commit, the
+// transaction started in a transaction commits to the snapshot of the outer one.
 type mockTransaction struct {
-	mock       *Mock
+	database.CommitHooks
+	mu         sync.Mutex
+	parent     *Mock
+	snapshot   *Mock
+	base       map[string]uint64
+	written    map[string]bool
+	events     []database.ChangeEvent
 	committed  bool
 	rolledBack bool
 }
 
 // StartTransaction starts a new transaction
 func (m *Mock) StartTransaction(ctx context.Context) (database.Transaction, error) {
-	// For mock database, we don't really implement transactions
-	// Just return a mock transaction object
-	return &mockTransaction{
-		mock: m,
-	}, nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tx := &mockTransaction{
+		parent:  m,
+		base:    maps.Clone(m.versions),
+		written: make(map[string]bool),
+	}
+	tables := make(map[string]*table, len(m.tables))
+	for name, t := range m.tables {
+		tables[name] = &table{data: slices.Clone(t.data)}
+	}
+	tx.snapshot = &Mock{
+		tables:          tables,
+		defaultDatabase: m.defaultDatabase,
+		counters:        maps.Clone(m.counters),
+		versions:        maps.Clone(m.versions),
+		tx:              tx,
+	}
+	return tx, nil
 }
 
-// WithTransaction returns a database instance with the transaction
+// WithTransaction returns a database instance that reads and writes the snapshot of the transaction,
+// the calls of the database fail with an invalid argument error if the tx is not started by the mock.
 func (m *Mock) WithTransaction(ctx context.Context, tx database.Transaction) database.Database {
-	// For mock database, transactions don't isolate data
-	// Just return self
-	return m
+	mockTx, ok := tx.(*mockTransaction)
+	if !ok {
+		return database.Failed(NewInvalidArgumentError("tx", fmt.Sprintf("%T is not a transaction of the mock", tx)))
+	}
+	return mockTx.snapshot
+}
+
+// touch marks the table or counter as written, the caller must hold the lock.
+func (m *Mock) touch(key string) {
+	m.versions[key]++
+	if m.tx != nil {
+		m.tx.markWritten(key)
+	}
+}
+
+// touchIfChanged marks the table as written if any row is changed, it is deferred by the writes.
+func (m *Mock) touchIfChanged(tableName string, count *int) {
+	if *count > 0 {
+		m.touch(tableName)
+	}
 }
 
-// Commit commits the transaction
+// checkTransaction returns an error if the mock is the snapshot of a closed transaction,
+// the caller must hold the lock.
+func (m *Mock) checkTransaction() error {
+	if m.tx == nil {
+		return nil
+	}
+	m.tx.mu.Lock()
+	defer m.tx.mu.Unlock()
+	if m.tx.committed || m.tx.rolledBack {
+		return ErrTransactionClosed
+	}
+	return nil
+}
+
+func (t *mockTransaction) addEvent(event database.ChangeEvent) {
+	t.mu.Lock()
+	t.events = append(t.events, event)
+	t.mu.Unlock()
+}
+
+func (t *mockTransaction) markWritten(key string) {
+	t.mu.Lock()
+	t.written[key] = true
+	t.mu.Unlock()
+}
+
+// Commit applies the changes of the snapshot to the parent
 func (t *mockTransaction) Commit() error {
+	if _, err := t.parent.inject(context.Background(), "", FaultCommit); err != nil {
+		return err
+	}
+	if err := t.commit(); err != nil {
+		return err
+	}
+	t.Committed()
+	return nil
+}
+
+func (t *mockTransaction) commit() error {
+	// lock order: snapshot, transaction, parent, which is the same as the writes on the snapshot.
+	parent, snapshot := t.parent, t.snapshot
+	snapshot.mu.Lock()
+	defer snapshot.mu.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if t.rolledBack {
 		return NewTransactionError("commit", "transaction already rolled back")
 	}
 	if t.committed {
 		return NewTransactionError("commit", "transaction already committed")
 	}
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+
+	for key := range t.written {
+		if parent.versions[key] != t.base[key] {
+			// the transaction is closed, the caller should retry with a new transaction
+			t.rolledBack = true
+			return NewAbortedError(strings.TrimPrefix(key, counterVersionPrefix))
+		}
+	}
+	for key := range t.written {
+		if strings.HasPrefix(key, counterVersionPrefix) {
+			continue
+		}
+		// the unique indexes created after the writes of the transaction are checked too
+		if err := checkUnique(key, parent.uniqueIndexes(key), snapshot.tables[key].data, nil, nil); err != nil {
+			t.rolledBack = true
+			return err
+		}
+	}
+	for key := range t.written {
+		if counterKey, ok := strings.CutPrefix(key, counterVersionPrefix); ok {
+			if value, exists := snapshot.counters[counterKey]; exists {
+				parent.counters[counterKey] = value
+			} else {
+				delete(parent.counters, counterKey)
+			}
+		} else {
+			parent.tables[key] = &table{data: slices.Clone(snapshot.tables[key].data)}
+		}
+		parent.touch(key)
+	}
+	switch {
+	case parent.tx != nil:
+		// the events of a nested transaction are published by the commit of the outer transaction
+		for _, event := range t.events {
+			parent.tx.addEvent(event)
+		}
+	case parent.feed != nil:
+		for _, event := range t.events {
+			parent.feed.publish(event)
+		}
+	}
 	t.committed = true
 	return nil
 }
 
-// Rollback rolls back the transaction
+// Rollback discards the changes of the snapshot
 func (t *mockTransaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if t.committed {
 		return NewTransactionError("rollback", "transaction already committed")
 	}
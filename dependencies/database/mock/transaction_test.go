@@ -0,0 +1,287 @@
+package mock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTransactionIsolation(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/txtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	_ = db.InsertOne(ctx, "users", &TestUser{ID: 1, Name: "Alice", Age: 25})
+
+	t.Run("Rollback discards changes", func(t *testing.T) {
+		tx, _ := db.StartTransaction(ctx)
+		txDB := db.WithTransaction(ctx, tx)
+		_ = txDB.InsertOne(ctx, "users", &TestUser{ID: 2, Name: "Bob"})
+		_, _ = txDB.UpdateOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}},
+			database.D{{Key: "age", Value: 99}})
+		_ = txDB.IncrCounter(ctx, "stats", "users", 0, 1)
+
+		if exist, _ := txDB.Exist(ctx, "users", database.C{{Key: "id", Value: int64(2)}}); !exist {
+			t.Fatal("Insert should be visible inside the transaction")
+		}
+		if exist, _ := db.Exist(ctx, "users", database.C{{Key: "id", Value: int64(2)}}); exist {
+			t.Fatal("Insert should not be visible outside the transaction before commit")
+		}
+		var user TestUser
+		_ = db.FindOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}}, &user)
+		if user.Age != 25 {
+			t.Errorf("Expected age 25 outside the transaction, got %d", user.Age)
+		}
+
+		if err := tx.Rollback(); err != nil {
+			t.Fatal("Rollback failed:", err)
+		}
+		if count, _ := db.Count(ctx, "users", nil); count != 1 {
+			t.Errorf("Expected 1 user after rollback, got %d", count)
+		}
+		if value, _ := db.GetCounter(ctx, "stats", "users"); value != 0 {
+			t.Errorf("Expected counter 0 after rollback, got %d", value)
+		}
+		if err := txDB.InsertOne(ctx, "users", &TestUser{ID: 3}); err == nil {
+			t.Error("Expected error when writing to a closed transaction")
+		}
+	})
+
+	t.Run("Commit applies changes", func(t *testing.T) {
+		tx, _ := db.StartTransaction(ctx)
+		txDB := db.WithTransaction(ctx, tx)
+		_, _ = txDB.Delete(ctx, "users", database.C{{Key: "id", Value: int64(1)}})
+		_ = txDB.IncrCounter(ctx, "stats", "deleted", 0, 1)
+		if err := tx.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		if count, _ := db.Count(ctx, "users", nil); count != 0 {
+			t.Errorf("Expected 0 users after commit, got %d", count)
+		}
+		if value, _ := db.GetCounter(ctx, "stats", "deleted"); value != 1 {
+			t.Errorf("Expected counter 1 after commit, got %d", value)
+		}
+	})
+
+	t.Run("Conflicting commit is aborted", func(t *testing.T) {
+		tx1, _ := db.StartTransaction(ctx)
+		tx2, _ := db.StartTransaction(ctx)
+		_ = db.WithTransaction(ctx, tx1).InsertOne(ctx, "orders", &TestUser{ID: 1})
+		_ = db.WithTransaction(ctx, tx2).InsertOne(ctx, "orders", &TestUser{ID: 2})
+		_ = db.WithTransaction(ctx, tx2).InsertOne(ctx, "items", &TestUser{ID: 2})
+		if err := tx1.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		err := tx2.Commit()
+		if status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected aborted error, got %v", err)
+		}
+		if count, _ := db.Count(ctx, "orders", nil); count != 1 {
+			t.Errorf("Expected 1 order, got %d", count)
+		}
+		if count, _ := db.Count(ctx, "items", nil); count != 0 {
+			t.Errorf("Expected the aborted transaction to apply nothing, got %d items", count)
+		}
+	})
+}
+
+// foreignTx a transaction which is not started by the mock
+type foreignTx struct{}
+
+func (foreignTx) Commit() error { return nil }
+
+func (foreignTx) Rollback() error { return nil }
+
+func TestForeignTransaction(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/foreigntx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	txDB := db.WithTransaction(ctx, foreignTx{})
+	if err = txDB.InsertOne(ctx, "users", &TestUser{ID: 1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+	if _, err = txDB.Count(ctx, "users", nil); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+	if count, _ := db.Count(ctx, "users", nil); count != 0 {
+		t.Errorf("Expected nothing written, got %d users", count)
+	}
+}
+
+func TestRunInTransaction(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/runtx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	users := database.NewRepository[TestUser](db, "users")
+
+	t.Run("Commit on success", func(t *testing.T) {
+		err := database.RunInTransaction(ctx, db, func(ctx context.Context, tx database.Database) error {
+			// the repository writes in the transaction of the ctx
+			if _, err := users.Insert(ctx, &TestUser{ID: 1, Name: "Alice"}); err != nil {
+				return err
+			}
+			if exist, _ := db.Exist(context.Background(), "users", database.C{{Key: "id", Value: int64(1)}}); exist {
+				t.Error("Insert should not be visible outside the transaction before commit")
+			}
+			return tx.IncrCounter(ctx, "stats", "users", 0, 1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count, _ := users.Count(ctx, nil); count != 1 {
+			t.Errorf("Expected 1 user after commit, got %d", count)
+		}
+	})
+
+	t.Run("Rollback on error and panic", func(t *testing.T) {
+		errFailed := status.Error(codes.FailedPrecondition, "failed")
+		err := database.RunInTransaction(ctx, db, func(ctx context.Context, tx database.Database) error {
+			_ = tx.InsertOne(ctx, "users", &TestUser{ID: 2})
+			return errFailed
+		})
+		if err != errFailed {
+			t.Fatalf("Expected the error of the fn, got %v", err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected the panic to be raised again")
+				}
+			}()
+			_ = database.RunInTransaction(ctx, db, func(ctx context.Context, tx database.Database) error {
+				_ = tx.InsertOne(ctx, "users", &TestUser{ID: 3})
+				panic("failed")
+			})
+		}()
+		if count, _ := db.Count(ctx, "users", nil); count != 1 {
+			t.Errorf("Expected 1 user after rollback, got %d", count)
+		}
+	})
+
+	t.Run("Retry aborted transaction", func(t *testing.T) {
+		var attempts int
+		err := database.RunInTransaction(ctx, db, func(ctx context.Context, tx database.Database) error {
+			attempts++
+			if _, err := tx.UpdateOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}},
+				database.D{{Key: "age", Value: attempts}}); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				// a conflicting commit of another transaction
+				_, err := db.UpdateOne(ctx, "users", database.C{{Key: "id", Value: int64(1)}},
+					database.D{{Key: "name", Value: "Bob"}})
+				return err
+			}
+			return nil
+		}, database.WithBackoff(time.Millisecond, time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 2 {
+			t.Fatalf("Expected 2 attempts, got %d", attempts)
+		}
+		user, _ := users.FindOne(ctx, database.C{{Key: "id", Value: int64(1)}})
+		if user == nil || user.Age != 2 || user.Name != "Bob" {
+			t.Errorf("Unexpected user after retry %+v", user)
+		}
+	})
+
+	t.Run("Give up after max retries", func(t *testing.T) {
+		var attempts int
+		err := database.RunInTransaction(ctx, db, func(context.Context, database.Database) error {
+			attempts++
+			return status.Error(codes.Aborted, "aborted")
+		}, database.WithMaxRetries(2), database.WithBackoff(time.Millisecond, time.Millisecond))
+		if status.Code(err) != codes.Aborted || attempts != 3 {
+			t.Fatalf("Expected 3 aborted attempts, got %d %v", attempts, err)
+		}
+	})
+
+	t.Run("Nested transaction joins the outer one", func(t *testing.T) {
+		err := database.RunInTransaction(ctx, db, func(ctx context.Context, tx database.Database) error {
+			return database.RunInTransaction(ctx, db, func(ctx context.Context, nested database.Database) error {
+				if nested != tx {
+					t.Error("Expected the nested transaction to join the outer one")
+				}
+				return nested.InsertOne(ctx, "users", &TestUser{ID: 4})
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exist, _ := db.Exist(ctx, "users", database.C{{Key: "id", Value: int64(4)}}); !exist {
+			t.Error("Expected the nested insert to be committed")
+		}
+	})
+}
+
+func TestTransactionUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/txunique")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	unique := &database.Index{Name: "uid_name", Fields: []string{"name"}, Unique: true}
+	if err = database.CreateIndex(ctx, db, "users", unique); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.InsertOne(ctx, "users", &TestUser{ID: 1, Name: "Alice"})
+	if err = db.InsertOne(ctx, "users", &TestUser{ID: 2, Name: "Alice"}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("Expected already exists, got %v", err)
+	}
+
+	tx, _ := db.StartTransaction(ctx)
+	txDB := db.WithTransaction(ctx, tx)
+	if err = txDB.InsertOne(ctx, "users", &TestUser{ID: 2, Name: "Alice"}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("Expected already exists in the transaction, got %v", err)
+	}
+	_ = txDB.InsertOne(ctx, "users", &TestUser{ID: 2, Name: "Bob"})
+	_, err = txDB.UpdateOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}},
+		database.D{{Key: "name", Value: "Alice"}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("Expected already exists of the update, got %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal("Commit failed:", err)
+	}
+
+	// the unique index created after the writes of the transaction fails the commit
+	tx, _ = db.StartTransaction(ctx)
+	txDB = db.WithTransaction(ctx, tx)
+	_, _ = txDB.Insert(ctx, "orders", []*TestUser{{ID: 1, Name: "x"}, {ID: 2, Name: "x"}})
+	if err = database.CreateIndex(ctx, db, "orders", &database.Index{Name: "uid_name", Fields: []string{"name"},
+		Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("Expected already exists of the commit, got %v", err)
+	}
+	if count, _ := db.Count(ctx, "orders", nil); count != 0 {
+		t.Errorf("Expected nothing committed, got %d orders", count)
+	}
+	if count, _ := db.Count(ctx, "users", nil); count != 2 {
+		t.Errorf("Expected 2 users, got %d", count)
+	}
+}
@@ -0,0 +1,80 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type VersionedUser struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version" db:"version"`
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "mock://local/versiontest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	if err = db.InsertOne(ctx, "users", &VersionedUser{ID: 1, Name: "Alice"}); err != nil {
+		t.Fatal("InsertOne failed:", err)
+	}
+	byID := database.C{{Key: "id", Value: int64(1)}}
+
+	// two replicas load the same version
+	var first, second VersionedUser
+	_ = db.FindOne(ctx, "users", byID, &first)
+	_ = db.FindOne(ctx, "users", byID, &second)
+
+	first.Name = "Alicia"
+	if _, err = db.UpdateOne(ctx, "users", byID, &first); err != nil {
+		t.Fatal("UpdateOne failed:", err)
+	}
+	if first.Version != 1 {
+		t.Errorf("Expected version 1 after the update, got %d", first.Version)
+	}
+
+	second.Name = "Alison"
+	_, err = db.UpdateOne(ctx, "users", byID, &second)
+	if !errors.Is(err, database.ErrVersionConflict) || status.Code(err) != codes.Aborted {
+		t.Fatalf("Expected the version conflict, got %v", err)
+	}
+	if second.Version != 0 {
+		t.Errorf("Expected the version is reset to 0, got %d", second.Version)
+	}
+
+	t.Run("ReplaceOne", func(t *testing.T) {
+		_, err := db.ReplaceOne(ctx, "users", byID, &second)
+		if !errors.Is(err, database.ErrVersionConflict) {
+			t.Fatalf("Expected the version conflict, got %v", err)
+		}
+		// reload and retry
+		_ = db.FindOne(ctx, "users", byID, &second)
+		second.Name = "Alison"
+		if _, err = db.ReplaceOne(ctx, "users", byID, &second); err != nil {
+			t.Fatal("ReplaceOne failed:", err)
+		}
+		var user VersionedUser
+		_ = db.FindOne(ctx, "users", byID, &user)
+		if user.Name != "Alison" || user.Version != 2 {
+			t.Errorf("Expected Alison of version 2, got %+v", user)
+		}
+	})
+
+	t.Run("Versioned ReplaceOne never inserts", func(t *testing.T) {
+		_, err := db.ReplaceOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}}, &VersionedUser{ID: 2})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Expected not found, got %v", err)
+		}
+	})
+}
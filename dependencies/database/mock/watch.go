@@ -0,0 +1,201 @@
+package mock
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"sync"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+// maxChangeLog the number of the latest changes kept for resuming
+const maxChangeLog = 1024
+
+// changeFeed fans out the writes of the mock to the watchers
+type changeFeed struct {
+	mu          sync.Mutex
+	seq         uint64
+	log         []database.ChangeEvent
+	subscribers map[*subscriber]bool
+	closed      bool
+}
+
+// subscriber queues the events, so the writes never wait for the slow consumers
+type subscriber struct {
+	mu     sync.Mutex
+	table  string
+	filter database.C
+	queue  []database.ChangeEvent
+	notify chan struct{}
+	done   chan struct{}
+}
+
+// Watch implements database.Watcher, the resume token is the sequence of the event,
+// only the latest 1024 events can be resumed.
+func (m *Mock) Watch(ctx context.Context, tableName string, filter database.C,
+	opts ...database.WatchOption,
+) (<-chan database.ChangeEvent, error) {
+	o := database.NewWatchOptions(opts...)
+	var after uint64
+	if o.ResumeToken != "" {
+		var err error
+		if after, err = strconv.ParseUint(o.ResumeToken, 10, 64); err != nil {
+			return nil, NewInvalidArgumentError("resume_token", "invalid resume token")
+		}
+	}
+	m.mu.RLock()
+	feed := m.feed
+	m.mu.RUnlock()
+	if feed == nil {
+		return nil, NewInvalidOperationError("watch", "database is closed")
+	}
+
+	sub := &subscriber{
+		table:  tableName,
+		filter: filter,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	feed.mu.Lock()
+	if feed.closed {
+		feed.mu.Unlock()
+		return nil, NewInvalidOperationError("watch", "database is closed")
+	}
+	if o.ResumeToken != "" {
+		oldest := feed.seq + 1
+		if len(feed.log) > 0 {
+			oldest = seqOf(feed.log[0])
+		}
+		if after > feed.seq || after+1 < oldest {
+			feed.mu.Unlock()
+			return nil, NewInvalidArgumentError("resume_token", "the resume token has expired")
+		}
+		for _, event := range feed.log {
+			if seqOf(event) > after {
+				sub.push(event)
+			}
+		}
+	}
+	feed.subscribers[sub] = true
+	feed.mu.Unlock()
+
+	out := make(chan database.ChangeEvent, o.BufferSize)
+	go func() {
+		defer close(out)
+		defer feed.unsubscribe(sub)
+		for {
+			for _, event := range sub.pop() {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-sub.notify:
+			case <-sub.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func seqOf(event database.ChangeEvent) uint64 {
+	seq, _ := strconv.ParseUint(event.ResumeToken, 10, 64)
+	return seq
+}
+
+func (s *subscriber) push(event database.ChangeEvent) {
+	if event.Table != s.table {
+		return
+	}
+	if event.Op != database.OpDelete && len(s.filter) > 0 && !matchConditions(event.Document, s.filter) {
+		return
+	}
+	s.mu.Lock()
+	s.queue = append(s.queue, event)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) pop() []database.ChangeEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.queue
+	s.queue = nil
+	return events
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		subscribers: make(map[*subscriber]bool),
+	}
+}
+
+func (f *changeFeed) publish(event database.ChangeEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.seq++
+	event.ResumeToken = strconv.FormatUint(f.seq, 10)
+	f.log = append(f.log, event)
+	if len(f.log) > maxChangeLog {
+		f.log = f.log[len(f.log)-maxChangeLog:]
+	}
+	for sub := range f.subscribers {
+		sub.push(event)
+	}
+}
+
+func (f *changeFeed) unsubscribe(sub *subscriber) {
+	f.mu.Lock()
+	delete(f.subscribers, sub)
+	f.mu.Unlock()
+}
+
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for sub := range f.subscribers {
+		close(sub.done)
+		delete(f.subscribers, sub)
+	}
+}
+
+// emit publishes the change of the row, the changes of a transaction are published when it is committed.
+// the caller must hold the lock.
+func (m *Mock) emit(tableName string, op database.ChangeOp, row map[string]any) {
+	event := database.ChangeEvent{
+		Op:    op,
+		Table: tableName,
+		Key:   rowKey(row),
+	}
+	if op != database.OpDelete {
+		event.Document = maps.Clone(row)
+	}
+	if m.tx != nil {
+		m.tx.addEvent(event)
+		return
+	}
+	if m.feed != nil {
+		m.feed.publish(event)
+	}
+}
+
+// rowKey the key of the row, which is the id or _id field
+func rowKey(row map[string]any) any {
+	if key, ok := row["id"]; ok {
+		return key
+	}
+	return row["_id"]
+}
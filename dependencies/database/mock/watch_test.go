@@ -0,0 +1,117 @@
+package mock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+)
+
+func receiveEvent(t *testing.T, events <-chan database.ChangeEvent) database.ChangeEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		if event.Err != nil {
+			t.Fatal("Watch failed:", event.Err)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the change event")
+		return database.ChangeEvent{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, err := database.New(ctx, "mock://local/watchtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+	watcher := db.(database.Watcher)
+	events, err := watcher.Watch(ctx, "users", database.C{{Key: "age", Value: 18, C: database.Gte}})
+	if err != nil {
+		t.Fatal("Watch failed:", err)
+	}
+
+	_ = db.InsertOne(ctx, "users", &TestUser{ID: 1, Name: "Kid", Age: 10})
+	_ = db.InsertOne(ctx, "users", &TestUser{ID: 2, Name: "Alice", Age: 20})
+	_ = db.InsertOne(ctx, "orders", &TestUser{ID: 3, Age: 30})
+	_, _ = db.UpdateOne(ctx, "users", database.C{{Key: "id", Value: int64(2)}}, database.D{{Key: "age", Value: 21}})
+	_, _ = db.Delete(ctx, "users", database.C{{Key: "id", Value: int64(2)}})
+
+	inserted := receiveEvent(t, events)
+	if inserted.Op != database.OpInsert || inserted.Key != int64(2) || inserted.Document["name"] != "Alice" {
+		t.Errorf("Unexpected insert event %+v", inserted)
+	}
+	updated := receiveEvent(t, events)
+	if updated.Op != database.OpUpdate || updated.Document["age"] != 21 {
+		t.Errorf("Unexpected update event %+v", updated)
+	}
+	deleted := receiveEvent(t, events)
+	if deleted.Op != database.OpDelete || deleted.Key != int64(2) || deleted.Document != nil {
+		t.Errorf("Unexpected delete event %+v", deleted)
+	}
+
+	t.Run("Resume after the token", func(t *testing.T) {
+		resumed, err := watcher.Watch(ctx, "users", nil, database.WithResumeToken(inserted.ResumeToken))
+		if err != nil {
+			t.Fatal("Watch failed:", err)
+		}
+		if event := receiveEvent(t, resumed); event.Op != database.OpUpdate {
+			t.Errorf("Expected the update event after the token, got %+v", event)
+		}
+		if event := receiveEvent(t, resumed); event.Op != database.OpDelete {
+			t.Errorf("Expected the delete event after the token, got %+v", event)
+		}
+	})
+
+	t.Run("Transaction publishes on commit", func(t *testing.T) {
+		tx, _ := db.StartTransaction(ctx)
+		_ = db.WithTransaction(ctx, tx).InsertOne(ctx, "users", &TestUser{ID: 4, Age: 40})
+		select {
+		case event := <-events:
+			t.Fatalf("Unexpected event before commit %+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		if event := receiveEvent(t, events); event.Key != int64(4) {
+			t.Errorf("Unexpected event after commit %+v", event)
+		}
+	})
+
+	t.Run("Nested transaction publishes on the outer commit", func(t *testing.T) {
+		tx, _ := db.StartTransaction(ctx)
+		txDB := db.WithTransaction(ctx, tx)
+		nested, err := txDB.StartTransaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = txDB.WithTransaction(ctx, nested).InsertOne(ctx, "users", &TestUser{ID: 5, Age: 50})
+		if err = nested.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		select {
+		case event := <-events:
+			t.Fatalf("Unexpected event before the outer commit %+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal("Commit failed:", err)
+		}
+		if event := receiveEvent(t, events); event.Key != int64(5) {
+			t.Errorf("Unexpected event after commit %+v", event)
+		}
+	})
+
+	cancel()
+	for range events {
+	}
+}
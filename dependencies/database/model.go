@@ -1,6 +1,12 @@
 package database
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
 
 // Transaction a Transaction interface
 type Transaction interface {
@@ -56,8 +62,113 @@ const (
 	In
 	// Nin Not in [a,b,c]
 	Nin
+	// Like case-insensitive pattern match, % matches any characters and _ matches one character, the
+	// wildcards are escaped by a backslash, for exp: "abc%" is a prefix match and "%abc%" is a contains match.
+	// MySQL compares by the collation of the column, which is case-insensitive by default.
+	Like
+	// Regex regular expression match
+	Regex
+	// Exists the field is not null when the value is true, or is null when the value is false
+	Exists
+	// Between [a, b] inclusive, the value is a slice of two elements
+	Between
+	// ArrayContains the array field contains the value
+	ArrayContains
+	// JSONMatch the json field matches all key values of the value map, for exp:
+	// {Key: "profile", Value: map[string]any{"city": "sz"}, C: JSONMatch} is the same as profile.city = "sz"
+	JSONMatch
+	// Or any of the conditions, the value is a C
+	Or
+	// And all of the conditions, the value is a C, it is used to nest AND groups in an Or group
+	And
+	// Not none of the conditions, the value is a C, which means NOT (a AND b)
+	Not
+	// Search full-text search of the text value in the fields of the key, the fields are separated by commas,
+	// for exp: {Key: "name,description", Value: "alice", C: Search}, see SearchScore for the relevance.
+	Search
 )
 
+// IsGroup check if the condition is an Or, And or Not group
+func (c Condition) IsGroup() bool {
+	return c == Or || c == And || c == Not
+}
+
+// Group returns the sub conditions of an Or, And or Not group
+func (c CE) Group() C {
+	switch v := c.Value.(type) {
+	case C:
+		return v
+	case []CE:
+		return v
+	case CE:
+		return C{v}
+	default:
+		return nil
+	}
+}
+
+// ShouldExist returns the expectation of an Exists condition, any value other than false means exists.
+func (c CE) ShouldExist() bool {
+	v, ok := c.Value.(bool)
+	return !ok || v
+}
+
+// Range returns the lower and upper bounds of a Between condition
+func (c CE) Range() (from, to any, err error) {
+	v := reflect.ValueOf(c.Value)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != 2 {
+		return nil, nil, fmt.Errorf("the value of %s between must be a slice of two elements", c.Key)
+	}
+	return v.Index(0).Interface(), v.Index(1).Interface(), nil
+}
+
+// JSONMatches returns the equal conditions of a JSONMatch condition, the keys are sorted.
+func (c CE) JSONMatches() C {
+	v := reflect.ValueOf(c.Value)
+	if v.Kind() != reflect.Map {
+		return nil
+	}
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+	})
+	conds := make(C, len(keys))
+	for i, k := range keys {
+		conds[i] = CE{
+			Key:   fmt.Sprintf("%s.%v", c.Key, k.Interface()),
+			Value: v.MapIndex(k).Interface(),
+		}
+	}
+	return conds
+}
+
+// LikeToRegex converts the pattern of a Like condition to an anchored case-insensitive regular expression,
+// the wildcards can be escaped by a backslash.
+func LikeToRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	escaped := false
+	for _, r := range pattern {
+		if escaped {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
 // BulkError error for bulk update
 type BulkError struct {
 	Elements []*BulkElement
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/ti/common-go/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// operationSeconds the histogram of the duration of the database operations
+var operationSeconds = prom.NewHistogramVec(prom.HistogramOpts{
+	Name:    "db_operation_seconds",
+	Help:    "Histogram of the latency (seconds) of the database operations.",
+	Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+}, []string{"backend", "table", "op"})
+
+func init() {
+	prom.MustRegister(operationSeconds)
+}
+
+// the uri queries of the Observer
+const (
+	slowQueryKey = "slowQuery"
+	explainKey   = "explain"
+	metricsKey   = "metrics"
+)
+
+// explainTimeout the timeout of the explain of a slow operation
+const explainTimeout = 5 * time.Second
+
+// Observer records the duration of the operations to the db_operation_seconds histogram, and logs the slow
+// operations by log.Extract(ctx). It is configured by the queries of the uri:
+//
+//   - slowQuery=200ms logs the operations which take longer than the duration
+//   - explain=true attaches the query plan to the log of a slow operation
+//   - metrics=true records the histogram
+type Observer struct {
+	// Backend the backend label of the histogram, which is sql or mongo
+	Backend string
+	// SlowQuery the threshold of the slow operations, the slow operations are not logged if it is zero
+	SlowQuery time.Duration
+	// Explain attach the query plan to the log of a slow operation
+	Explain bool
+	// Metrics record the histogram
+	Metrics bool
+}
+
+// ParseObserver returns the observer of the uri and removes its queries, it returns nil if there is nothing
+// to observe.
+func ParseObserver(backend string, u *url.URL) (*Observer, error) {
+	query := u.Query()
+	o := &Observer{
+		Backend: backend,
+		Explain: query.Get(explainKey) == "true",
+		Metrics: query.Get(metricsKey) == "true",
+	}
+	if slowQuery := query.Get(slowQueryKey); slowQuery != "" {
+		var err error
+		if o.SlowQuery, err = time.ParseDuration(slowQuery); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid slowQuery %s", slowQuery)
+		}
+	}
+	if query.Has(slowQueryKey) || query.Has(explainKey) || query.Has(metricsKey) {
+		query.Del(slowQueryKey)
+		query.Del(explainKey)
+		query.Del(metricsKey)
+		u.RawQuery = query.Encode()
+	}
+	if !o.Metrics && o.SlowQuery == 0 {
+		return nil, nil
+	}
+	return o, nil
+}
+
+// Observe records the operation which started at the start. The explain returns the query plan of a slow
+// operation when the Explain is set, it can be nil, the slow operation is logged after the plan is explained.
+// The explain runs in background with a timeout, so it must not use the transaction of the operation.
+// It is a no-op on a nil observer.
+func (o *Observer) Observe(ctx context.Context, table, op string, conds C, start time.Time,
+	explain func(ctx context.Context) (string, error),
+) {
+	if o == nil {
+		return
+	}
+	used := time.Since(start)
+	if o.Metrics {
+		operationSeconds.WithLabelValues(o.Backend, table, op).Observe(used.Seconds())
+	}
+	if o.SlowQuery == 0 || used < o.SlowQuery {
+		return
+	}
+	fields := map[string]any{
+		"action":    o.Backend + ".slowQuery",
+		"table":     table,
+		"op":        op,
+		"condition": conds.String(),
+		"duration":  used.String(),
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		fields["trace_id"] = span.TraceID().String()
+	}
+	if !o.Explain || explain == nil {
+		log.Extract(ctx).With(fields).Warn("slow %s %s of %s took %s", o.Backend, op, table, used)
+		return
+	}
+	// the query plan is explained in background, the connection of the operation may be held by its
+	// rows until they are closed by the caller.
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), explainTimeout)
+		defer cancel()
+		plan, err := explain(ctx)
+		if err != nil {
+			plan = "explain error: " + err.Error()
+		}
+		fields["explain"] = plan
+		log.Extract(ctx).With(fields).Warn("slow %s %s of %s took %s", o.Backend, op, table, used)
+	}()
+}
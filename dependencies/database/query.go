@@ -21,6 +21,10 @@ type PageQueryResponse[T any] struct {
 type StreamQueryRequest struct {
 	PageToken string `json:"page_token,omitempty"`
 	PageField string
+	// Sort the stream by several keys, ["-created_at", "score"] means created_at DESC, score ASC, the
+	// PageField and Ascending are ignored if it is set. The page token holds the sort values of the last row,
+	// and the primary key of sql and mongo breaks the ties, the sort keys must be unique in the mock.
+	Sort      []string `json:"sort,omitempty"`
 	Filters   C        `json:"filter,omitempty"`
 	Select    []string `json:"select,omitempty"`
 	Limit     int      `json:"limit,omitempty"`
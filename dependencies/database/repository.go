@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"iter"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Repository the typed access to one table, T is the model type of the table, for exp:
+//
+//	users := database.NewRepository[User](db, "users")
+//	user, err := users.FindOne(ctx, database.C{{Key: "id", Value: 1}})
+type Repository[T any] struct {
+	db    Database
+	table string
+	opts  *RepositoryOptions
+}
+
+// RepositoryOptions the options of the repository
+type RepositoryOptions struct {
+	// IDGenerator generates the ids of the inserted docs whose id fields are zero, see [IDOf].
+	IDGenerator IDGenerator
+}
+
+// RepositoryOption the option of the repository
+type RepositoryOption func(*RepositoryOptions)
+
+// WithIDGenerator set the generator of the ids of the inserted docs, so the table gets the ids of the
+// generator rather than the ids of the database, for exp:
+//
+//	users := database.NewRepository[User](db, "users", database.WithIDGenerator(snowflake.ID))
+func WithIDGenerator(generator IDGenerator) RepositoryOption {
+	return func(o *RepositoryOptions) {
+		o.IDGenerator = generator
+	}
+}
+
+// NewRepository new a repository of the table.
+func NewRepository[T any](db Database, table string, opts ...RepositoryOption) *Repository[T] {
+	if d, ok := db.(*DB); ok {
+		// use the implementation directly, so the optional interfaces can be asserted
+		db = d.Database
+	}
+	o := &RepositoryOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Repository[T]{
+		db:    db,
+		table: table,
+		opts:  o,
+	}
+}
+
+// Table the table name of the repository
+func (r *Repository[T]) Table() string {
+	return r.table
+}
+
+// Database the underlying database of the repository
+func (r *Repository[T]) Database() Database {
+	return r.db
+}
+
+// dbOf the database of the operations with the ctx, it is bound to the transaction of RunInTransaction in
+// the ctx, see [TxFromContext].
+func (r *Repository[T]) dbOf(ctx context.Context) Database {
+	return TxFromContext(ctx, r.db)
+}
+
+// WithTransaction returns a repository which reads and writes in the transaction.
+func (r *Repository[T]) WithTransaction(ctx context.Context, tx Transaction) *Repository[T] {
+	return &Repository[T]{
+		db:    r.db.WithTransaction(ctx, tx),
+		table: r.table,
+		opts:  r.opts,
+	}
+}
+
+// Insert the docs, an empty docs inserts nothing. The ids of the docs are filled after the insert, or
+// generated before the insert by the IDGenerator of the options.
+func (r *Repository[T]) Insert(ctx context.Context, docs ...*T) (int, error) {
+	if r.opts.IDGenerator != nil {
+		GenerateIDs(docs, r.opts.IDGenerator)
+	}
+	switch len(docs) {
+	case 0:
+		return 0, nil
+	case 1:
+		if err := r.dbOf(ctx).InsertOne(ctx, r.table, docs[0]); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	default:
+		return r.dbOf(ctx).Insert(ctx, r.table, docs)
+	}
+}
+
+// FindOne find the first doc matched the conditions.
+func (r *Repository[T]) FindOne(ctx context.Context, conds C) (*T, error) {
+	data := new(T)
+	if err := r.dbOf(ctx).FindOne(ctx, r.table, conds, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Find the docs, sortBy ["age"] means age ASC, ["-age"] means age DESC.
+func (r *Repository[T]) Find(ctx context.Context, conds C, sortBy []string, limit int) ([]*T, error) {
+	var data []*T
+	if err := r.dbOf(ctx).Find(ctx, r.table, conds, sortBy, limit, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Update all docs matched the conditions, use UpdateFields to update some fields only.
+func (r *Repository[T]) Update(ctx context.Context, conds C, doc *T) (int, error) {
+	return r.dbOf(ctx).Update(ctx, r.table, conds, doc)
+}
+
+// UpdateFields update the fields of the doc of all docs matched the conditions, the encrypted fields of T
+// are encrypted, see [EncryptDocument].
+func (r *Repository[T]) UpdateFields(ctx context.Context, conds C, doc D) (int, error) {
+	doc, err := EncryptDocument(doc, new(T))
+	if err != nil {
+		return 0, err
+	}
+	return r.dbOf(ctx).Update(ctx, r.table, conds, doc)
+}
+
+// UpdateOne update the first doc matched the conditions.
+func (r *Repository[T]) UpdateOne(ctx context.Context, conds C, doc *T) (int, error) {
+	return r.dbOf(ctx).UpdateOne(ctx, r.table, conds, doc)
+}
+
+// BulkWrite the insert, update, upsert and delete models, the encrypted fields of T in the [D] of the
+// update models are encrypted, see [EncryptDocument].
+func (r *Repository[T]) BulkWrite(ctx context.Context, models []WriteModel, opts ...BulkWriteOption,
+) (*BulkWriteResult, error) {
+	if r.opts.IDGenerator != nil {
+		for _, v := range models {
+			if v.Op == WriteInsert {
+				GenerateIDs(v.Doc, r.opts.IDGenerator)
+			}
+		}
+	}
+	if EncryptedFields(new(T)) != nil {
+		encrypted := make([]WriteModel, len(models))
+		for i, v := range models {
+			if doc, ok := v.Doc.(D); ok && v.Op == WriteUpdate {
+				var err error
+				if v.Doc, err = EncryptDocument(doc, new(T)); err != nil {
+					return nil, err
+				}
+			}
+			encrypted[i] = v
+		}
+		models = encrypted
+	}
+	return BulkWrite(ctx, r.dbOf(ctx), r.table, models, opts...)
+}
+
+// Delete all docs matched the conditions.
+func (r *Repository[T]) Delete(ctx context.Context, conds C) (int, error) {
+	return r.dbOf(ctx).Delete(ctx, r.table, conds)
+}
+
+// Count the docs matched the conditions.
+func (r *Repository[T]) Count(ctx context.Context, conds C) (int64, error) {
+	return r.dbOf(ctx).Count(ctx, r.table, conds)
+}
+
+// Aggregate group the docs and compute the aggregate functions.
+func (r *Repository[T]) Aggregate(ctx context.Context, in *AggregateRequest) (*AggregateResponse, error) {
+	return Aggregate(ctx, r.dbOf(ctx), r.table, in)
+}
+
+// Exist check if any doc matched the conditions.
+func (r *Repository[T]) Exist(ctx context.Context, conds C) (bool, error) {
+	return r.dbOf(ctx).Exist(ctx, r.table, conds)
+}
+
+// PageQuery query the docs by page, the database must implement [PageQuerier].
+func (r *Repository[T]) PageQuery(ctx context.Context, in *PageQueryRequest) (*PageQueryResponse[T], error) {
+	db := r.dbOf(ctx)
+	q, ok := db.(PageQuerier)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "PageQuery unimplemented for %s",
+			reflect.TypeOf(db).String())
+	}
+	out := &PageQueryResponse[T]{}
+	if err := q.DoPageQuery(ctx, r.table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StreamQuery query the docs by page token, the database must implement [StreamQuerier].
+func (r *Repository[T]) StreamQuery(ctx context.Context, in *StreamQueryRequest) (*StreamResponse[T], error) {
+	db := r.dbOf(ctx)
+	q, ok := db.(StreamQuerier)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "StreamQuery unimplemented for %s",
+			reflect.TypeOf(db).String())
+	}
+	out := &StreamResponse[T]{}
+	if err := q.DoStreamQuery(ctx, r.table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Iterate the docs one by one with FindRows, the rows are closed when the loop ends, for exp:
+//
+//	for user, err := range users.Iterate(ctx, nil, []string{"-id"}, 0) {
+//		if err != nil {
+//			return err
+//		}
+//	}
+func (r *Repository[T]) Iterate(ctx context.Context, conds C, sortBy []string, limit int) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		rows, err := r.dbOf(ctx).FindRows(ctx, r.table, conds, sortBy, limit, new(T))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		// the rows of RowScanner are scanned into the *T, the others are decoded as the *T
+		scanner, scan := rows.(RowScanner)
+		for rows.Next() {
+			var data *T
+			if scan {
+				data = new(T)
+				err = scanner.Scan(data)
+			} else {
+				var row any
+				if row, err = rows.Decode(); err == nil {
+					var ok bool
+					if data, ok = row.(*T); !ok {
+						yield(nil, status.Errorf(codes.Internal, "the row of %s is %T, not %T", r.table, row, data))
+						return
+					}
+				}
+			}
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if !yield(data, nil) {
+				return
+			}
+		}
+	}
+}
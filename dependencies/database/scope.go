@@ -0,0 +1,534 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ScopeOptions the options of the soft delete and timestamps decorator, an empty field name disables the feature.
+type ScopeOptions struct {
+	// SoftDelete the field which is set to the delete time instead of deleting the row,
+	// it must be nullable, for exp: DeletedAt *timestamppb.Timestamp `json:"deleted_at"`.
+	SoftDelete string
+	// CreatedAt the field which is set to the insert time if it is zero.
+	CreatedAt string
+	// UpdatedAt the field which is set to the insert, update and replace time.
+	UpdatedAt string
+}
+
+// the uri query keys of ScopeOptions
+const (
+	softDeleteKey = "softDelete"
+	createdAtKey  = "createdAt"
+	updatedAtKey  = "updatedAt"
+)
+
+// parseScopeOptions parse and remove the ScopeOptions from the uri query,
+// for exp: ?softDelete=deleted_at&createdAt=created_at&updatedAt=updated_at
+func parseScopeOptions(u *url.URL) (opts ScopeOptions, ok bool) {
+	query := u.Query()
+	if !query.Has(softDeleteKey) && !query.Has(createdAtKey) && !query.Has(updatedAtKey) {
+		return opts, false
+	}
+	opts = ScopeOptions{
+		SoftDelete: query.Get(softDeleteKey),
+		CreatedAt:  query.Get(createdAtKey),
+		UpdatedAt:  query.Get(updatedAtKey),
+	}
+	query.Del(softDeleteKey)
+	query.Del(createdAtKey)
+	query.Del(updatedAtKey)
+	u.RawQuery = query.Encode()
+	return opts, true
+}
+
+// Unscoper is implemented by the databases which filter or stamp the rows, Unscoped returns the database
+// without the filters and stamps.
+type Unscoper interface {
+	Unscoped() Database
+}
+
+// Unscoped returns the database without the soft delete filter and timestamps, it returns the db itself
+// if the db is not scoped.
+func Unscoped(db Database) Database {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	if u, ok := db.(Unscoper); ok {
+		return u.Unscoped()
+	}
+	return db
+}
+
+// NewScoped wrap the db with the soft delete and timestamps decorator.
+//
+// The CreatedAt and UpdatedAt fields are stamped on Insert, Update and Replace, the time fields can be
+// time.Time, *time.Time or *timestamppb.Timestamp. Delete sets the SoftDelete field instead of deleting
+// the rows, and the rows whose SoftDelete field is not null are excluded from the queries, updates and
+// deletes. Use [Unscoped] to read or delete the soft deleted rows.
+func NewScoped(db Database, opts ScopeOptions) Database {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	return &scopedDB{
+		Database: db,
+		opts:     opts,
+	}
+}
+
+// scopedDB the soft delete and timestamps decorator
+type scopedDB struct {
+	Database
+	opts ScopeOptions
+}
+
+// Unscoped implements Unscoper
+func (s *scopedDB) Unscoped() Database {
+	return s.Database
+}
+
+// scope add the not deleted condition
+func (s *scopedDB) scope(conds C) C {
+	if s.opts.SoftDelete == "" {
+		return conds
+	}
+	return append(slices.Clip(conds), CE{Key: s.opts.SoftDelete, Value: false, C: Exists})
+}
+
+// GetDatabase implements Database
+func (s *scopedDB) GetDatabase(ctx context.Context, project string) (Database, error) {
+	db, err := s.Database.GetDatabase(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return NewScoped(db, s.opts), nil
+}
+
+// WithTransaction implements Database
+func (s *scopedDB) WithTransaction(ctx context.Context, tx Transaction) Database {
+	return NewScoped(s.Database.WithTransaction(ctx, tx), s.opts)
+}
+
+// Insert implements Database
+func (s *scopedDB) Insert(ctx context.Context, table string, docs any) (int, error) {
+	s.stamp(docs, true)
+	return s.Database.Insert(ctx, table, docs)
+}
+
+// InsertOne implements Database
+func (s *scopedDB) InsertOne(ctx context.Context, table string, data any) error {
+	s.stamp(data, true)
+	return s.Database.InsertOne(ctx, table, data)
+}
+
+// Update implements Database
+func (s *scopedDB) Update(ctx context.Context, table string, condition C, doc any) (int, error) {
+	return s.Database.Update(ctx, table, s.scope(condition), s.stampUpdate(doc))
+}
+
+// UpdateOne implements Database
+func (s *scopedDB) UpdateOne(ctx context.Context, table string, condition C, doc any) (int, error) {
+	return s.Database.UpdateOne(ctx, table, s.scope(condition), s.stampUpdate(doc))
+}
+
+// Replace implements Database, the docs of the soft deleted rows are skipped, so the rows are never overwritten
+// or restored.
+func (s *scopedDB) Replace(ctx context.Context, table string, indexKeys []string, docs any) (int, error) {
+	s.stamp(docs, true)
+	if s.opts.SoftDelete == "" {
+		return s.Database.Replace(ctx, table, indexKeys, docs)
+	}
+	docs, err := s.skipDeleted(ctx, table, indexKeys, docs)
+	if err != nil || docs == nil {
+		return 0, err
+	}
+	return s.Database.Replace(ctx, table, indexKeys, docs)
+}
+
+// skipDeleted returns the docs without the ones of the soft deleted rows, it is nil if no doc is left.
+func (s *scopedDB) skipDeleted(ctx context.Context, table string, indexKeys []string, docs any) (any, error) {
+	v := reflect.Indirect(reflect.ValueOf(docs))
+	if v.Kind() != reflect.Slice {
+		deleted, err := s.isDeleted(ctx, table, indexKeys, docs)
+		if err != nil || deleted {
+			return nil, err
+		}
+		return docs, nil
+	}
+	kept := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for i := range v.Len() {
+		deleted, err := s.isDeleted(ctx, table, indexKeys, v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			kept = reflect.Append(kept, v.Index(i))
+		}
+	}
+	if kept.Len() == 0 {
+		return nil, nil
+	}
+	return kept.Interface(), nil
+}
+
+// isDeleted check if the row of the index keys of the doc is soft deleted, the index keys default to the id key.
+func (s *scopedDB) isDeleted(ctx context.Context, table string, indexKeys []string, doc any) (bool, error) {
+	if len(indexKeys) == 0 {
+		key := IDKey
+		if id, ok := IDOf(doc); ok {
+			key = id.Key
+		}
+		indexKeys = []string{key}
+	}
+	conds := make(C, 0, len(indexKeys)+1)
+	for _, key := range indexKeys {
+		value, ok := docValue(doc, key)
+		if !ok {
+			return false, nil
+		}
+		conds = append(conds, CE{Key: key, Value: value})
+	}
+	return s.Database.Exist(ctx, table, append(conds, CE{Key: s.opts.SoftDelete, Value: true, C: Exists}))
+}
+
+// ReplaceOne implements Database
+func (s *scopedDB) ReplaceOne(ctx context.Context, table string, condition C, data any) (int, error) {
+	s.stamp(data, true)
+	return s.Database.ReplaceOne(ctx, table, s.scope(condition), data)
+}
+
+// Delete implements Database, it sets the SoftDelete field when the soft delete is enabled.
+func (s *scopedDB) Delete(ctx context.Context, table string, condition C) (int, error) {
+	if s.opts.SoftDelete == "" {
+		return s.Database.Delete(ctx, table, condition)
+	}
+	return s.Database.Update(ctx, table, s.scope(condition), s.deleteDoc())
+}
+
+// DeleteOne implements Database, it sets the SoftDelete field when the soft delete is enabled.
+func (s *scopedDB) DeleteOne(ctx context.Context, table string, condition C) (int, error) {
+	if s.opts.SoftDelete == "" {
+		return s.Database.DeleteOne(ctx, table, condition)
+	}
+	return s.Database.UpdateOne(ctx, table, s.scope(condition), s.deleteDoc())
+}
+
+// BulkWrite implements BulkWriter, the models are stamped and scoped like the single writes,
+// the soft deletes are counted as updates.
+func (s *scopedDB) BulkWrite(ctx context.Context, table string, models []WriteModel, opts ...BulkWriteOption,
+) (*BulkWriteResult, error) {
+	scoped := make([]WriteModel, len(models))
+	for i, model := range models {
+		switch model.Op {
+		case WriteInsert:
+			s.stamp(model.Doc, true)
+		case WriteUpdate:
+			model.Filter = s.scope(model.Filter)
+			model.Doc = s.stampUpdate(model.Doc)
+		case WriteUpsert:
+			s.stamp(model.Doc, true)
+			model.Filter = s.scope(model.Filter)
+		case WriteDelete:
+			if s.opts.SoftDelete != "" {
+				model = UpdateModel(s.scope(model.Filter), s.deleteDoc())
+			}
+		}
+		scoped[i] = model
+	}
+	return BulkWrite(ctx, s.Database, table, scoped, opts...)
+}
+
+func (s *scopedDB) deleteDoc() D {
+	now := time.Now()
+	doc := D{{Key: s.opts.SoftDelete, Value: now}}
+	if s.opts.UpdatedAt != "" {
+		doc = append(doc, E{Key: s.opts.UpdatedAt, Value: now})
+	}
+	return doc
+}
+
+// Find implements Database
+func (s *scopedDB) Find(ctx context.Context, table string, condition C, sortBy []string, limit int,
+	arrayPtr any,
+) error {
+	return s.Database.Find(ctx, table, s.scope(condition), sortBy, limit, arrayPtr)
+}
+
+// FindOne implements Database
+func (s *scopedDB) FindOne(ctx context.Context, table string, condition C, data any) error {
+	return s.Database.FindOne(ctx, table, s.scope(condition), data)
+}
+
+// FindRows implements Database
+func (s *scopedDB) FindRows(ctx context.Context, table string, condition C, sortBy []string, limit int,
+	oneData any,
+) (Row, error) {
+	return s.Database.FindRows(ctx, table, s.scope(condition), sortBy, limit, oneData)
+}
+
+// Exist implements Database
+func (s *scopedDB) Exist(ctx context.Context, table string, condition C) (bool, error) {
+	return s.Database.Exist(ctx, table, s.scope(condition))
+}
+
+// Count implements Database
+func (s *scopedDB) Count(ctx context.Context, table string, condition C) (int64, error) {
+	return s.Database.Count(ctx, table, s.scope(condition))
+}
+
+// Aggregate implements Aggregator
+func (s *scopedDB) Aggregate(ctx context.Context, table string, in *AggregateRequest) (*AggregateResponse, error) {
+	scoped := *in
+	scoped.Filters = s.scope(in.Filters)
+	return Aggregate(ctx, s.Database, table, &scoped)
+}
+
+// DoPageQuery implements PageQuerier
+func (s *scopedDB) DoPageQuery(ctx context.Context, table string, in *PageQueryRequest, result any) error {
+	q, ok := s.Database.(PageQuerier)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "PageQuery unimplemented for %s",
+			reflect.TypeOf(s.Database).String())
+	}
+	scoped := *in
+	scoped.Filters = s.scope(in.Filters)
+	return q.DoPageQuery(ctx, table, &scoped, result)
+}
+
+// DoStreamQuery implements StreamQuerier
+func (s *scopedDB) DoStreamQuery(ctx context.Context, table string, in *StreamQueryRequest, result any) error {
+	q, ok := s.Database.(StreamQuerier)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "StreamQuery unimplemented for %s",
+			reflect.TypeOf(s.Database).String())
+	}
+	scoped := *in
+	scoped.Filters = s.scope(in.Filters)
+	return q.DoStreamQuery(ctx, table, &scoped, result)
+}
+
+// Watch implements Watcher, the changes are not filtered as a soft delete is an update.
+func (s *scopedDB) Watch(ctx context.Context, table string, filter C, opts ...WatchOption,
+) (<-chan ChangeEvent, error) {
+	w, ok := s.Database.(Watcher)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Watch unimplemented for %s",
+			reflect.TypeOf(s.Database).String())
+	}
+	return w.Watch(ctx, table, filter, opts...)
+}
+
+// ProvisionTenant implements TenantManager
+func (s *scopedDB) ProvisionTenant(ctx context.Context, project string) error {
+	return ProvisionTenant(ctx, s.Database, project)
+}
+
+// ListTenants implements TenantManager
+func (s *scopedDB) ListTenants(ctx context.Context) ([]string, error) {
+	return ListTenants(ctx, s.Database)
+}
+
+// Savepoint implements Savepointer
+func (s *scopedDB) Savepoint(ctx context.Context, name string) error {
+	return Savepoint(ctx, s.Database, name)
+}
+
+// RollbackToSavepoint implements Savepointer
+func (s *scopedDB) RollbackToSavepoint(ctx context.Context, name string) error {
+	return RollbackToSavepoint(ctx, s.Database, name)
+}
+
+// ReleaseSavepoint implements Savepointer
+func (s *scopedDB) ReleaseSavepoint(ctx context.Context, name string) error {
+	return ReleaseSavepoint(ctx, s.Database, name)
+}
+
+// IndexNames implements Indexer
+func (s *scopedDB) IndexNames(ctx context.Context, table string) ([]string, error) {
+	return IndexNames(ctx, s.Database, table)
+}
+
+// CreateIndex implements Indexer
+func (s *scopedDB) CreateIndex(ctx context.Context, table string, index *Index) error {
+	return CreateIndex(ctx, s.Database, table, index)
+}
+
+// stampUpdate set the UpdatedAt of the update doc, the doc is a D or a pointer of struct.
+func (s *scopedDB) stampUpdate(doc any) any {
+	if s.opts.UpdatedAt == "" {
+		return doc
+	}
+	d, ok := doc.(D)
+	if !ok {
+		s.stamp(doc, false)
+		return doc
+	}
+	now := time.Now()
+	i := slices.IndexFunc(d, func(e E) bool {
+		return e.Key == s.opts.UpdatedAt
+	})
+	if i >= 0 {
+		d = slices.Clone(d)
+		d[i].Value = now
+		return d
+	}
+	return append(slices.Clip(d), E{Key: s.opts.UpdatedAt, Value: now})
+}
+
+// stamp set the CreatedAt if it is zero and the UpdatedAt of the doc or the slice of docs,
+// the docs can be pointers of struct or maps.
+func (s *scopedDB) stamp(docs any, create bool) {
+	if s.opts.UpdatedAt == "" && (!create || s.opts.CreatedAt == "") {
+		return
+	}
+	now := time.Now()
+	v := reflect.ValueOf(docs)
+	if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Slice {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		s.stampValue(v, now, create)
+		return
+	}
+	for i := range v.Len() {
+		s.stampValue(v.Index(i), now, create)
+	}
+}
+
+func (s *scopedDB) stampValue(v reflect.Value, now time.Time, create bool) {
+	if create && s.opts.CreatedAt != "" {
+		setTimeField(v, s.opts.CreatedAt, now, false)
+	}
+	if s.opts.UpdatedAt != "" {
+		setTimeField(v, s.opts.UpdatedAt, now, true)
+	}
+}
+
+// setTimeField set the time field of the struct or map whose name or json/bson tag is the name,
+// the field is only set when it is zero if overwrite is false.
+func setTimeField(v reflect.Value, name string, now time.Time, overwrite bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.IsNil() {
+			return
+		}
+		key := reflect.ValueOf(name).Convert(v.Type().Key())
+		if !overwrite && v.MapIndex(key).IsValid() && !v.MapIndex(key).IsZero() {
+			return
+		}
+		if value := reflect.ValueOf(now); value.Type().AssignableTo(v.Type().Elem()) {
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		field, ok := fieldByName(v, name)
+		if !ok || !field.CanSet() || (!overwrite && !field.IsZero()) {
+			return
+		}
+		switch field.Type() {
+		case reflect.TypeFor[time.Time]():
+			field.Set(reflect.ValueOf(now))
+		case reflect.TypeFor[*time.Time]():
+			field.Set(reflect.ValueOf(&now))
+		case reflect.TypeFor[*timestamppb.Timestamp]():
+			field.Set(reflect.ValueOf(timestamppb.New(now)))
+		}
+	}
+}
+
+// fieldByName find the field whose json or bson tag, or the snake case of the name, is the name,
+// the fields of the anonymous structs are found too.
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if found, ok := fieldByName(fv, name); ok {
+					return found, true
+				}
+			}
+			continue
+		}
+		if fieldName(field) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "bson"} {
+		if tag, _, _ := strings.Cut(field.Tag.Get(key), ","); tag != "" && tag != "-" {
+			return tag
+		}
+	}
+	return SnakeCase(field.Name)
+}
+
+// SnakeCase converts the camel case or pascal case name to snake case, it is the key of a field without
+// the json or bson tag, for exp: CreatedAt to created_at, HTTPResponse to http_response, ID to id.
+func SnakeCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 5)
+	for i, r := range s {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			prevLower := s[i-1] >= 'a' && s[i-1] <= 'z'
+			nextLower := i+1 < len(s) && s[i+1] >= 'a' && s[i+1] <= 'z'
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+// docValue returns the value of the key of the struct, map or D doc.
+func docValue(doc any, key string) (any, bool) {
+	switch d := doc.(type) {
+	case map[string]any:
+		value, ok := d[key]
+		return value, ok
+	case D:
+		i := slices.IndexFunc(d, func(e E) bool {
+			return e.Key == key
+		})
+		if i < 0 {
+			return nil, false
+		}
+		return d[i].Value, true
+	}
+	v := reflect.Indirect(reflect.ValueOf(doc))
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+	field, ok := fieldByName(v, key)
+	if !ok {
+		return nil, false
+	}
+	return field.Interface(), true
+}
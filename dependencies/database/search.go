@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SearchScore the sort key of the relevance of the first Search condition, for exp: []string{"-_score"}
+// sorts the most relevant first. It can not be a sort key of the keyset stream query.
+const SearchScore = "_score"
+
+// SearchFields returns the fields of a Search condition
+func (c CE) SearchFields() []string {
+	fields := strings.Split(c.Key, ",")
+	for i, v := range fields {
+		fields[i] = strings.TrimSpace(v)
+	}
+	return fields
+}
+
+// SearchText returns the text of a Search condition
+func (c CE) SearchText() string {
+	return fmt.Sprint(c.Value)
+}
+
+// SearchCondition returns the first Search condition of the conditions, which is the condition of the
+// SearchScore.
+func SearchCondition(conds C) (CE, bool) {
+	for _, v := range conds {
+		if v.C == Search {
+			return v, true
+		}
+	}
+	return CE{}, false
+}
+
+// HasSearchScore check if the sort has the SearchScore key
+func HasSearchScore(sort []string) bool {
+	return slices.ContainsFunc(sort, func(v string) bool {
+		return strings.TrimPrefix(v, "-") == SearchScore
+	})
+}
+
+// SearchTokens splits the text to the lower case words, it is the tokenizer of the backends without a
+// full-text index.
+func SearchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Tenancy the strategy of GetDatabase to isolate the projects, it is set by the tenancy query of the uri.
+type Tenancy string
+
+const (
+	// TenancyColumn the projects share the tables, the rows are filtered by the project column, it is the default.
+	TenancyColumn Tenancy = "column"
+	// TenancySchema each project has its own postgres schema in the database.
+	TenancySchema Tenancy = "schema"
+	// TenancyDatabase each project has its own database, it is supported by mysql, postgres, sqlite and mongo.
+	TenancyDatabase Tenancy = "database"
+)
+
+// tenancyKey the query key of the tenancy strategy
+const tenancyKey = "tenancy"
+
+// ParseTenancy returns the tenancy of the uri and removes the tenancy query, it is TenancyColumn by default.
+func ParseTenancy(u *url.URL) (Tenancy, error) {
+	query := u.Query()
+	if !query.Has(tenancyKey) {
+		return TenancyColumn, nil
+	}
+	tenancy := Tenancy(query.Get(tenancyKey))
+	switch tenancy {
+	case TenancyColumn, TenancySchema, TenancyDatabase:
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "tenancy must be column, schema or database, got %s", tenancy)
+	}
+	query.Del(tenancyKey)
+	u.RawQuery = query.Encode()
+	return tenancy, nil
+}
+
+// TenantName the name of the schema or database of the project, it is the base name and the project joined
+// by "_", exp: the project p1 of the database app is app_p1. The project must be an identifier.
+func TenantName(base, project string) (string, error) {
+	if !fieldNameRegexp.MatchString(project) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid tenant %q, it must be an identifier", project)
+	}
+	return base + "_" + project, nil
+}
+
+// TenantManager is an optional interface that Database implementations can satisfy to provision and list
+// the tenants which have their own schemas or databases.
+type TenantManager interface {
+	// ProvisionTenant creates the schema or database of the project if it does not exist,
+	// GetDatabase provisions the tenant on its first use too.
+	ProvisionTenant(ctx context.Context, project string) error
+	// ListTenants returns the projects which have been provisioned.
+	ListTenants(ctx context.Context) ([]string, error)
+}
+
+// ProvisionTenant creates the schema or database of the project, it returns an Unimplemented error if
+// the db does not implement TenantManager.
+func ProvisionTenant(ctx context.Context, db Database, project string) error {
+	m, err := tenantManager(db)
+	if err != nil {
+		return err
+	}
+	return m.ProvisionTenant(ctx, project)
+}
+
+// ListTenants returns the provisioned projects, it returns an Unimplemented error if the db does not
+// implement TenantManager.
+func ListTenants(ctx context.Context, db Database) ([]string, error) {
+	m, err := tenantManager(db)
+	if err != nil {
+		return nil, err
+	}
+	return m.ListTenants(ctx)
+}
+
+func tenantManager(db Database) (TenantManager, error) {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	m, ok := db.(TenantManager)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "tenant manager unimplemented for %s",
+			reflect.TypeOf(db).String())
+	}
+	return m, nil
+}
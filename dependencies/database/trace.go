@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// tracingKey the query key of the tracing switch
+const tracingKey = "tracing"
+
+// the attributes of the spans of the database operations
+const (
+	attrSystem     = attribute.Key("db.system")
+	attrTable      = attribute.Key("db.table")
+	attrOperation  = attribute.Key("db.operation")
+	attrCondition  = attribute.Key("db.condition")
+	attrStatusCode = attribute.Key("rpc.grpc.status_code")
+)
+
+// Tracer starts the OpenTelemetry spans of the database operations, it is enabled by the tracing=true
+// query of the uri.
+type Tracer struct {
+	system string
+	tracer trace.Tracer
+}
+
+// ParseTracer returns the tracer of the uri and removes the tracing query, it returns nil if the tracing
+// is not enabled. The system is the db.system attribute of the spans, exp: mysql or mongodb.
+func ParseTracer(system string, u *url.URL) *Tracer {
+	query := u.Query()
+	if !query.Has(tracingKey) {
+		return nil
+	}
+	enabled := query.Get(tracingKey) == "true"
+	query.Del(tracingKey)
+	u.RawQuery = query.Encode()
+	if !enabled {
+		return nil
+	}
+	return &Tracer{
+		system: system,
+		tracer: otel.Tracer("database/" + system),
+	}
+}
+
+// Start starts the span of the operation on the table, the done ends the span and records the error with
+// its grpc status code. It is a no-op on a nil tracer.
+func (t *Tracer) Start(ctx context.Context, table, op string, conds C) (context.Context, func(err error)) {
+	if t == nil {
+		return ctx, func(error) {}
+	}
+	name := op
+	if table != "" {
+		name += " " + table
+	}
+	attrs := []attribute.KeyValue{attrSystem.String(t.system), attrOperation.String(op)}
+	if table != "" {
+		attrs = append(attrs, attrTable.String(table))
+	}
+	if len(conds) > 0 {
+		attrs = append(attrs, attrCondition.String(conds.Sanitized()))
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetAttributes(attrStatusCode.Int(int(status.Code(err))))
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// conditionOperators the operators of the sanitized conditions
+var conditionOperators = map[Condition]string{
+	Eq:            "=",
+	Ne:            "!=",
+	Lt:            "<",
+	Lte:           "<=",
+	Gt:            ">",
+	Gte:           ">=",
+	In:            "IN",
+	Nin:           "NOT IN",
+	Like:          "LIKE",
+	Regex:         "REGEX",
+	Exists:        "EXISTS",
+	Between:       "BETWEEN",
+	ArrayContains: "CONTAINS",
+	JSONMatch:     "MATCH",
+	Search:        "SEARCH",
+}
+
+// Sanitized returns the conditions without the values, so they can be attached to the traces and logs,
+// for exp: name = ? AND (age > ? OR vip = ?).
+func (c C) Sanitized() string {
+	return c.sanitized(" AND ")
+}
+
+func (c C) sanitized(sep string) string {
+	parts := make([]string, len(c))
+	for i, v := range c {
+		switch v.C {
+		case Or:
+			parts[i] = "(" + v.Group().sanitized(" OR ") + ")"
+		case And:
+			parts[i] = "(" + v.Group().sanitized(" AND ") + ")"
+		case Not:
+			parts[i] = "NOT (" + v.Group().sanitized(" AND ") + ")"
+		default:
+			op, ok := conditionOperators[v.C]
+			if !ok {
+				op = fmt.Sprintf("OP(%d)", v.C)
+			}
+			parts[i] = v.Key + " " + op + " ?"
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TxFunc the function run in a transaction by RunInTransaction, the tx is the database bound to the
+// transaction, and the ctx carries the transaction to the repositories, see [TxFromContext].
+type TxFunc func(ctx context.Context, tx Database) error
+
+// TxOptions the options of RunInTransaction
+type TxOptions struct {
+	// MaxRetries the max retries of an aborted transaction, it is 3 by default, 0 means no retry.
+	MaxRetries int
+	// Backoff the delay before the first retry, it is doubled on every retry with jitter, 10ms by default.
+	Backoff time.Duration
+	// MaxBackoff the max delay between the retries, 1s by default.
+	MaxBackoff time.Duration
+}
+
+// TxOption the option of RunInTransaction
+type TxOption func(*TxOptions)
+
+// WithMaxRetries set the max retries of an aborted transaction
+func WithMaxRetries(retries int) TxOption {
+	return func(o *TxOptions) {
+		o.MaxRetries = retries
+	}
+}
+
+// WithBackoff set the delay before the first retry and the max delay between the retries
+func WithBackoff(backoff, maxBackoff time.Duration) TxOption {
+	return func(o *TxOptions) {
+		o.Backoff = backoff
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+// NewTxOptions apply the options
+func NewTxOptions(opts ...TxOption) *TxOptions {
+	o := &TxOptions{
+		MaxRetries: 3,
+		Backoff:    10 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// AfterCommitter is an optional interface that the transactions can satisfy to run the functions after they
+// are committed, the cached decorator invalidates the tables written in the transactions by it.
+type AfterCommitter interface {
+	// AfterCommit registers the fn which runs after the transaction is committed, it never runs if the
+	// commit fails or the transaction is rolled back.
+	AfterCommit(fn func())
+}
+
+// CommitHooks the functions which run after the commit, the transactions embed it to implement AfterCommitter.
+type CommitHooks struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// AfterCommit implements AfterCommitter
+func (h *CommitHooks) AfterCommit(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+// Committed runs the functions after the transaction is committed, it is called by the transactions.
+func (h *CommitHooks) Committed() {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// Savepointer is an optional interface that the databases bound to a transaction can satisfy to run the
+// nested transactions of RunInTransaction in savepoints.
+type Savepointer interface {
+	// Savepoint creates the savepoint of the name in the transaction
+	Savepoint(ctx context.Context, name string) error
+	// RollbackToSavepoint rolls back the writes after the savepoint
+	RollbackToSavepoint(ctx context.Context, name string) error
+	// ReleaseSavepoint keeps the writes after the savepoint in the transaction
+	ReleaseSavepoint(ctx context.Context, name string) error
+}
+
+// Savepoint creates the savepoint in the transaction of the db, it returns an Unimplemented error if the db
+// does not implement Savepointer.
+func Savepoint(ctx context.Context, db Database, name string) error {
+	s, err := savepointer(db)
+	if err != nil {
+		return err
+	}
+	return s.Savepoint(ctx, name)
+}
+
+// RollbackToSavepoint rolls back the transaction of the db to the savepoint, it returns an Unimplemented
+// error if the db does not implement Savepointer.
+func RollbackToSavepoint(ctx context.Context, db Database, name string) error {
+	s, err := savepointer(db)
+	if err != nil {
+		return err
+	}
+	return s.RollbackToSavepoint(ctx, name)
+}
+
+// ReleaseSavepoint releases the savepoint of the transaction of the db, it returns an Unimplemented error
+// if the db does not implement Savepointer.
+func ReleaseSavepoint(ctx context.Context, db Database, name string) error {
+	s, err := savepointer(db)
+	if err != nil {
+		return err
+	}
+	return s.ReleaseSavepoint(ctx, name)
+}
+
+func savepointer(db Database) (Savepointer, error) {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	s, ok := db.(Savepointer)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "savepoint unimplemented for %s",
+			reflect.TypeOf(db).String())
+	}
+	return s, nil
+}
+
+type txContextKey struct{}
+
+// txContext the transaction of RunInTransaction in the ctx
+type txContext struct {
+	// db the database which starts the transaction
+	db Database
+	// tx the database bound to the transaction
+	tx Database
+	// depth the depth of the nested transactions
+	depth int
+}
+
+// TxFromContext returns the database bound to the transaction which is started on the db by RunInTransaction
+// with the ctx, or the db itself if there is no such transaction. The repositories read and write in the
+// transaction of the ctx by it, so the transaction is not threaded through the repository code.
+func TxFromContext(ctx context.Context, db Database) Database {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	if txCtx, ok := ctx.Value(txContextKey{}).(*txContext); ok && txCtx.db == db {
+		return txCtx.tx
+	}
+	return db
+}
+
+// IsRetryable check if the transaction of the error can be retried, the serialization failures, deadlocks
+// and transient transaction errors are converted to Aborted by the implementations, for exp:
+//
+//   - the deadlock and lock wait timeout of mysql
+//   - the serialization failure and deadlock of postgres
+//   - the busy and locked database of sqlite
+//   - the TransientTransactionError of mongo
+//   - the conflicting commit of mock
+func IsRetryable(err error) bool {
+	return status.Code(err) == codes.Aborted
+}
+
+// RunInTransaction runs the fn in a transaction of the db. The transaction is committed if the fn returns nil,
+// and rolled back if the fn returns an error or panics, the panic is raised again after the rollback. An
+// aborted transaction (see [IsRetryable]) is retried with backoff, so the fn must be safe to run again.
+//
+// RunInTransaction in the fn with the ctx of the fn runs a nested transaction in a savepoint if the db
+// implements Savepointer, the error of the nested fn rolls back to the savepoint only. The nested fn joins the
+// outer transaction on the other databases. The nested transactions are never retried alone.
+func RunInTransaction(ctx context.Context, db Database, fn TxFunc, opts ...TxOption) error {
+	if d, ok := db.(*DB); ok {
+		db = d.Database
+	}
+	if outer, ok := ctx.Value(txContextKey{}).(*txContext); ok && outer.db == db {
+		return runNested(ctx, outer, fn)
+	}
+	o := NewTxOptions(opts...)
+	backoff := o.Backoff
+	for retries := 0; ; retries++ {
+		err := runTransaction(ctx, db, fn)
+		if err == nil || !IsRetryable(err) || retries >= o.MaxRetries {
+			return err
+		}
+		// the jitter of the backoff, so the conflicting transactions are not retried at the same time
+		var delay time.Duration
+		if backoff > 0 {
+			delay = backoff/2 + rand.N(backoff/2+1)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, o.MaxBackoff)
+	}
+}
+
+// runTransaction runs the fn in a new transaction
+func runTransaction(ctx context.Context, db Database, fn TxFunc) (err error) {
+	tx, err := db.StartTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	txDB := db.WithTransaction(ctx, tx)
+	var finished bool
+	defer func() {
+		if finished {
+			return
+		}
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		_ = tx.Rollback()
+	}()
+	err = fn(context.WithValue(ctx, txContextKey{}, &txContext{db: db, tx: txDB}), txDB)
+	if err != nil {
+		return err
+	}
+	// a failed commit ends the transaction, it is not rolled back
+	finished = true
+	return tx.Commit()
+}
+
+// runNested runs the fn in a savepoint of the outer transaction, or in the outer transaction if the
+// savepoints are not supported.
+func runNested(ctx context.Context, outer *txContext, fn TxFunc) (err error) {
+	inner := &txContext{db: outer.db, tx: outer.tx, depth: outer.depth + 1}
+	ctx = context.WithValue(ctx, txContextKey{}, inner)
+	name := fmt.Sprintf("nested_tx_%d", inner.depth)
+	if err = Savepoint(ctx, outer.tx, name); err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return fn(ctx, outer.tx)
+		}
+		return err
+	}
+	var finished bool
+	defer func() {
+		if finished {
+			return
+		}
+		if p := recover(); p != nil {
+			_ = RollbackToSavepoint(ctx, outer.tx, name)
+			panic(p)
+		}
+	}()
+	if err = fn(ctx, outer.tx); err != nil {
+		finished = true
+		_ = RollbackToSavepoint(ctx, outer.tx, name)
+		return err
+	}
+	finished = true
+	return ReleaseSavepoint(ctx, outer.tx, name)
+}
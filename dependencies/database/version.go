@@ -0,0 +1,118 @@
+package database
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrVersionConflict the version of the doc is changed by others, reload the doc and retry.
+var ErrVersionConflict = status.Error(codes.Aborted, "version conflict")
+
+// Version the version field of a model for the optimistic locking, it is tagged by db:"version", for exp:
+//
+//	type User struct {
+//		ID      int64  `json:"id"`
+//		Name    string `json:"name"`
+//		Version int64  `json:"version" db:"version"`
+//	}
+//
+// UpdateOne and ReplaceOne of a versioned model only match the doc of the same version and increment
+// the version, the ErrVersionConflict is returned if the doc exists with another version.
+type Version struct {
+	// Key the field name of the version
+	Key string
+	// Value the version before the update
+	Value   int64
+	field   reflect.Value
+	current any
+}
+
+// VersionOf returns the version field of the pointer of struct, it is false if the model is not versioned.
+func VersionOf(data any) (*Version, bool) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	key, field, ok := findVersionField(v.Elem())
+	if !ok {
+		return nil, false
+	}
+	version := &Version{Key: key, field: field, current: field.Interface()}
+	if field.CanInt() {
+		version.Value = field.Int()
+	} else {
+		version.Value = int64(field.Uint())
+	}
+	return version, true
+}
+
+func findVersionField(v reflect.Value) (string, reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous {
+			if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if key, field, ok := findVersionField(fv); ok {
+					return key, field, true
+				}
+			}
+			continue
+		}
+		if HasTagOption(sf, "version") && (fv.CanInt() || fv.CanUint()) && fv.CanSet() {
+			return fieldName(sf), fv, true
+		}
+	}
+	return "", reflect.Value{}, false
+}
+
+// HasTagOption check if the db tag of the field has the option, the options are separated by commas,
+// for exp: db:"version" or db:"encrypt,index".
+func HasTagOption(field reflect.StructField, option string) bool {
+	return slices.Contains(strings.Split(field.Tag.Get("db"), ","), option)
+}
+
+// TagOptionValue returns the value of the key=value option of the db tag of the field, for exp: the value of
+// ttl is 720h in db:"ttl=720h".
+func TagOptionValue(field reflect.StructField, key string) (string, bool) {
+	for _, option := range strings.Split(field.Tag.Get("db"), ",") {
+		if k, v, ok := strings.Cut(option, "="); ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Scope add the condition of the current version to the conditions.
+func (v *Version) Scope(conds C) C {
+	// the value keeps the type of the field, so the mock compares it exactly
+	return append(slices.Clip(conds), CE{Key: v.Key, Value: v.current})
+}
+
+// Increment set the version field to the next version, call Reset if the update fails.
+func (v *Version) Increment() {
+	v.set(v.Value + 1)
+}
+
+// Reset set the version field back to the version before the update.
+func (v *Version) Reset() {
+	v.set(v.Value)
+}
+
+func (v *Version) set(value int64) {
+	if v.field.CanInt() {
+		v.field.SetInt(value)
+	} else {
+		v.field.SetUint(uint64(value))
+	}
+}
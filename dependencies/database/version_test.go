@@ -0,0 +1,57 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+type VersionedUser struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version" db:"version"`
+}
+
+func TestVersionOf(t *testing.T) {
+	user := &VersionedUser{ID: 1, Version: 3}
+	version, ok := database.VersionOf(user)
+	if !ok || version.Key != "version" || version.Value != 3 {
+		t.Fatalf("Unexpected version %+v", version)
+	}
+	conds := version.Scope(database.C{{Key: "id", Value: int64(1)}})
+	if len(conds) != 2 || conds[1].Key != "version" || conds[1].Value != 3 {
+		t.Errorf("Unexpected scoped conditions %v", conds)
+	}
+	version.Increment()
+	if user.Version != 4 {
+		t.Errorf("Expected version 4 after the increment, got %d", user.Version)
+	}
+	version.Reset()
+	if user.Version != 3 {
+		t.Errorf("Expected version 3 after the reset, got %d", user.Version)
+	}
+
+	type embedded struct {
+		VersionedUser
+		Revision uint32 `json:"revision" db:"version"`
+	}
+	if version, ok = database.VersionOf(&embedded{}); !ok || version.Key != "version" {
+		t.Errorf("Expected the version of the embedded struct, got %+v", version)
+	}
+	type unsigned struct {
+		Revision uint32 `json:"revision" db:"version"`
+	}
+	revision := &unsigned{Revision: 1}
+	if version, ok = database.VersionOf(revision); !ok || version.Key != "revision" {
+		t.Fatalf("Unexpected version %+v", version)
+	}
+	if version.Increment(); revision.Revision != 2 {
+		t.Errorf("Expected revision 2 after the increment, got %d", revision.Revision)
+	}
+
+	for _, data := range []any{VersionedUser{}, &struct{ Name string }{}, (*VersionedUser)(nil)} {
+		if _, ok = database.VersionOf(data); ok {
+			t.Errorf("Expected %T is not versioned", data)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import "context"
+
+// Watcher is an optional interface that Database implementations can satisfy to subscribe the changes
+// of a table, it is implemented by the mongo change streams and the mock database.
+//
+// The channel is closed when the ctx is done or the subscription fails, the failure is reported by the Err
+// of the last event. The filter matches the full document after the change, the delete events are always
+// delivered as their documents are gone.
+type Watcher interface {
+	Watch(ctx context.Context, table string, filter C, opts ...WatchOption) (<-chan ChangeEvent, error)
+}
+
+// ChangeOp the operation type of a change
+type ChangeOp string
+
+const (
+	// OpInsert a document is inserted
+	OpInsert ChangeOp = "insert"
+	// OpUpdate a document is updated
+	OpUpdate ChangeOp = "update"
+	// OpReplace a document is replaced
+	OpReplace ChangeOp = "replace"
+	// OpDelete a document is deleted
+	OpDelete ChangeOp = "delete"
+)
+
+// ChangeEvent the change of a document
+type ChangeEvent struct {
+	Op    ChangeOp
+	Table string
+	// Key the key of the document, it is the _id of mongo and the id of mock.
+	Key any
+	// Document the full document after the change, it is nil for the delete events.
+	Document map[string]any
+	// ResumeToken pass it to WithResumeToken to restart the subscription after this event.
+	ResumeToken string
+	// Err the error which closes the channel, the other fields are empty when it is set.
+	Err error
+}
+
+// WatchOptions the options of Watch
+type WatchOptions struct {
+	// ResumeToken restart the subscription after the event of the token
+	ResumeToken string
+	// BufferSize the buffer size of the channel
+	BufferSize int
+}
+
+// WatchOption the option of Watch
+type WatchOption func(*WatchOptions)
+
+// WithResumeToken restart the subscription after the event of the ResumeToken
+func WithResumeToken(token string) WatchOption {
+	return func(o *WatchOptions) {
+		o.ResumeToken = token
+	}
+}
+
+// WithBufferSize set the buffer size of the channel, it is 64 by default
+func WithBufferSize(size int) WatchOption {
+	return func(o *WatchOptions) {
+		o.BufferSize = size
+	}
+}
+
+// NewWatchOptions apply the options, it is used by the implementations of Watcher.
+func NewWatchOptions(opts ...WatchOption) *WatchOptions {
+	o := &WatchOptions{
+		BufferSize: 64,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
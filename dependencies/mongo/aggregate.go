@@ -0,0 +1,120 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Aggregate implements database.Aggregator with the $match, $group, $project, $match, $sort and $limit pipeline.
+func (m *Mongo) Aggregate(ctx context.Context, table string, in *database.AggregateRequest,
+) (out *database.AggregateResponse, err error) {
+	ctx, done := m.operation(ctx, table, "aggregate", in.Filters)
+	defer func() { done(err) }()
+	if err := in.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	cur, err := m.Collection(table).Aggregate(ctx, aggregatePipeline(m.project, in))
+	if err != nil {
+		return nil, convertToStatusError(table, err)
+	}
+	defer func() {
+		_ = cur.Close(ctx)
+	}()
+	out = &database.AggregateResponse{}
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err = cur.Decode(&doc); err != nil {
+			return nil, status.Errorf(codes.Internal, "decode %s aggregate error %s", table, err)
+		}
+		row := make(map[string]any, len(in.GroupBy)+len(in.Aggregations))
+		for _, key := range in.GroupBy {
+			row[key] = doc[key]
+		}
+		for _, a := range in.Aggregations {
+			value := doc[a.Name()]
+			if d, ok := value.(bson.Decimal128); ok {
+				value = d.String()
+			}
+			row[a.Name()] = database.AggregateValue(a.Func, value)
+		}
+		out.Data = append(out.Data, row)
+	}
+	if err = cur.Err(); err != nil {
+		return nil, convertToStatusError(table, err)
+	}
+	if len(in.GroupBy) == 0 && len(in.Having) == 0 && len(out.Data) == 0 {
+		// $group returns nothing when no document matches, but aggregating without group by returns one row in sql
+		row := make(map[string]any, len(in.Aggregations))
+		for _, a := range in.Aggregations {
+			row[a.Name()] = database.AggregateValue(a.Func, nil)
+		}
+		out.Data = append(out.Data, row)
+	}
+	return out, nil
+}
+
+func aggregatePipeline(project string, in *database.AggregateRequest) mongo.Pipeline {
+	var pipeline mongo.Pipeline
+	if filter := getCondition(project, in.Filters); len(filter) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filter}})
+	}
+	var groupID any
+	projection := bson.D{{Key: "_id", Value: 0}}
+	if len(in.GroupBy) > 0 {
+		keys := make(bson.D, len(in.GroupBy))
+		for i, key := range in.GroupBy {
+			keys[i] = bson.E{Key: key, Value: "$" + key}
+			projection = append(projection, bson.E{Key: key, Value: "$_id." + key})
+		}
+		groupID = keys
+	}
+	group := bson.D{{Key: "_id", Value: groupID}}
+	for _, a := range in.Aggregations {
+		group = append(group, bson.E{Key: a.Name(), Value: aggregateAccumulator(a)})
+		projection = append(projection, bson.E{Key: a.Name(), Value: 1})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: group}},
+		bson.D{{Key: "$project", Value: projection}},
+	)
+	if having := getCondition("", in.Having); len(having) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: having}})
+	}
+	if orderBy := in.OrderBy(); len(orderBy) > 0 {
+		sort := make(bson.D, len(orderBy))
+		for i, v := range orderBy {
+			if strings.HasPrefix(v, "-") {
+				sort[i] = bson.E{Key: v[1:], Value: -1}
+			} else {
+				sort[i] = bson.E{Key: v, Value: 1}
+			}
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sort}})
+	}
+	if in.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: in.Limit}})
+	}
+	return pipeline
+}
+
+// aggregateAccumulator the accumulator of the aggregation, the Count of a key counts the non-null values
+// like COUNT(`key`) of sql.
+func aggregateAccumulator(a database.Aggregation) bson.D {
+	field := "$" + a.Key
+	if a.Func != database.Count {
+		return bson.D{{Key: "$" + string(a.Func), Value: field}}
+	}
+	if a.Key == "" {
+		return bson.D{{Key: "$sum", Value: 1}}
+	}
+	return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$ne", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{field, nil}}}, nil}}},
+		1, 0,
+	}}}}}
+}
@@ -0,0 +1,108 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ti/common-go/dependencies/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BulkWrite implements database.BulkWriter with the BulkWrite of the collection, the models of the versioned
+// docs are written one by one, so the version of each doc is checked.
+func (m *Mongo) BulkWrite(ctx context.Context, table string, models []database.WriteModel,
+	opts ...database.BulkWriteOption,
+) (result *database.BulkWriteResult, err error) {
+	ctx, done := m.operation(ctx, table, "bulkWrite", nil)
+	defer func() { done(err) }()
+	if len(models) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no write models found")
+	}
+	for _, model := range models {
+		if _, versioned := database.VersionOf(model.Doc); versioned && model.Op != database.WriteInsert {
+			return database.WriteEach(ctx, m, table, models, opts...)
+		}
+	}
+	o := database.NewBulkWriteOptions(opts...)
+	result = database.NewBulkWriteResult(len(models))
+	// indexes the index of each mongo model in the models
+	indexes := make([]int, 0, len(models))
+	writeModels := make([]mongo.WriteModel, 0, len(models))
+	for i, model := range models {
+		writeModel, err := m.writeModel(model)
+		if err != nil {
+			result.Fail(i, err)
+			if o.Ordered {
+				result.Skip(i + 1)
+				break
+			}
+			continue
+		}
+		indexes = append(indexes, i)
+		writeModels = append(writeModels, writeModel)
+	}
+	if len(writeModels) == 0 {
+		return result, result.Err()
+	}
+	ret, err := m.Collection(table).BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(o.Ordered))
+	if ret != nil {
+		result.Inserted = int(ret.InsertedCount)
+		result.Updated = int(ret.ModifiedCount + ret.UpsertedCount)
+		result.Deleted = int(ret.DeletedCount)
+	}
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0 {
+			return nil, convertToStatusError(table, err)
+		}
+		for _, v := range bulkErr.WriteErrors {
+			result.Fail(indexes[v.Index], writeErrorStatus(table, v.WriteError))
+		}
+		if o.Ordered {
+			result.Skip(indexes[bulkErr.WriteErrors[0].Index] + 1)
+		}
+	}
+	return result, result.Err()
+}
+
+// writeModel convert the model to the mongo model like InsertOne, UpdateOne, ReplaceOne and DeleteOne.
+func (m *Mongo) writeModel(model database.WriteModel) (mongo.WriteModel, error) {
+	if err := model.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	filter := getCondition(m.project, model.Filter)
+	switch model.Op {
+	case database.WriteInsert:
+		setObjectID(model.Doc)
+		doc, err := m.transformData(model.Doc, false)
+		if err != nil {
+			return nil, err
+		}
+		return mongo.NewInsertOneModel().SetDocument(doc), nil
+	case database.WriteUpdate:
+		doc, err := m.convertUpdateDoc(model.Doc)
+		if err != nil {
+			return nil, err
+		}
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": doc}), nil
+	case database.WriteUpsert:
+		doc, err := m.transformData(model.Doc, false)
+		if err != nil {
+			return nil, err
+		}
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true), nil
+	default:
+		return mongo.NewDeleteOneModel().SetFilter(filter), nil
+	}
+}
+
+func writeErrorStatus(table string, err mongo.WriteError) error {
+	if err.Code == 11000 {
+		return status.Errorf(codes.AlreadyExists, "%s may already exists for %s", table, err.Message)
+	}
+	return status.Errorf(codes.Internal, "%s db error %s", table, err.Message)
+}
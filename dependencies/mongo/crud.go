@@ -3,9 +3,11 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/ti/common-go/dependencies/database"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -16,17 +18,38 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// GetDatabase get database with project ns.
+// GetDatabase get database with project ns, the project of tenancy=database has its own database
+// which is created by mongo on the first write.
 func (m *Mongo) GetDatabase(_ context.Context, project string) (database.Database, error) {
+	if m.tenancy == database.TenancyDatabase && project != "" {
+		name, err := database.TenantName(m.tenantBase, project)
+		if err != nil {
+			return nil, err
+		}
+		return &Mongo{
+			Client:          m.Client,
+			defaultDatabase: name,
+			tenancy:         m.tenancy,
+			tenantBase:      m.tenantBase,
+			observer:        m.observer,
+			tracer:          m.tracer,
+		}, nil
+	}
 	return &Mongo{
 		Client:          m.Client,
 		defaultDatabase: m.defaultDatabase,
 		project:         project,
+		tenancy:         m.tenancy,
+		tenantBase:      m.tenantBase,
+		observer:        m.observer,
+		tracer:          m.tracer,
 	}, nil
 }
 
 // Insert insert many data
 func (m *Mongo) Insert(ctx context.Context, table string, docs any) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "insert", nil)
+	defer func() { done(err) }()
 	data := reflect.ValueOf(docs)
 	isSlice := data.Kind() == reflect.Slice
 	if !isSlice {
@@ -46,7 +69,11 @@ func (m *Mongo) Insert(ctx context.Context, table string, docs any) (count int,
 	col := m.Collection(table)
 	mgoDocs := make([]any, dataLen)
 	for i := range dataLen {
-		mgoDocs[i] = m.transformData(data.Index(i).Interface(), false)
+		doc := data.Index(i).Interface()
+		setObjectID(doc)
+		if mgoDocs[i], err = m.transformData(doc, false); err != nil {
+			return 0, err
+		}
 	}
 	ret, err := col.InsertMany(ctx, mgoDocs, options.InsertMany().SetOrdered(false))
 	if err != nil {
@@ -73,28 +100,56 @@ func convertToStatusError(table string, err error) error {
 	if IsNotFoundError(err) {
 		return status.Errorf(codes.NotFound, "%s may not found", table)
 	}
+	if IsTransientError(err) {
+		return status.Errorf(codes.Aborted, "%s transaction aborted %s", table, err)
+	}
 	return status.Errorf(codes.Internal, "%s db error %s", table, err)
 }
 
 // InsertOne insert one data
-func (m *Mongo) InsertOne(ctx context.Context, table string, data any) error {
+func (m *Mongo) InsertOne(ctx context.Context, table string, data any) (err error) {
+	ctx, done := m.operation(ctx, table, "insertOne", nil)
+	defer func() { done(err) }()
 	col := m.Collection(table)
-	doc := m.transformData(data, false)
-	_, err := col.InsertOne(ctx, doc)
+	setObjectID(data)
+	doc, err := m.transformData(data, false)
+	if err != nil {
+		return err
+	}
+	_, err = col.InsertOne(ctx, doc)
 	if err != nil {
 		if IsConflictError(err) {
 			return status.Errorf(codes.AlreadyExists, "%s may already exists for %s", table, err)
 		}
-		return status.Errorf(codes.Internal, "create %s db error %s", table, err)
+		return convertToStatusError(table, err)
 	}
 	return nil
 }
 
+// setObjectID set a new ObjectID to the zero id field of the doc before the insert, as the driver does for
+// the docs without _id, so the caller gets the id of the inserted doc. A string id field is set to the
+// ObjectIDToBase64 form, see [database.IDOf].
+func setObjectID(doc any) {
+	id, ok := database.IDOf(doc)
+	if !ok || !id.IsGenerated() || !id.IsZero() {
+		return
+	}
+	oid := bson.NewObjectID()
+	if !id.Set(oid) {
+		id.Set(ObjectIDToBase64(oid))
+	}
+}
+
 // Update update data
-func (m *Mongo) Update(ctx context.Context, table string, conds database.C, data any) (int, error) {
+func (m *Mongo) Update(ctx context.Context, table string, conds database.C, data any) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "update", conds)
+	defer func() { done(err) }()
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
-	doc := m.convertUpdateDoc(data)
+	doc, err := m.convertUpdateDoc(data)
+	if err != nil {
+		return 0, err
+	}
 	ret, err := col.UpdateMany(ctx, filter, bson.M{"$set": doc})
 	if err != nil {
 		err = convertToStatusError(table, err)
@@ -103,22 +158,53 @@ func (m *Mongo) Update(ctx context.Context, table string, conds database.C, data
 	return int(ret.ModifiedCount), nil
 }
 
-// UpdateOne update one data
-func (m *Mongo) UpdateOne(ctx context.Context, table string, conds database.C, data any) (int, error) {
+// UpdateOne update one data, the version of a versioned model is checked and incremented.
+func (m *Mongo) UpdateOne(ctx context.Context, table string, conds database.C, data any) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "updateOne", conds)
+	defer func() { done(err) }()
+	filterConds := conds
+	version, versioned := database.VersionOf(data)
+	if versioned {
+		version.Increment()
+		defer func() {
+			if err != nil {
+				version.Reset()
+			}
+		}()
+		filterConds = version.Scope(conds)
+	}
 	col := m.Collection(table)
-	filter := getCondition(m.project, conds)
-	doc := m.convertUpdateDoc(data)
+	filter := getCondition(m.project, filterConds)
+	doc, err := m.convertUpdateDoc(data)
+	if err != nil {
+		return 0, err
+	}
 	ret, err := col.UpdateOne(ctx, filter, bson.M{"$set": doc})
 	if err != nil {
 		return 0, convertToStatusError(table, err)
 	}
 	if ret.MatchedCount == 0 {
+		if versioned {
+			return 0, m.versionNotMatched(ctx, table, conds)
+		}
 		return 0, status.Errorf(codes.NotFound, "condition %s not found", conds)
 	}
 	return int(ret.ModifiedCount), nil
 }
 
-func (m *Mongo) convertUpdateDoc(d any) (doc any) {
+// versionNotMatched returns ErrVersionConflict if the doc exists with another version, or NotFound.
+func (m *Mongo) versionNotMatched(ctx context.Context, table string, conds database.C) error {
+	exist, err := m.Exist(ctx, table, conds)
+	if err != nil {
+		return convertToStatusError(table, err)
+	}
+	if exist {
+		return database.ErrVersionConflict
+	}
+	return status.Errorf(codes.NotFound, "condition %s not found", conds)
+}
+
+func (m *Mongo) convertUpdateDoc(d any) (doc any, err error) {
 	if reflect.TypeOf(d) == databaseDocType {
 		doc = convertDocs(d.(database.D))
 	} else if reflect.TypeOf(d) == databaseMapType {
@@ -134,30 +220,54 @@ func (m *Mongo) convertUpdateDoc(d any) (doc any) {
 		}
 		doc = convertDocs(d)
 	} else {
-		doc = m.transformData(d, true)
+		doc, err = m.transformData(d, true)
 	}
-	return doc
+	return doc, err
 }
 
-// ReplaceOne replace one data
+// ReplaceOne replace one data, a versioned model is never inserted, and its version is checked and incremented.
 func (m *Mongo) ReplaceOne(ctx context.Context, table string, conds database.C, data any) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "replaceOne", conds)
+	defer func() { done(err) }()
+	filterConds := conds
+	version, versioned := database.VersionOf(data)
+	if versioned {
+		version.Increment()
+		defer func() {
+			if err != nil {
+				version.Reset()
+			}
+		}()
+		filterConds = version.Scope(conds)
+	}
 	col := m.Collection(table)
-	doc := m.transformData(data, false)
-	filter := getCondition(m.project, conds)
-	ret, err := col.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	doc, err := m.transformData(data, false)
+	if err != nil {
+		return 0, err
+	}
+	filter := getCondition(m.project, filterConds)
+	ret, err := col.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(!versioned))
 	if err != nil {
 		return 0, convertToStatusError(table, err)
 	}
+	if versioned && ret.MatchedCount == 0 {
+		return 0, m.versionNotMatched(ctx, table, conds)
+	}
 	return int(ret.ModifiedCount), nil
 }
 
 // Replace update data in bulk
 func (m *Mongo) Replace(ctx context.Context, table string, indexKeys []string, docs any) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "replace", nil)
+	defer func() { done(err) }()
 	data := reflect.ValueOf(docs)
 	isSlice := data.Kind() == reflect.Slice
 	col := m.Collection(table)
 	if !isSlice {
-		filter, doc := m.getFilterByIndexKeys(indexKeys, docs)
+		filter, doc, transformErr := m.getFilterByIndexKeys(indexKeys, docs)
+		if transformErr != nil {
+			return 0, transformErr
+		}
 		ret, errReplace := col.ReplaceOne(ctx, filter, doc)
 		if errReplace != nil {
 			return 0, convertToStatusError(table, err)
@@ -173,7 +283,10 @@ func (m *Mongo) Replace(ctx context.Context, table string, indexKeys []string, d
 	}
 	bulkModels := make([]mongo.WriteModel, dataLen)
 	for i := range dataLen {
-		filter, doc := m.getFilterByIndexKeys(indexKeys, docs)
+		filter, doc, transformErr := m.getFilterByIndexKeys(indexKeys, docs)
+		if transformErr != nil {
+			return 0, transformErr
+		}
 		model := mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc)
 		bulkModels[i] = model
 	}
@@ -185,10 +298,13 @@ func (m *Mongo) Replace(ctx context.Context, table string, indexKeys []string, d
 	return dataLen, nil
 }
 
-func (m *Mongo) getFilterByIndexKeys(indexKeys []string, data any) (bson.D, bson.D) {
+func (m *Mongo) getFilterByIndexKeys(indexKeys []string, data any) (bson.D, bson.D, error) {
 	conds := make(database.C, len(indexKeys))
 	hasAnonymous := reflect.ValueOf(data).Elem().Type().Field(0).Anonymous
-	doc := transformDocument(data, "", hasAnonymous)
+	doc, err := transformDocument(data, "", hasAnonymous)
+	if err != nil {
+		return nil, nil, err
+	}
 	docMap := make(map[string]bson.E)
 	for _, v := range doc {
 		docMap[v.Key] = v
@@ -202,7 +318,7 @@ func (m *Mongo) getFilterByIndexKeys(indexKeys []string, data any) (bson.D, bson
 		}
 	}
 	filter := getCondition(m.project, conds)
-	return filter, doc
+	return filter, doc, nil
 }
 
 func getBulkError(err error) (ok bool, errorBulk *database.BulkError) {
@@ -224,7 +340,9 @@ func getBulkError(err error) (ok bool, errorBulk *database.BulkError) {
 }
 
 // Delete delete data
-func (m *Mongo) Delete(ctx context.Context, table string, conds database.C) (int, error) {
+func (m *Mongo) Delete(ctx context.Context, table string, conds database.C) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "delete", conds)
+	defer func() { done(err) }()
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
 	ret, err := col.DeleteMany(ctx, filter)
@@ -235,7 +353,9 @@ func (m *Mongo) Delete(ctx context.Context, table string, conds database.C) (int
 }
 
 // DeleteOne delete one
-func (m *Mongo) DeleteOne(ctx context.Context, table string, conds database.C) (int, error) {
+func (m *Mongo) DeleteOne(ctx context.Context, table string, conds database.C) (count int, err error) {
+	ctx, done := m.operation(ctx, table, "deleteOne", conds)
+	defer func() { done(err) }()
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
 	ret, err := col.DeleteOne(ctx, filter)
@@ -248,7 +368,9 @@ func (m *Mongo) DeleteOne(ctx context.Context, table string, conds database.C) (
 // Find data.
 func (m *Mongo) Find(ctx context.Context, table string, conds database.C, order []string,
 	limit int, arryPtr any,
-) error {
+) (err error) {
+	ctx, done := m.operation(ctx, table, "find", conds)
+	defer func() { done(err) }()
 	valuePtr := reflect.ValueOf(arryPtr)
 	value := valuePtr.Elem()
 	eleType := reflect.TypeOf(arryPtr).Elem().Elem().Elem()
@@ -271,11 +393,17 @@ func (m *Mongo) Find(ctx context.Context, table string, conds database.C, order
 }
 
 // FindOne find one
-func (m *Mongo) FindOne(ctx context.Context, table string, conds database.C, data any) error {
+func (m *Mongo) FindOne(ctx context.Context, table string, conds database.C, data any) (err error) {
+	ctx, done := m.operation(ctx, table, "findOne", conds)
+	defer func() { done(err) }()
+	// the conditions of the deterministic encrypted fields match the encrypted values
+	if conds, err = database.EncryptConditions(conds, data); err != nil {
+		return err
+	}
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
 	result := col.FindOne(ctx, filter)
-	err := result.Err()
+	err = result.Err()
 	if err != nil {
 		return convertToStatusError(table, err)
 	}
@@ -299,13 +427,15 @@ func (m *Mongo) FindOne(ctx context.Context, table string, conds database.C, dat
 			firstField.Set(newValue.Elem())
 		}
 	}
-	return nil
+	return database.DecryptFields(data)
 }
 
 // FindRows find rows
 func (m *Mongo) FindRows(ctx context.Context, table string, conds database.C, sortBy []string, limit int,
 	data any,
-) (database.Row, error) {
+) (row database.Row, err error) {
+	ctx, done := m.operation(ctx, table, "findRows", conds)
+	defer func() { done(err) }()
 	elemType := reflect.TypeOf(data).Elem()
 	return m.findRows(ctx, table, conds, sortBy, limit, elemType)
 }
@@ -314,6 +444,10 @@ func (m *Mongo) FindRows(ctx context.Context, table string, conds database.C, so
 func (m *Mongo) findRows(ctx context.Context, table string, conds database.C, sortBy []string, limit int,
 	elemType reflect.Type,
 ) (database.Row, error) {
+	conds, err := database.EncryptConditions(conds, reflect.New(elemType).Interface())
+	if err != nil {
+		return nil, err
+	}
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
 	opts := options.Find()
@@ -328,15 +462,7 @@ func (m *Mongo) findRows(ctx context.Context, table string, conds database.C, so
 	} else {
 		sortFields = make(bson.D, len(sortBy))
 		for i, v := range sortBy {
-			orderValue := 1
-			if strings.HasPrefix(v, "-") {
-				orderValue = -1
-				v = v[1:]
-			}
-			sortFields[i] = bson.E{
-				Key:   v,
-				Value: orderValue,
-			}
+			sortFields[i] = sortElement(v)
 		}
 	}
 	opts.SetSort(sortFields)
@@ -354,10 +480,12 @@ func (m *Mongo) findRows(ctx context.Context, table string, conds database.C, so
 }
 
 // Exist check if cond exist
-func (m *Mongo) Exist(ctx context.Context, table string, conds database.C) (bool, error) {
+func (m *Mongo) Exist(ctx context.Context, table string, conds database.C) (exist bool, err error) {
+	ctx, done := m.operation(ctx, table, "exist", conds)
+	defer func() { done(err) }()
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
-	err := col.FindOne(ctx, filter).Err()
+	err = col.FindOne(ctx, filter).Err()
 	if err != nil {
 		if IsNotFoundError(err) {
 			return false, nil
@@ -368,7 +496,9 @@ func (m *Mongo) Exist(ctx context.Context, table string, conds database.C) (bool
 }
 
 // Count data.
-func (m *Mongo) Count(ctx context.Context, table string, conds database.C) (int64, error) {
+func (m *Mongo) Count(ctx context.Context, table string, conds database.C) (count int64, err error) {
+	ctx, done := m.operation(ctx, table, "count", conds)
+	defer func() { done(err) }()
 	col := m.Collection(table)
 	filter := getCondition(m.project, conds)
 	ret, err := col.CountDocuments(ctx, filter)
@@ -379,7 +509,9 @@ func (m *Mongo) Count(ctx context.Context, table string, conds database.C) (int6
 }
 
 // IncrCounter incr counter
-func (m *Mongo) IncrCounter(ctx context.Context, counterTable, key string, start, count int64) error {
+func (m *Mongo) IncrCounter(ctx context.Context, counterTable, key string, start, count int64) (err error) {
+	ctx, done := m.operation(ctx, counterTable, "incrCounter", nil)
+	defer func() { done(err) }()
 	col := m.Collection(counterTable)
 	filter := getCondition(m.project, database.C{
 		{
@@ -388,7 +520,7 @@ func (m *Mongo) IncrCounter(ctx context.Context, counterTable, key string, start
 		},
 	})
 	var docsNew counter
-	err := col.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"count": count}}).Decode(&docsNew)
+	err = col.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"count": count}}).Decode(&docsNew)
 	if err != nil {
 		if IsNotFoundError(err) {
 			_, errInsert := col.InsertOne(ctx, counter{
@@ -418,7 +550,9 @@ type counter struct {
 }
 
 // DecrCounter Decr Counter
-func (m *Mongo) DecrCounter(ctx context.Context, counterTable, key string, count int64) error {
+func (m *Mongo) DecrCounter(ctx context.Context, counterTable, key string, count int64) (err error) {
+	ctx, done := m.operation(ctx, counterTable, "decrCounter", nil)
+	defer func() { done(err) }()
 	col := m.Collection(counterTable)
 	filter := getCondition(m.project, database.C{
 		{
@@ -427,7 +561,7 @@ func (m *Mongo) DecrCounter(ctx context.Context, counterTable, key string, count
 		},
 	})
 	var docsNew counter
-	err := col.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"count": -1 * count}}).Decode(&docsNew)
+	err = col.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"count": -1 * count}}).Decode(&docsNew)
 	if err != nil {
 		if IsNotFoundError(err) {
 			return nil
@@ -442,7 +576,9 @@ func (m *Mongo) DecrCounter(ctx context.Context, counterTable, key string, count
 }
 
 // GetCounter Decr Counter
-func (m *Mongo) GetCounter(ctx context.Context, counterTable, key string) (int64, error) {
+func (m *Mongo) GetCounter(ctx context.Context, counterTable, key string) (count int64, err error) {
+	ctx, done := m.operation(ctx, counterTable, "getCounter", nil)
+	defer func() { done(err) }()
 	col := m.Collection(counterTable)
 	doc := counter{}
 	filter := getCondition(m.project, database.C{
@@ -451,7 +587,7 @@ func (m *Mongo) GetCounter(ctx context.Context, counterTable, key string) (int64
 			Value: key,
 		},
 	})
-	err := col.FindOne(ctx, filter).Decode(&doc)
+	err = col.FindOne(ctx, filter).Decode(&doc)
 	if err != nil {
 		if IsNotFoundError(err) {
 			return 0, nil
@@ -461,37 +597,69 @@ func (m *Mongo) GetCounter(ctx context.Context, counterTable, key string) (int64
 	return doc.Count, nil
 }
 
-// StartTransaction start transaction
+// StartTransaction start transaction, the operations of the database of WithTransaction run in its session.
+// The transient errors are Aborted, so the transaction can be retried by database.RunInTransaction.
 func (m *Mongo) StartTransaction(ctx context.Context) (database.Transaction, error) {
 	session, err := m.StartSession()
 	if err != nil {
 		return nil, err
 	}
+	if err = session.StartTransaction(); err != nil {
+		session.EndSession(ctx)
+		return nil, status.Errorf(codes.Internal, "start transaction error %s", err)
+	}
+	// the span of the transaction is ended by the commit or rollback
+	ctx, done := m.tracer.Start(ctx, "", "transaction", nil)
 	return &sessionTransaction{
 		session: session,
 		ctx:     ctx,
+		done:    done,
 	}, nil
 }
 
+// sessionTransaction the transaction of StartTransaction, it implements database.AfterCommitter
 type sessionTransaction struct {
+	database.CommitHooks
 	session *mongo.Session
-	ctx     context.Context
+	// ctx the ctx of the span of the transaction, the spans of the operations in the transaction are its children
+	ctx      context.Context
+	done     func(error)
+	doneOnce sync.Once
+}
+
+// end ends the span of the transaction once, the rollback deferred after the commit does not end it again.
+func (s *sessionTransaction) end(err error) {
+	s.doneOnce.Do(func() {
+		s.done(err)
+	})
 }
 
 // Commit implements database.Transaction
 func (s *sessionTransaction) Commit() error {
 	err := s.session.CommitTransaction(s.ctx)
+	// the commit can be retried if its result is unknown
+	for i := 0; i < maxCommitRetries && hasErrorLabel(err, "UnknownTransactionCommitResult"); i++ {
+		err = s.session.CommitTransaction(s.ctx)
+	}
+	s.end(err)
+	s.session.EndSession(s.ctx)
 	if err != nil {
-		s.session.EndSession(s.ctx)
+		if IsTransientError(err) {
+			return status.Errorf(codes.Aborted, "transaction aborted %s", err)
+		}
 		return err
 	}
-	s.session.EndSession(s.ctx)
+	s.Committed()
 	return nil
 }
 
+// maxCommitRetries the max retries of the commit of an unknown result
+const maxCommitRetries = 3
+
 // Rollback implements database.Transaction
 func (s *sessionTransaction) Rollback() error {
 	err := s.session.AbortTransaction(s.ctx)
+	s.end(err)
 	if err != nil {
 		s.session.EndSession(s.ctx)
 		return err
@@ -500,17 +668,22 @@ func (s *sessionTransaction) Rollback() error {
 	return nil
 }
 
-// WithTransaction with transaction
+// WithTransaction with transaction, the calls of the database fail with InvalidArgument if the tx is not
+// started by StartTransaction.
 func (m *Mongo) WithTransaction(_ context.Context, tx database.Transaction) database.Database {
 	sessionTx, ok := tx.(*sessionTransaction)
 	if !ok {
-		panic("invalid transaction type")
+		return database.Failed(status.Errorf(codes.InvalidArgument, "%T is not a transaction of mongo", tx))
 	}
 	return &Mongo{
 		Client:          m.Client,
 		defaultDatabase: m.defaultDatabase,
 		project:         m.project,
 		session:         sessionTx,
+		tenancy:         m.tenancy,
+		tenantBase:      m.tenantBase,
+		observer:        m.observer,
+		tracer:          m.tracer,
 	}
 }
 
@@ -525,24 +698,106 @@ func getCondition(projectID string, conds database.C) bson.D {
 		})
 	}
 	for _, v := range conds {
-		key := fixQueryKey(v.Key)
-		value := v.Value
-		switch v.C {
-		case database.In:
-			value = bson.D{{Key: "$in", Value: value}}
-		case database.Nin:
-			value = bson.D{{Key: "$nin", Value: value}}
-		case database.Ne:
-			value = bson.D{{Key: "$ne", Value: value}}
-		}
-		cond = append(cond, bson.E{
-			Key:   key,
-			Value: value,
-		})
+		cond = append(cond, getConditionElement(v)...)
 	}
 	return cond
 }
 
+// getConditionElement convert the condition to the mongo filter elements.
+func getConditionElement(v database.CE) bson.D {
+	switch {
+	case v.C.IsGroup():
+		return getConditionGroup(v)
+	case v.C == database.JSONMatch:
+		var cond bson.D
+		for _, sub := range v.JSONMatches() {
+			cond = append(cond, getConditionElement(sub)...)
+		}
+		return cond
+	}
+	key := fixQueryKey(v.Key)
+	value := v.Value
+	switch v.C {
+	case database.In:
+		value = bson.D{{Key: "$in", Value: value}}
+	case database.Nin:
+		value = bson.D{{Key: "$nin", Value: value}}
+	case database.Ne:
+		value = bson.D{{Key: "$ne", Value: value}}
+	case database.Lt:
+		value = bson.D{{Key: "$lt", Value: value}}
+	case database.Lte:
+		value = bson.D{{Key: "$lte", Value: value}}
+	case database.Gt:
+		value = bson.D{{Key: "$gt", Value: value}}
+	case database.Gte:
+		value = bson.D{{Key: "$gte", Value: value}}
+	case database.Like:
+		value = bson.Regex{Pattern: database.LikeToRegex(fmt.Sprint(value))}
+	case database.Regex:
+		value = bson.Regex{Pattern: fmt.Sprint(value)}
+	case database.Exists:
+		// the same as IS NOT NULL and IS NULL of sql, null values are treated as not exist
+		if v.ShouldExist() {
+			value = bson.D{{Key: "$ne", Value: nil}}
+		} else {
+			value = bson.D{{Key: "$eq", Value: nil}}
+		}
+	case database.Between:
+		from, to, err := v.Range()
+		if err != nil {
+			slog.Warn("invalid between condition", "error", err)
+			return matchNothing
+		}
+		value = bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}
+	case database.ArrayContains:
+		value = bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$eq", Value: value}}}}
+	case database.Search:
+		// the fields are decided by the text index of the collection
+		return bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: v.SearchText()}}}}
+	}
+	return bson.D{{Key: key, Value: value}}
+}
+
+// sortElement convert the sort key to the mongo sort, -key is descending, the database.SearchScore sorts by
+// the text score, which is always the most relevant first.
+func sortElement(v string) bson.E {
+	if strings.TrimPrefix(v, "-") == database.SearchScore {
+		return bson.E{Key: database.SearchScore, Value: bson.D{{Key: "$meta", Value: "textScore"}}}
+	}
+	if strings.HasPrefix(v, "-") {
+		return bson.E{Key: v[1:], Value: -1}
+	}
+	return bson.E{Key: v, Value: 1}
+}
+
+// getConditionGroup convert the Or, And, Not groups to $or, $and and $nor of $and,
+// an empty Or or Not group matches nothing and an empty And group matches everything.
+func getConditionGroup(v database.CE) bson.D {
+	group := v.Group()
+	if len(group) == 0 {
+		if v.C == database.And {
+			return nil
+		}
+		return matchNothing
+	}
+	filters := make(bson.A, len(group))
+	for i, sub := range group {
+		filters[i] = getConditionElement(sub)
+	}
+	switch v.C {
+	case database.Or:
+		return bson.D{{Key: "$or", Value: filters}}
+	case database.Not:
+		return bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "$and", Value: filters}}}}}
+	default:
+		return bson.D{{Key: "$and", Value: filters}}
+	}
+}
+
+// matchNothing the filter which matches no documents
+var matchNothing = bson.D{{Key: "$expr", Value: false}}
+
 func convertDocs(src database.D) bson.D {
 	result := make(bson.D, len(src))
 	for i, v := range src {
@@ -593,8 +848,10 @@ func (m *monogRow) Close() error {
 // Decode implements database.Row
 func (m *monogRow) Decode() (any, error) {
 	data := reflect.New(m.dataType).Interface()
-	err := m.cur.Decode(data)
-	return data, err
+	if err := m.cur.Decode(data); err != nil {
+		return data, err
+	}
+	return data, database.DecryptFields(data)
 }
 
 // Next implements database.Row
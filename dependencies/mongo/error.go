@@ -17,6 +17,16 @@ func IsNotFoundError(err error) bool {
 	return false
 }
 
+func hasErrorLabel(err error, label string) bool {
+	var labeled mongo.LabeledError
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
+
+// IsTransientError check if the error has the TransientTransactionError label, the transaction can be retried
+func IsTransientError(err error) bool {
+	return hasErrorLabel(err, "TransientTransactionError")
+}
+
 // IsConflictError check if it is not found
 func IsConflictError(err error) bool {
 	if err == nil {
@@ -14,6 +14,8 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Mongo the mongo client
@@ -22,6 +24,11 @@ type Mongo struct {
 	defaultDatabase string
 	project         string
 	session         *sessionTransaction
+	tenancy         database.Tenancy
+	// tenantBase the default database of the uri, the database of a tenant is named by it and the project
+	tenantBase string
+	observer   *database.Observer
+	tracer     *database.Tracer
 }
 
 func init() {
@@ -31,7 +38,8 @@ func init() {
 	})
 }
 
-// New client
+// New client, exp: mongodb://127.0.0.1:27017/app, GetDatabase binds the project to its own database
+// app_<project> with mongodb://127.0.0.1:27017/app?tenancy=database.
 func New(ctx context.Context, uri string) (*Mongo, error) {
 	m := &Mongo{}
 	u, err := url.Parse(uri)
@@ -43,6 +51,20 @@ func New(ctx context.Context, uri string) (*Mongo, error) {
 
 // Init the mongo client
 func (m *Mongo) Init(ctx context.Context, u *url.URL) error {
+	tenancy, err := database.ParseTenancy(u)
+	if err != nil {
+		return err
+	}
+	if tenancy == database.TenancySchema {
+		return status.Error(codes.InvalidArgument, "tenancy=schema is not supported by mongo, use tenancy=database")
+	}
+	// slowQuery=200ms logs the slow operations, explain=true attaches the plans of the slow queries
+	observer, err := database.ParseObserver("mongo", u)
+	if err != nil {
+		return err
+	}
+	// tracing=true starts the spans of the operations
+	tracer := database.ParseTracer("mongodb", u)
 	uri := u.String()
 	opts := options.Client().
 		ApplyURI(uri).
@@ -78,14 +100,18 @@ func (m *Mongo) Init(ctx context.Context, u *url.URL) error {
 		m.defaultDatabase = defaultDatabase
 	}
 	m.Client = mongoClient
+	m.tenancy = tenancy
+	m.tenantBase = m.defaultDatabase
+	m.observer = observer
+	m.tracer = tracer
 	return nil
 }
 
-// transformDocument transform any object to bson documents
-func (m *Mongo) transformData(val any, forceDoc bool) any {
+// transformDocument transform any object to bson documents, it returns the error of the encrypted fields.
+func (m *Mongo) transformData(val any, forceDoc bool) (any, error) {
 	hasAnonymous := reflect.ValueOf(val).Elem().Type().Field(0).Anonymous
-	if !forceDoc && !hasAnonymous && m.project == "" {
-		return val
+	if !forceDoc && !hasAnonymous && m.project == "" && database.EncryptedFields(val) == nil {
+		return val, nil
 	}
 	return transformDocument(val, m.project, hasAnonymous)
 }
@@ -111,7 +137,7 @@ func isFirstFieldAnonymous(val any) (firstField, newValue reflect.Value, isPoint
 }
 
 // transformDocument transform any object to bson documents
-func transformDocument(val any, project string, hasAnonymous bool) bson.D {
+func transformDocument(val any, project string, hasAnonymous bool) (bson.D, error) {
 	dis, err := codecs.EncodeToDocument(val)
 	if err != nil {
 		panic(err)
@@ -121,10 +147,31 @@ func transformDocument(val any, project string, hasAnonymous bool) bson.D {
 		dis = dis[0].Value.(bson.D)
 		dis = append(dis, disOther...)
 	}
+	if fields := database.EncryptedFields(val); fields != nil {
+		if dis, err = encryptDocument(dis, fields); err != nil {
+			return nil, err
+		}
+	}
 	if project != "" {
 		dis = append(bson.D{{Key: "project", Value: project}}, dis...)
 	}
-	return dis
+	return dis, nil
+}
+
+// encryptDocument encrypts the values of the encrypted fields in place, see database.EncryptOf, the plain
+// values are never written if a field can not be encrypted.
+func encryptDocument(dis bson.D, fields map[string]bool) (bson.D, error) {
+	for i, v := range dis {
+		if deterministic, ok := fields[v.Key]; ok && v.Value != nil {
+			value, err := database.EncryptValue(reflect.ValueOf(v.Value), deterministic)
+			if err != nil {
+				return nil, status.Errorf(status.Code(err), "encrypt the field %s error %s",
+					v.Key, status.Convert(err).Message())
+			}
+			dis[i].Value = value
+		}
+	}
+	return dis, nil
 }
 
 // Collection get Collection
@@ -6,7 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ti/common-go/dependencies/database"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // TestMongo test database json mapping problem
@@ -51,3 +54,110 @@ func TestMongo(t *testing.T) {
 	}
 	_ = col.Drop(ctx)
 }
+
+func TestSetObjectID(t *testing.T) {
+	type objectIDModel struct {
+		ID bson.ObjectID `json:"_id"`
+	}
+	type base64Model struct {
+		ID string `json:"_id"`
+	}
+	doc := &objectIDModel{}
+	setObjectID(doc)
+	if doc.ID.IsZero() {
+		t.Fatal("the object id is not set")
+	}
+	b64 := &base64Model{}
+	setObjectID(b64)
+	if _, err := ObjectIDFromBase64(b64.ID); err != nil {
+		t.Fatalf("the base64 object id %q is invalid %v", b64.ID, err)
+	}
+	// the id of the caller is kept
+	b64 = &base64Model{ID: "custom"}
+	setObjectID(b64)
+	if b64.ID != "custom" {
+		t.Fatalf("the id of the caller is changed to %s", b64.ID)
+	}
+}
+
+func TestTransformEncryptedDocument(t *testing.T) {
+	type customer struct {
+		Name    string  `json:"name"`
+		Phone   string  `json:"phone" db:"encrypt=deterministic"`
+		Address *string `json:"address" db:"encrypt"`
+	}
+	database.SetKeyProvider(&database.StaticKeys{Current: "k1", Keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef"),
+	}})
+	t.Cleanup(func() {
+		database.SetKeyProvider(nil)
+	})
+	m := &Mongo{}
+	data, err := m.transformData(&customer{Name: "alice", Phone: "+1234567890"}, false)
+	doc, ok := data.(bson.D)
+	if err != nil || !ok {
+		t.Fatal("the model of the encrypted fields is not transformed")
+	}
+	phone, _ := database.Encrypt("+1234567890", true)
+	want := bson.D{{Key: "name", Value: "alice"}, {Key: "phone", Value: phone}, {Key: "address", Value: nil}}
+	if len(doc) != len(want) {
+		t.Fatalf("unexpected doc %v", doc)
+	}
+	for i, v := range want {
+		if doc[i] != v {
+			t.Fatalf("unexpected element %v, want %v", doc[i], v)
+		}
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found customer
+	if err = bson.Unmarshal(raw, &found); err != nil {
+		t.Fatal(err)
+	}
+	if err = database.DecryptFields(&found); err != nil || found.Phone != "+1234567890" {
+		t.Fatalf("unexpected decrypted customer %+v error %v", found, err)
+	}
+
+	// the write fails if the field can not be encrypted
+	database.SetKeyProvider(nil)
+	if _, err = m.transformData(&customer{Name: "alice", Phone: "+1234567890"}, false); status.Code(err) !=
+		codes.FailedPrecondition {
+		t.Fatalf("expected failed precondition, got %v", err)
+	}
+	if _, err = m.convertUpdateDoc(&customer{Phone: "+1234567890"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected failed precondition, got %v", err)
+	}
+}
+
+func TestLikeCondition(t *testing.T) {
+	cond := getConditionElement(database.CE{Key: "name", Value: `a\_b%`, C: database.Like})
+	regex, ok := cond[0].Value.(bson.Regex)
+	if !ok || regex.Pattern != `(?i)^a_b.*$` {
+		t.Fatalf("unexpected like condition %v", cond)
+	}
+}
+
+func TestRegexExistsCondition(t *testing.T) {
+	// the regex is case-sensitive and the null values do not exist, the same as sql and the mock
+	cond := getConditionElement(database.CE{Key: "name", Value: "^a", C: database.Regex})
+	if regex, ok := cond[0].Value.(bson.Regex); !ok || regex.Pattern != "^a" || regex.Options != "" {
+		t.Fatalf("unexpected regex condition %v", cond)
+	}
+	cond = getConditionElement(database.CE{Key: "profile.nick", Value: true, C: database.Exists})
+	if v, ok := cond[0].Value.(bson.D); !ok || v[0].Key != "$ne" || v[0].Value != nil {
+		t.Fatalf("unexpected exists condition %v", cond)
+	}
+}
+
+func TestTransactionEnd(t *testing.T) {
+	var ended int
+	tx := &sessionTransaction{done: func(error) { ended++ }}
+	// the rollback deferred after the commit does not end the span again
+	tx.end(nil)
+	tx.end(status.Error(codes.FailedPrecondition, "the transaction is committed"))
+	if ended != 1 {
+		t.Fatalf("expected the span ended once, got %d", ended)
+	}
+}
@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type operationKey struct{}
+
+// explainedOps the operations whose query plans are explained by the find command
+var explainedOps = map[string]bool{
+	"find":        true,
+	"findOne":     true,
+	"findRows":    true,
+	"exist":       true,
+	"count":       true,
+	"pageQuery":   true,
+	"streamQuery": true,
+}
+
+// operation binds the ctx to the session of the transaction, and starts to observe and trace the operation of
+// a method, the done records it and ends its span with the error of the method. The operations of the methods
+// called by the method are not recorded, for exp: Insert of one doc calls InsertOne.
+func (m *Mongo) operation(ctx context.Context, table, op string, conds database.C) (context.Context, func(error)) {
+	if m.session != nil {
+		// the operations of WithTransaction run in the session of the transaction
+		ctx = mongo.NewSessionContext(ctx, m.session.session)
+	}
+	if (m.observer == nil && m.tracer == nil) || ctx.Value(operationKey{}) != nil {
+		return ctx, func(error) {}
+	}
+	start := time.Now()
+	ctx = context.WithValue(ctx, operationKey{}, op)
+	if m.session != nil {
+		// the spans of the operations in the transaction are the children of the span of the transaction
+		ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(m.session.ctx))
+	}
+	ctx, end := m.tracer.Start(ctx, table, op, conds)
+	return ctx, func(err error) {
+		end(err)
+		if m.observer == nil {
+			return
+		}
+		var explain func(ctx context.Context) (string, error)
+		if explainedOps[op] {
+			explain = func(ctx context.Context) (string, error) {
+				return m.explain(ctx, table, conds)
+			}
+		}
+		m.observer.Observe(ctx, table, op, conds, start, explain)
+	}
+}
+
+// explain returns the winning plan of the find of the conditions, it runs out of the session of the
+// transaction, as explain is not allowed in a transaction and the session is used by the caller.
+func (m *Mongo) explain(ctx context.Context, table string, conds database.C) (string, error) {
+	ctx = mongo.NewSessionContext(ctx, nil)
+	var result bson.Raw
+	err := m.DefaultDatabase().RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: table},
+			{Key: "filter", Value: getCondition(m.project, conds)},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&result)
+	if err != nil {
+		return "", err
+	}
+	plan, err := result.LookupErr("queryPlanner", "winningPlan")
+	if err != nil {
+		return result.String(), nil
+	}
+	return plan.String(), nil
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/json/v2"
 	"fmt"
 	"log/slog"
+	"reflect"
 	"strconv"
-	"strings"
 
 	"github.com/ti/common-go/dependencies/database"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -19,14 +19,30 @@ import (
 // PageQuery query the documents
 func PageQuery[T any](ctx context.Context, s *Mongo, table string,
 	in *database.PageQueryRequest,
-) (out *database.PageQueryResponse[T], err error) {
-	out = &database.PageQueryResponse[T]{}
-	col := s.Collection(table)
-	var limit int64
-	var filter bson.D
-	out.Total, limit, filter, err = parseQuery(ctx, col, s.project, in.Filters, int64(in.Limit), in.NoCount)
-	if !in.NoCount && out.Total == 0 {
-		return
+) (*database.PageQueryResponse[T], error) {
+	out := &database.PageQueryResponse[T]{}
+	if err := s.DoPageQuery(ctx, table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DoPageQuery implements database.PageQuerier, the result is a *database.PageQueryResponse[T]
+func (m *Mongo) DoPageQuery(ctx context.Context, table string, in *database.PageQueryRequest, result any) (err error) {
+	ctx, done := m.operation(ctx, table, "pageQuery", in.Filters)
+	defer func() { done(err) }()
+	out, err := database.NewQueryResult(result)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	col := m.Collection(table)
+	total, limit, filter, err := parseQuery(ctx, col, m.project, in.Filters, int64(in.Limit), in.NoCount)
+	if err != nil {
+		return err
+	}
+	out.SetTotal(total)
+	if !in.NoCount && total == 0 {
+		return nil
 	}
 	selectParams, distinct := parseSelectAndDistinct(in.Select)
 	var skip int
@@ -41,19 +57,9 @@ func PageQuery[T any](ctx context.Context, s *Mongo, table string,
 		opts.SetProjection(selectParams)
 	}
 	if len(in.Sort) > 0 {
-		sortDoc := bson.D{}
-		for _, v := range in.Sort {
-			if strings.HasPrefix(v, "-") {
-				sortDoc = append(sortDoc, bson.E{
-					Key:   v[1:],
-					Value: -1,
-				})
-			} else {
-				sortDoc = append(sortDoc, bson.E{
-					Key:   v,
-					Value: 1,
-				})
-			}
+		sortDoc := make(bson.D, len(in.Sort))
+		for i, v := range in.Sort {
+			sortDoc[i] = sortElement(v)
 		}
 		opts.SetSort(sortDoc)
 	} else {
@@ -65,7 +71,7 @@ func PageQuery[T any](ctx context.Context, s *Mongo, table string,
 		})
 	}
 	if distinct != "" {
-		return parseDistinct[T](ctx, col, distinct)
+		return parseDistinct(ctx, col, distinct, out)
 	}
 	if limit > 0 {
 		opts.SetLimit(limit)
@@ -73,29 +79,32 @@ func PageQuery[T any](ctx context.Context, s *Mongo, table string,
 	cur, errFind := col.Find(ctx, filter, opts)
 	if errFind != nil {
 		if IsNotFoundError(errFind) {
-			return nil, status.Error(codes.NotFound, "no data")
+			return status.Error(codes.NotFound, "no data")
 		}
-		return nil, status.Errorf(codes.Internal, "db find error %s", errFind)
+		return status.Errorf(codes.Internal, "db find error %s", errFind)
 	}
 	err = parseData(ctx, out, cur)
-	if out.Total == 0 {
-		out.Total = int64(len(out.Data))
+	if out.Total() == 0 {
+		out.SetTotal(int64(out.Len()))
 	}
-	return
+	return err
 }
 
-func parseData[T any](ctx context.Context, out *database.PageQueryResponse[T], cur *mongo.Cursor,
+func parseData(ctx context.Context, out *database.QueryResult, cur *mongo.Cursor,
 ) error {
 	defer func() {
 		_ = cur.Close(ctx)
 	}()
 	for cur.Next(ctx) {
-		result := new(T)
+		result := out.New()
 		err := cur.Decode(result)
 		if err != nil {
 			return status.Errorf(codes.Internal, "find cursor error %s", err)
 		}
-		out.Data = append(out.Data, result)
+		if err = database.DecryptFields(result); err != nil {
+			return err
+		}
+		out.Append(result)
 	}
 	return nil
 }
@@ -118,18 +127,16 @@ func parseSelectAndDistinct(selectIn []string) (selectParams map[string]int, dis
 	return
 }
 
-func parseDistinct[T any](ctx context.Context, collection *mongo.Collection,
-	distinct string,
-) (*database.PageQueryResponse[T], error) {
+func parseDistinct(ctx context.Context, collection *mongo.Collection,
+	distinct string, out *database.QueryResult,
+) error {
 	var ret []any
 	if err := collection.Distinct(ctx, distinct, bson.M{}).Decode(&ret); err != nil {
-		return nil, err
-	}
-	retDistinct := &database.PageQueryResponse[T]{
-		Total: 0,
+		return err
 	}
+	out.SetTotal(0)
 	if len(ret) == 0 {
-		return retDistinct, nil
+		return nil
 	}
 	var retJSON string
 	switch t := ret[0].(type) {
@@ -146,13 +153,13 @@ func parseDistinct[T any](ctx context.Context, collection *mongo.Collection,
 	default:
 		slog.Warn("MgoQuery unknown type", "type", t)
 	}
-	retDistinct.Total = int64(len(ret))
-	var result []*T
-	if err := json.Unmarshal([]byte(retJSON), &result); err != nil {
-		return nil, fmt.Errorf("conver json %s error %w", retJSON, err)
+	out.SetTotal(int64(len(ret)))
+	result := reflect.New(out.Data().Type())
+	if err := json.Unmarshal([]byte(retJSON), result.Interface()); err != nil {
+		return fmt.Errorf("conver json %s error %w", retJSON, err)
 	}
-	retDistinct.Data = result
-	return retDistinct, nil
+	out.SetData(result.Elem())
+	return nil
 }
 
 type kv struct {
@@ -17,14 +17,39 @@ import (
 // StreamQuery query the documents
 func StreamQuery[T any](ctx context.Context, mgo *Mongo, table string,
 	in *database.StreamQueryRequest,
-) (out *database.StreamResponse[T], err error) {
-	col := mgo.Collection(table)
-	out = &database.StreamResponse[T]{}
-	var limit int64
-	var filter bson.D
-	out.Total, limit, filter, err = parseQuery(ctx, col, mgo.project, in.Filters, int64(in.Limit), in.NoCount)
-	if !in.NoCount && out.Total == 0 {
-		return
+) (*database.StreamResponse[T], error) {
+	out := &database.StreamResponse[T]{}
+	if err := mgo.DoStreamQuery(ctx, table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DoStreamQuery implements database.StreamQuerier, the result is a *database.StreamResponse[T]
+func (m *Mongo) DoStreamQuery(ctx context.Context, table string, in *database.StreamQueryRequest,
+	result any,
+) (err error) {
+	ctx, done := m.operation(ctx, table, "streamQuery", in.Filters)
+	defer func() { done(err) }()
+	out, err := database.NewQueryResult(result)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if database.HasSearchScore(in.Sort) {
+		return status.Errorf(codes.InvalidArgument, "%s can not be a sort key of the stream query",
+			database.SearchScore)
+	}
+	col := m.Collection(table)
+	total, limit, filter, err := parseQuery(ctx, col, m.project, in.Filters, int64(in.Limit), in.NoCount)
+	if err != nil {
+		return err
+	}
+	out.SetTotal(total)
+	if !in.NoCount && total == 0 {
+		return nil
+	}
+	if len(in.Sort) > 0 {
+		return m.doSortedStreamQuery(ctx, col, in, out, filter, limit)
 	}
 	opts := options.Find().SetLimit(limit)
 	parseSelect(opts, in.Select)
@@ -52,25 +77,115 @@ func StreamQuery[T any](ctx context.Context, mgo *Mongo, table string,
 		var pageToken PageToken
 		err = decodeConditionPageToken(in.PageToken, &pageToken)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if pageToken.PageLastValue == nil {
-			return out, nil
+			return nil
 		}
 		filter = append(filter, pageTokenToFilter(in.PageField, in.Ascending, &pageToken)...)
 	}
 	cur, errFind := col.Find(ctx, filter, opts)
 	if errFind != nil {
 		if IsNotFoundError(errFind) {
-			return nil, status.Error(codes.NotFound, "no data")
+			return status.Error(codes.NotFound, "no data")
 		}
-		return nil, status.Errorf(codes.Internal, "db find error %s", errFind)
+		return status.Errorf(codes.Internal, "db find error %s", errFind)
 	}
 	err = decodeData(ctx, in, out, cur)
-	if out.Total == 0 {
-		out.Total = int64(len(out.Data))
+	if out.Total() == 0 {
+		out.SetTotal(int64(out.Len()))
 	}
-	return
+	return err
+}
+
+// doSortedStreamQuery the stream query sorted by the keys of in.Sort and _id, the page token holds
+// the sort values and the _id of the last doc.
+func (m *Mongo) doSortedStreamQuery(ctx context.Context, col *mongo.Collection, in *database.StreamQueryRequest,
+	out *database.QueryResult, filter bson.D, limit int64,
+) error {
+	sort := database.WithTieBreaker(in.Sort, docID)
+	if in.PageToken != "" {
+		values, err := database.DecodeSortToken(in.PageToken, sort)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		// the ObjectID is encoded as the hex string
+		if hex, ok := values[len(values)-1].(string); ok {
+			if id, errID := bson.ObjectIDFromHex(hex); errID == nil {
+				values[len(values)-1] = id
+			}
+		}
+		filter = append(filter, getCondition("", database.C{database.KeysetCondition(sort, values, false)})...)
+	}
+	keys, desc := database.SortKeys(sort)
+	sortDoc := make(bson.D, len(keys))
+	for i, key := range keys {
+		sortDoc[i] = bson.E{Key: key, Value: 1}
+		if desc[i] {
+			sortDoc[i].Value = -1
+		}
+	}
+	opts := options.Find().SetLimit(limit).SetSort(sortDoc)
+	parseSelect(opts, database.SelectSortKeys(in.Select, in.Sort))
+	cur, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return status.Errorf(codes.Internal, "db find error %s", err)
+	}
+	defer func() {
+		_ = cur.Close(ctx)
+	}()
+	var last bson.Raw
+	for cur.Next(ctx) {
+		result := out.New()
+		if err = cur.Decode(result); err != nil {
+			return status.Errorf(codes.Internal, "find cursor error %s", err)
+		}
+		if err = database.DecryptFields(result); err != nil {
+			return err
+		}
+		last = cur.Current
+		out.Append(result)
+	}
+	if err = cur.Err(); err != nil {
+		return status.Errorf(codes.Internal, "find cursor error %s", err)
+	}
+	if out.Total() == 0 {
+		out.SetTotal(int64(out.Len()))
+	}
+	if int64(out.Len()) < limit {
+		return nil
+	}
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = sortValue(last, key)
+	}
+	pageToken, err := database.EncodeSortToken(sort, values)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	out.SetPageToken(pageToken)
+	return nil
+}
+
+// sortValue the value of the key of the doc for the page token, it is nil if the key is missing.
+func sortValue(doc bson.Raw, key string) any {
+	raw, err := doc.LookupErr(strings.Split(key, ".")...)
+	if err != nil {
+		return nil
+	}
+	switch raw.Type {
+	case bson.TypeObjectID:
+		return raw.ObjectID().Hex()
+	case bson.TypeDateTime:
+		return raw.Time()
+	case bson.TypeNull, bson.TypeUndefined:
+		return nil
+	}
+	var value any
+	if err = raw.Unmarshal(&value); err != nil {
+		return nil
+	}
+	return value
 }
 
 func parseQuery(ctx context.Context, col *mongo.Collection,
@@ -124,8 +239,8 @@ func parseSelect(opts *options.FindOptionsBuilder, selectData []string) {
 	}
 }
 
-func decodeData[T any](ctx context.Context, in *database.StreamQueryRequest,
-	out *database.StreamResponse[T], cur *mongo.Cursor,
+func decodeData(ctx context.Context, in *database.StreamQueryRequest,
+	out *database.QueryResult, cur *mongo.Cursor,
 ) error {
 	if in.PageField == "" {
 		in.PageField = docID
@@ -140,7 +255,7 @@ func decodeData[T any](ctx context.Context, in *database.StreamQueryRequest,
 	for {
 		hasNext := cur.Next(ctx)
 		if !hasNext {
-			if last != nil && len(out.Data) == in.Limit {
+			if last != nil && out.Len() == in.Limit {
 				if in.PageField == docID {
 					pageToken.PageLastValue = getDocObjectIDFromCursor(last)
 				} else {
@@ -149,12 +264,15 @@ func decodeData[T any](ctx context.Context, in *database.StreamQueryRequest,
 			}
 			break
 		}
-		result := new(T)
+		result := out.New()
 		err := cur.Decode(result)
 		lastResult = result
 		if err != nil {
 			return status.Errorf(codes.Internal, "find cursor error %s", err)
 		}
+		if err = database.DecryptFields(result); err != nil {
+			return err
+		}
 		if i == 0 && in.PageToken != "" {
 			if in.PageField == docID {
 				pageToken.PageFirstValue = getDocObjectIDFromCursor(cur)
@@ -165,10 +283,10 @@ func decodeData[T any](ctx context.Context, in *database.StreamQueryRequest,
 			last = cur
 			lastResult = result
 		}
-		out.Data = append(out.Data, result)
+		out.Append(result)
 		i++
 	}
-	out.PageToken = pageToken.String()
+	out.SetPageToken(pageToken.String())
 	return nil
 }
 
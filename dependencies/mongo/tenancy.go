@@ -0,0 +1,67 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tenantCollection the collection which is created by ProvisionTenant, mongo does not list a database
+// until it has a collection.
+const tenantCollection = "_tenant"
+
+// codeNamespaceExists the error code of creating an existing collection
+const codeNamespaceExists = 48
+
+// ProvisionTenant implements database.TenantManager, it creates the database of the project,
+// it is a no-op for tenancy=column.
+func (m *Mongo) ProvisionTenant(ctx context.Context, project string) error {
+	if m.tenancy != database.TenancyDatabase {
+		return nil
+	}
+	name, err := database.TenantName(m.tenantBase, project)
+	if err != nil {
+		return err
+	}
+	err = m.Database(name).CreateCollection(ctx, tenantCollection)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == codeNamespaceExists {
+		return nil
+	}
+	if err != nil {
+		return convertToStatusError(tenantCollection, err)
+	}
+	return nil
+}
+
+// ListTenants implements database.TenantManager, it returns the projects of the databases which are named
+// by database.TenantName.
+func (m *Mongo) ListTenants(ctx context.Context) ([]string, error) {
+	if m.tenancy != database.TenancyDatabase {
+		return nil, status.Error(codes.FailedPrecondition, "the tenants are listed with tenancy=database")
+	}
+	prefix := m.tenantBase + "_"
+	names, err := m.ListDatabaseNames(ctx, bson.D{{Key: "name", Value: bson.D{
+		{Key: "$regex", Value: "^" + regexp.QuoteMeta(prefix)},
+	}}})
+	if err != nil {
+		return nil, convertToStatusError("tenants", err)
+	}
+	projects := make([]string, 0, len(names))
+	for _, name := range names {
+		project := strings.TrimPrefix(name, prefix)
+		if _, err = database.TenantName(m.tenantBase, project); err == nil {
+			projects = append(projects, project)
+		}
+	}
+	slices.Sort(projects)
+	return projects, nil
+}
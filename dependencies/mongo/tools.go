@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ti/common-go/dependencies/database"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -41,6 +42,9 @@ type Index struct {
 	ReverseOrder bool
 	Unique       bool
 	Expires      time.Duration
+	// Text the full-text index of the fields for the database.Search condition, a collection has one
+	// text index at most.
+	Text bool
 }
 
 // EnsureIndex creates an index
@@ -48,10 +52,13 @@ type Index struct {
 func EnsureIndex(ctx context.Context, col *mongo.Collection, indexs ...*Index) (err error) {
 	indexKeys := make([]mongo.IndexModel, len(indexs))
 	for i, v := range indexs {
-		value := 1
+		var value any = 1
 		if v.ReverseOrder {
 			value = -1
 		}
+		if v.Text {
+			value = "text"
+		}
 		opts := options.Index()
 		fields := strings.Split(v.Field, ",")
 		if v.Field == "" {
@@ -63,6 +70,9 @@ func EnsureIndex(ctx context.Context, col *mongo.Collection, indexs ...*Index) (
 			partialFilter[i] = bson.E{Key: v, Value: bson.D{{Key: "$exists", Value: true}}}
 			indexName += "_" + v
 		}
+		if v.Text {
+			indexName = "text" + indexName
+		}
 		opts.SetName(indexName)
 		if v.Unique {
 			opts.SetUnique(v.Unique)
@@ -90,3 +100,55 @@ func EnsureIndex(ctx context.Context, col *mongo.Collection, indexs ...*Index) (
 	}
 	return nil
 }
+
+// namespaceNotFound the error code of listing the indexes of a collection which does not exist
+const namespaceNotFound = 26
+
+// CreateIndex implements database.Indexer, the ttl index is the TTL index of mongo, and a unique index is
+// partial to the docs which have the fields, as EnsureIndex.
+func (m *Mongo) CreateIndex(ctx context.Context, table string, index *database.Index) error {
+	var value any = 1
+	if index.ReverseOrder {
+		value = -1
+	}
+	keys := make(bson.D, len(index.Fields))
+	partialFilter := make(bson.D, len(index.Fields))
+	for i, v := range index.Fields {
+		keys[i] = bson.E{Key: v, Value: value}
+		partialFilter[i] = bson.E{Key: v, Value: bson.D{{Key: "$exists", Value: true}}}
+	}
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+		opts.SetPartialFilterExpression(partialFilter)
+	}
+	if index.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(index.TTL / time.Second))
+	}
+	_, err := m.Collection(table).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts})
+	if err != nil {
+		return fmt.Errorf("create index %s for %s error for %w", index.Name, table, err)
+	}
+	return nil
+}
+
+// IndexNames implements database.Indexer, the leading "_" of the index names of EnsureIndex is removed, so
+// they are the names of CreateIndex.
+func (m *Mongo) IndexNames(ctx context.Context, table string) ([]string, error) {
+	specs, err := m.Collection(table).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		var se mongo.ServerError
+		if errors.As(err, &se) && se.HasErrorCode(namespaceNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, spec := range specs {
+		if spec.Name == "_id_" {
+			continue
+		}
+		names = append(names, strings.TrimPrefix(spec.Name, "_"))
+	}
+	return names, nil
+}
@@ -0,0 +1,119 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changeStreamEvent the fields of the change stream event
+type changeStreamEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID any `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument bson.M `bson:"fullDocument"`
+}
+
+// Watch implements database.Watcher with the change streams, which requires a replica set or a sharded cluster.
+// The resume token is the base64 of the change stream resume token.
+func (m *Mongo) Watch(ctx context.Context, table string, filter database.C,
+	opts ...database.WatchOption,
+) (<-chan database.ChangeEvent, error) {
+	o := database.NewWatchOptions(opts...)
+	streamOpts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if o.ResumeToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(o.ResumeToken)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid resume token")
+		}
+		streamOpts.SetResumeAfter(bson.Raw(token))
+	}
+	match := bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{
+		string(database.OpInsert), string(database.OpUpdate), string(database.OpReplace), string(database.OpDelete),
+	}}}}}
+	if docFilter := getCondition(m.project, filter); len(docFilter) > 0 {
+		// the delete events have no full document to match
+		match = append(match, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "operationType", Value: string(database.OpDelete)}},
+			prefixFilterKeys("fullDocument.", docFilter),
+		}})
+	}
+	stream, err := m.Collection(table).Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}}, streamOpts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "watch %s error %s", table, err)
+	}
+	out := make(chan database.ChangeEvent, o.BufferSize)
+	go func() {
+		defer close(out)
+		defer func() {
+			_ = stream.Close(context.WithoutCancel(ctx))
+		}()
+		for stream.Next(ctx) {
+			var event changeStreamEvent
+			if err := stream.Decode(&event); err != nil {
+				sendChangeEvent(ctx, out, database.ChangeEvent{
+					Err: status.Errorf(codes.Internal, "decode change event error %s", err),
+				})
+				return
+			}
+			changeEvent := database.ChangeEvent{
+				Op:          database.ChangeOp(event.OperationType),
+				Table:       table,
+				Key:         event.DocumentKey.ID,
+				ResumeToken: base64.RawURLEncoding.EncodeToString(stream.ResumeToken()),
+			}
+			if event.FullDocument != nil {
+				changeEvent.Document = event.FullDocument
+			}
+			if !sendChangeEvent(ctx, out, changeEvent) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			sendChangeEvent(ctx, out, database.ChangeEvent{
+				Err: status.Errorf(codes.Internal, "watch %s error %s", table, err),
+			})
+		}
+	}()
+	return out, nil
+}
+
+func sendChangeEvent(ctx context.Context, out chan<- database.ChangeEvent, event database.ChangeEvent) bool {
+	select {
+	case out <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// prefixFilterKeys prefix the field keys of the filter, the keys of $or, $and and $nor are prefixed recursively.
+func prefixFilterKeys(prefix string, filter bson.D) bson.D {
+	result := make(bson.D, len(filter))
+	for i, e := range filter {
+		if !strings.HasPrefix(e.Key, "$") {
+			result[i] = bson.E{Key: prefix + e.Key, Value: e.Value}
+			continue
+		}
+		result[i] = e
+		if subFilters, ok := e.Value.(bson.A); ok {
+			prefixed := make(bson.A, len(subFilters))
+			for j, sub := range subFilters {
+				if subFilter, ok := sub.(bson.D); ok {
+					sub = prefixFilterKeys(prefix, subFilter)
+				}
+				prefixed[j] = sub
+			}
+			result[i].Value = prefixed
+		}
+	}
+	return result
+}
@@ -123,23 +123,16 @@ func (l *Lru) Init(ctx context.Context, u *url.URL) error {
 	return err
 }
 
-// Set with ttl
+// Set with ttl, the data is sent to the other instances by the mq
 func (l *Lru) Set(ctx context.Context, key string, data any, ttl time.Duration) error {
-	var bytesData []byte
-	if data == nil || ttl == 0 {
-		l.cache.Delete(key)
-	} else {
-		var err error
-		bytesData, err = json.Marshal(data)
-		if err != nil {
-			return err
-		}
-		l.cache.Set(key, bytesData, ttl)
+	bytesData, err := l.set(key, data, ttl)
+	if err != nil {
+		return err
 	}
 	if l.disableMQ {
 		return nil
 	}
-	err := l.broker.Publish(ctx, l.topic, &broker.Message{
+	err = l.broker.Publish(ctx, l.topic, &broker.Message{
 		Header: map[string]string{
 			"id":       key,
 			"instance": l.instanceID,
@@ -159,6 +152,25 @@ func (l *Lru) Set(ctx context.Context, key string, data any, ttl time.Duration)
 	return err
 }
 
+// SetLocal set the data of this instance only, the data is not sent to the other instances.
+func (l *Lru) SetLocal(_ context.Context, key string, data any, ttl time.Duration) error {
+	_, err := l.set(key, data, ttl)
+	return err
+}
+
+func (l *Lru) set(key string, data any, ttl time.Duration) ([]byte, error) {
+	if data == nil || ttl == 0 {
+		l.cache.Delete(key)
+		return nil, nil
+	}
+	bytesData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	l.cache.Set(key, bytesData, ttl)
+	return bytesData, nil
+}
+
 // Get the data
 func (l *Lru) Get(_ context.Context, key string, data any) error {
 	bytesData := l.cache.Get(key)
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"encoding/json/v2"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cache the json cache of redis, it implements database.Cache, for exp:
+//
+//	db = database.NewCached(db, r.Cache(), database.WithCacheTTL(time.Minute))
+type Cache struct {
+	r *Redis
+}
+
+// Cache returns the json cache of the redis
+func (r *Redis) Cache() *Cache {
+	return &Cache{r: r}
+}
+
+// Get the data, it returns a NotFound error if the key is missing
+func (c *Cache) Get(ctx context.Context, key string, data any) error {
+	value, err := c.r.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal([]byte(value), data); err != nil {
+		return status.Errorf(codes.Internal, "cache unmarshal error %v ", err)
+	}
+	return nil
+}
+
+// Set the data with ttl, the key is deleted if the data is nil or the ttl is zero
+func (c *Cache) Set(ctx context.Context, key string, data any, ttl time.Duration) error {
+	if data == nil || ttl == 0 {
+		return c.r.Delete(ctx, key)
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.r.Set(ctx, key, string(value), ttl)
+}
@@ -81,6 +81,9 @@ func (r *Redis) Init(ctx context.Context, u *url.URL) error {
 	if err != nil {
 		return errors.New("redis can not dial " + u.String() + " - " + err.Error())
 	}
+	if query.Get("tracing") == valueTrue {
+		client = newTracedClient(client, u.Host)
+	}
 	r.client = client
 	var lockerOpts rueidislock.LockerOption
 	err = uri.Unmarshal(u, &lockerOpts)
@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/rueidis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the attributes of the spans of the redis commands
+const (
+	attrSystem     = attribute.Key("db.system")
+	attrOperation  = attribute.Key("db.operation")
+	attrStatusCode = attribute.Key("rpc.grpc.status_code")
+)
+
+// tracedClient the client of tracing=true, it starts a span for every command or pipeline,
+// so the commands of Cache, RateLimitN and Cmdable are traced too.
+type tracedClient struct {
+	rueidis.Client
+	tracer trace.Tracer
+}
+
+func newTracedClient(client rueidis.Client, host string) rueidis.Client {
+	return &tracedClient{
+		Client: client,
+		tracer: otel.Tracer("redis/" + host),
+	}
+}
+
+// Do implements rueidis.Client
+func (c *tracedClient) Do(ctx context.Context, cmd rueidis.Completed) rueidis.RedisResult {
+	ctx, span := c.start(ctx, commandName(cmd.Commands()))
+	resp := c.Client.Do(ctx, cmd)
+	endSpan(span, resp.Error())
+	return resp
+}
+
+// DoMulti implements rueidis.Client
+func (c *tracedClient) DoMulti(ctx context.Context, multi ...rueidis.Completed) []rueidis.RedisResult {
+	ctx, span := c.start(ctx, "PIPELINE")
+	resp := c.Client.DoMulti(ctx, multi...)
+	endSpan(span, multiError(resp))
+	return resp
+}
+
+// DoCache implements rueidis.Client
+func (c *tracedClient) DoCache(ctx context.Context, cmd rueidis.Cacheable, ttl time.Duration) rueidis.RedisResult {
+	ctx, span := c.start(ctx, commandName(cmd.Commands()))
+	resp := c.Client.DoCache(ctx, cmd, ttl)
+	endSpan(span, resp.Error())
+	return resp
+}
+
+// DoMultiCache implements rueidis.Client
+func (c *tracedClient) DoMultiCache(ctx context.Context, multi ...rueidis.CacheableTTL) []rueidis.RedisResult {
+	ctx, span := c.start(ctx, "PIPELINE")
+	resp := c.Client.DoMultiCache(ctx, multi...)
+	endSpan(span, multiError(resp))
+	return resp
+}
+
+func (c *tracedClient) start(ctx context.Context, op string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrSystem.String("redis"), attrOperation.String(op)))
+}
+
+// commandName the name of the command, the arguments are not traced as they may be sensitive.
+func commandName(commands []string) string {
+	if len(commands) == 0 {
+		return "UNKNOWN"
+	}
+	return commands[0]
+}
+
+func multiError(resp []rueidis.RedisResult) error {
+	for _, v := range resp {
+		if err := v.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSpan ends the span and records the error with its grpc status code, the redis nil is a NotFound error
+// as returned by Get.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		code := status.Code(err)
+		if rueidis.IsRedisNil(err) {
+			code = codes.NotFound
+		}
+		span.SetAttributes(attrStatusCode.Int(int(code)))
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
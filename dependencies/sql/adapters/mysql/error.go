@@ -22,6 +22,9 @@ func ConvertError(err error) error {
 			code = codes.AlreadyExists
 		case 1690:
 			code = codes.OutOfRange
+		// the deadlock and lock wait timeout, the transaction can be retried
+		case 1205, 1213:
+			code = codes.Aborted
 		case 2000:
 			code = codes.NotFound
 		default:
@@ -24,6 +24,9 @@ func ConvertError(err error) error {
 		// undefined_table
 		case "42P01":
 			code = codes.NotFound
+		// serialization_failure and deadlock_detected, the transaction can be retried
+		case "40001", "40P01":
+			code = codes.Aborted
 		default:
 			code = codes.Unknown
 		}
@@ -40,3 +40,63 @@ func replaceQuestionMarks(sql string) string {
 	})
 	return sql
 }
+
+// JSONPath convert the path of the jsonb field to the jsonb operators, the path can be nested, for exp:
+//
+//	data, a.b -> `data`->'a'->'b'
+//	data, a.b (text) -> `data`->'a'->>'b'
+func JSONPath(jsonKey, path string, text bool) string {
+	keys := strings.Split(path, ".")
+	expr := "`" + jsonKey + "`"
+	for i, v := range keys {
+		op := "->"
+		if text && i == len(keys)-1 {
+			op = "->>"
+		}
+		expr += op + "'" + v + "'"
+	}
+	return expr
+}
+
+// JSONDocument the json document of the value at the path, it is matched by the @> containment of the jsonb
+// field which can be served by the GIN index, for exp: the path a.b with the value 1 is {"a":{"b":1}}.
+func JSONDocument(path string, value any) (string, error) {
+	keys := strings.Split(path, ".")
+	doc := value
+	for i := len(keys) - 1; i >= 0; i-- {
+		doc = map[string]any{keys[i]: doc}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// JSONArrayDocument the json array which contains the document of the value at the path, it is matched by
+// the @> containment of the jsonb array, for exp: the path id with the value 1 is [{"id":1}], and the empty
+// path with the value 1 is [1].
+func JSONArrayDocument(path string, value any) (string, error) {
+	if path == "" {
+		data, err := json.Marshal([]any{value})
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	doc, err := JSONDocument(path, value)
+	if err != nil {
+		return "", err
+	}
+	return "[" + doc + "]", nil
+}
+
+// JSONValue the json text of the value, it is compared with the jsonb value of a path, so the numbers are
+// not compared as text.
+func JSONValue(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConvertError converts database errors to gRPC status errors
+func ConvertError(err error) error {
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		var code codes.Code
+		switch sqliteError.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			code = codes.AlreadyExists
+		case sqlite3.ErrConstraintNotNull, sqlite3.ErrConstraintCheck:
+			code = codes.InvalidArgument
+		default:
+			switch sqliteError.Code {
+			case sqlite3.ErrBusy, sqlite3.ErrLocked:
+				code = codes.Aborted
+			case sqlite3.ErrCantOpen:
+				code = codes.NotFound
+			case sqlite3.ErrError:
+				// undefined table
+				if strings.HasPrefix(sqliteError.Error(), "no such table") {
+					code = codes.NotFound
+				} else {
+					code = codes.Unknown
+				}
+			default:
+				code = codes.Unknown
+			}
+		}
+		err = status.Errorf(code, "error for code %d, message %s",
+			sqliteError.ExtendedCode, sqliteError.Error())
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// Package sqlite the sqlite adapter of the sql dependency, it is used for local development and hermetic tests.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DriverName the name of the registered sqlite driver, it is the sqlite3 driver with the REGEXP function.
+const DriverName = "sqlite3_regexp"
+
+func init() {
+	sql.Register(DriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", regexpMatch, true)
+		},
+	})
+}
+
+// regexpMatch implements `value REGEXP pattern`, null values never match.
+func regexpMatch(pattern string, value any) (bool, error) {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case []byte:
+		if v == nil {
+			return false, nil
+		}
+		text = string(v)
+	case string:
+		text = v
+	default:
+		text = fmt.Sprint(v)
+	}
+	return regexp.MatchString(pattern, text)
+}
+
+// memoryPath the path of in memory database
+const memoryPath = ":memory:"
+
+// memoryID the number of the last in memory database, every in memory client has its own named database.
+var memoryID atomic.Int64
+
+// DSN convert uri to the sqlite dsn, for exp:
+//
+//	sqlite:///:memory: -> file:memory1?cache=shared&mode=memory
+//	sqlite:///tmp/test.db?_busy_timeout=5000 -> file:/tmp/test.db?_busy_timeout=5000
+//	sqlite://./data/test.db -> file:./data/test.db
+//
+// The connections of an in memory database share the cache, so a query out of a transaction never waits for
+// the connection of the transaction.
+func DSN(uri *url.URL) string {
+	dsn := "file:" + uri.Host + uri.Path
+	if !IsMemory(uri) {
+		if uri.RawQuery != "" {
+			dsn += "?" + uri.RawQuery
+		}
+		return dsn
+	}
+	if strings.TrimPrefix(uri.Path, "/") == memoryPath {
+		dsn = fmt.Sprintf("file:memory%d", memoryID.Add(1))
+	}
+	query := uri.Query()
+	query.Set("mode", "memory")
+	query.Set("cache", "shared")
+	return dsn + "?" + query.Encode()
+}
+
+// IsMemory check if the uri is an in memory database, the database is dropped when its last connection
+// is closed, so the pool must keep an idle connection.
+func IsMemory(uri *url.URL) bool {
+	return strings.TrimPrefix(uri.Path, "/") == memoryPath || uri.Query().Get("mode") == "memory"
+}
+
+// JSONValue convert mysql json path query `key`->'$.sub' to sqlite
+func JSONValue(jsonKey, jsonSubKey string) string {
+	return fmt.Sprintf("json_extract(`%s`,'$.%s')", jsonKey, jsonSubKey)
+}
+
+// JSONArrayContains check if the json array at path of the json field contains the value,
+// the path is empty for the field itself.
+func JSONArrayContains(jsonKey, path string) string {
+	if path == "" {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(`%s`) WHERE json_each.value = ?)", jsonKey)
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(`%s`,'$.%s') WHERE json_each.value = ?)", jsonKey, path)
+}
+
+// JSONMemberOf convert mysql `? MEMBER OF(key->'$[*].sub')` to sqlite, the sub key can be empty.
+func JSONMemberOf(jsonKey, jsonSubKey string) string {
+	if jsonSubKey == "" {
+		return JSONArrayContains(jsonKey, "")
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(`%s`) WHERE json_extract(json_each.value,'$.%s') = ?)",
+		jsonKey, jsonSubKey)
+}
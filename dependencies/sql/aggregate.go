@@ -0,0 +1,109 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Aggregate implements database.Aggregator, the groups are computed by GROUP BY in a sub query,
+// so the Having and Sort can use the names of the aggregations on all dialects.
+func (s *SQL) Aggregate(ctx context.Context, table string, in *database.AggregateRequest,
+) (out *database.AggregateResponse, err error) {
+	ctx, done := s.operation(ctx, table, "aggregate", in.Filters)
+	defer func() { done(err) }()
+	if err := in.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	query, args := s.aggregateQuery(table, in)
+	rows, err := s.readQueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, convertSQLError(s.scheme, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	out = &database.AggregateResponse{}
+	columns := len(in.GroupBy) + len(in.Aggregations)
+	for rows.Next() {
+		values := make([]any, columns)
+		dest := make([]any, columns)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, status.Errorf(codes.Internal, "scan %s aggregate error %s", table, err)
+		}
+		row := make(map[string]any, columns)
+		for i, key := range in.GroupBy {
+			row[key] = database.GroupValue(values[i])
+		}
+		for i, a := range in.Aggregations {
+			row[a.Name()] = database.AggregateValue(a.Func, values[len(in.GroupBy)+i])
+		}
+		out.Data = append(out.Data, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, convertSQLError(s.scheme, err)
+	}
+	return out, nil
+}
+
+// aggregateQuery SELECT * FROM (SELECT `key`, SUM(`field`) AS `sum_field` FROM `table` WHERE ... GROUP BY `key`)
+// AS `aggregated` WHERE having ORDER BY ... LIMIT n
+func (s *SQL) aggregateQuery(table string, in *database.AggregateRequest) (string, []any) {
+	fields := make([]string, 0, len(in.GroupBy)+len(in.Aggregations))
+	groupBy := make([]string, len(in.GroupBy))
+	for i, key := range in.GroupBy {
+		groupBy[i] = fmt.Sprintf("`%s`", key)
+		fields = append(fields, groupBy[i])
+	}
+	for _, a := range in.Aggregations {
+		column := "*"
+		if a.Key != "" {
+			column = fmt.Sprintf("`%s`", a.Key)
+		}
+		fields = append(fields, fmt.Sprintf("%s(%s) AS `%s`", strings.ToUpper(string(a.Func)), column, a.Name()))
+	}
+	query := fmt.Sprintf("SELECT %s FROM `%s`", strings.Join(fields, ","), table)
+	var args []any
+	var conds []string
+	if s.project != "" {
+		conds = append(conds, "`project` = ?")
+		args = append(args, s.project)
+	}
+	if condQuery, condArgs := tidySQLConds(s.scheme, in.Filters, s.compactMode); condQuery != "" {
+		conds = append(conds, condQuery)
+		args = append(args, condArgs...)
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	if len(groupBy) > 0 {
+		query += " GROUP BY " + strings.Join(groupBy, ",")
+	}
+	query = fmt.Sprintf("SELECT * FROM (%s) AS `aggregated`", query)
+	if havingQuery, havingArgs := tidySQLConds(s.scheme, in.Having, s.compactMode); havingQuery != "" {
+		query += " WHERE " + havingQuery
+		args = append(args, havingArgs...)
+	}
+	if orderBy := in.OrderBy(); len(orderBy) > 0 {
+		sorts := make([]string, len(orderBy))
+		for i, v := range orderBy {
+			if strings.HasPrefix(v, "-") {
+				sorts[i] = fmt.Sprintf("`%s` DESC", v[1:])
+			} else {
+				sorts[i] = fmt.Sprintf("`%s`", v)
+			}
+		}
+		query += " ORDER BY " + strings.Join(sorts, ",")
+	}
+	if in.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", in.Limit)
+	}
+	return query, args
+}
@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// bulkInsertBatch the max rows of a multi-row insert of BulkWrite
+const bulkInsertBatch = 100
+
+// BulkWrite implements database.BulkWriter, the models are written in a transaction which is committed after
+// all the models, the consecutive inserts of the same type are batched into multi-row inserts. If a batch
+// fails, its rows are inserted one by one to find the failed ones.
+func (s *SQL) BulkWrite(ctx context.Context, table string, models []database.WriteModel,
+	opts ...database.BulkWriteOption,
+) (result *database.BulkWriteResult, err error) {
+	ctx, done := s.operation(ctx, table, "bulkWrite", nil)
+	defer func() { done(err) }()
+	if len(models) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no write models found")
+	}
+	o := database.NewBulkWriteOptions(opts...)
+	txDB := s
+	var tx database.Transaction
+	if s.tx == nil {
+		if tx, err = s.StartTransaction(ctx); err != nil {
+			return nil, convertSQLError(s.scheme, err)
+		}
+		defer func() {
+			// it is a no-op after the commit
+			_ = tx.Rollback()
+		}()
+		txDB = s.WithTransaction(ctx, tx).(*SQL)
+	}
+	result = database.NewBulkWriteResult(len(models))
+	for i := 0; i < len(models); {
+		end := insertBatchEnd(models, i)
+		if end-i > 1 {
+			count, err := txDB.savepoint(ctx, func() (int, error) {
+				return txDB.Insert(ctx, table, insertDocs(models[i:end]))
+			})
+			if err == nil {
+				result.Add(database.WriteInsert, count)
+				i = end
+				continue
+			}
+		}
+		if !txDB.writeEach(ctx, table, models, i, end, o.Ordered, result) {
+			break
+		}
+		i = end
+	}
+	if tx != nil {
+		if err = tx.Commit(); err != nil {
+			return nil, convertSQLError(s.scheme, err)
+		}
+	}
+	return result, result.Err()
+}
+
+// writeEach write the models[from:to] one by one, it returns false if a model failed in the ordered mode.
+func (s *SQL) writeEach(ctx context.Context, table string, models []database.WriteModel, from, to int,
+	ordered bool, result *database.BulkWriteResult,
+) bool {
+	for i := from; i < to; i++ {
+		count, err := s.savepoint(ctx, func() (int, error) {
+			return models[i].Write(ctx, s, table)
+		})
+		if err != nil {
+			result.Fail(i, err)
+			if ordered {
+				result.Skip(i + 1)
+				return false
+			}
+			continue
+		}
+		result.Add(models[i].Op, count)
+	}
+	return true
+}
+
+// savepoint run the write in a savepoint on postgres, so a failed write does not abort the transaction.
+// A failed statement of mysql and sqlite is rolled back alone.
+func (s *SQL) savepoint(ctx context.Context, write func() (int, error)) (int, error) {
+	if s.scheme != schemePostgres {
+		return write()
+	}
+	if _, err := s.ExecQuery(ctx, "SAVEPOINT bulk_write"); err != nil {
+		return 0, err
+	}
+	count, err := write()
+	if err != nil {
+		_, _ = s.ExecQuery(ctx, "ROLLBACK TO SAVEPOINT bulk_write")
+		return 0, err
+	}
+	_, err = s.ExecQuery(ctx, "RELEASE SAVEPOINT bulk_write")
+	return count, err
+}
+
+// insertBatchEnd returns the end of the consecutive inserts of the same type from the start.
+func insertBatchEnd(models []database.WriteModel, start int) int {
+	if models[start].Op != database.WriteInsert || models[start].Doc == nil {
+		return start + 1
+	}
+	docType := reflect.TypeOf(models[start].Doc)
+	end := start + 1
+	for end < len(models) && end-start < bulkInsertBatch && models[end].Op == database.WriteInsert &&
+		models[end].Doc != nil && reflect.TypeOf(models[end].Doc) == docType {
+		end++
+	}
+	return end
+}
+
+// insertDocs the slice of the docs of the inserts
+func insertDocs(models []database.WriteModel) any {
+	docs := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(models[0].Doc)), len(models), len(models))
+	for i, model := range models {
+		docs.Index(i).Set(reflect.ValueOf(model.Doc))
+	}
+	return docs.Interface()
+}
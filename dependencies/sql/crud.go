@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/ti/common-go/dependencies/sql/adapters/mysql"
 	"github.com/ti/common-go/dependencies/sql/adapters/postgres"
+	"github.com/ti/common-go/dependencies/sql/adapters/sqlite"
 
 	"github.com/ti/common-go/dependencies/database"
 	"google.golang.org/grpc/codes"
@@ -30,6 +32,8 @@ const (
 	queryFieldProject = "`project`,"
 	limit1            = " LIMIT 1"
 	lenLayoutDateTime = len(time.DateTime)
+	// layoutDateTimeMicro the DateTime with the microseconds of datetime(6), the zeros are trimmed
+	layoutDateTimeMicro = "2006-01-02 15:04:05.999999"
 )
 
 var (
@@ -40,11 +44,16 @@ var (
 
 // InsertOne insert one data
 func (s *SQL) InsertOne(ctx context.Context, table string, data any) (err error) {
+	ctx, done := s.operation(ctx, table, "insertOne", nil)
+	defer func() { done(err) }()
 	query := fmt.Sprintf("INSERT INTO `%s` (", table)
 	if s.project != "" {
 		query += queryFieldProject
 	}
-	querys, args := TransformSQLArgs(s.scheme, data, false, s.loc)
+	querys, args, err := transformSQLArgs(s.scheme, data, false, s.loc)
+	if err != nil {
+		return err
+	}
 	query += strings.Join(querys, ",")
 	query += queryAppendValues
 	if s.project != "" {
@@ -57,12 +66,22 @@ func (s *SQL) InsertOne(ctx context.Context, table string, data any) (err error)
 		query += "?"
 	}
 	query += `)`
-	_, err = s.ExecQuery(ctx, query, args...)
-	return
+	if s.scheme == schemePostgres {
+		_, err = s.insertReturning(ctx, query, args, data)
+		return
+	}
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	s.fillInsertIDs(ctx, result, data)
+	return nil
 }
 
 // Insert  single data or slice
 func (s *SQL) Insert(ctx context.Context, table string, docs any) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "insert", nil)
+	defer func() { done(err) }()
 	data := reflect.ValueOf(docs)
 	isSlice := data.Kind() == reflect.Slice
 	if !isSlice {
@@ -73,8 +92,11 @@ func (s *SQL) Insert(ctx context.Context, table string, docs any) (count int, er
 	if data.Len() == 0 {
 		return 0, errors.New("no insert data found")
 	}
-	querys, args := TransformSQLArgs(s.scheme, data.Index(0).Interface(),
+	querys, args, err := transformSQLArgs(s.scheme, data.Index(0).Interface(),
 		false, s.loc)
+	if err != nil {
+		return 0, err
+	}
 	query := fmt.Sprintf("INSERT INTO `%s` (", table)
 	if s.project != "" {
 		query += queryFieldProject
@@ -94,12 +116,97 @@ func (s *SQL) Insert(ctx context.Context, table string, docs any) (count int, er
 	queryValues += `)`
 	query += queryValues
 	for i := 1; i < data.Len(); i++ {
-		_, dataArgs := TransformSQLArgs(s.scheme, data.Index(i).Interface(),
+		_, dataArgs, transformErr := transformSQLArgs(s.scheme, data.Index(i).Interface(),
 			false, s.loc)
+		if transformErr != nil {
+			return 0, transformErr
+		}
 		args = append(args, dataArgs...)
 		query += "," + queryValues
 	}
-	return s.ExecQuery(ctx, query, args...)
+	elems := make([]any, data.Len())
+	for i := range elems {
+		elems[i] = data.Index(i).Interface()
+	}
+	if s.scheme == schemePostgres {
+		return s.insertReturning(ctx, query, args, elems...)
+	}
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	s.fillInsertIDs(ctx, result, elems...)
+	count64, _ := result.RowsAffected()
+	return int(count64), nil
+}
+
+// insertReturning run the insert of postgres with RETURNING, the generated ids are filled back into the zero
+// id fields of the docs in the order of the values, see [database.IDOf].
+func (s *SQL) insertReturning(ctx context.Context, query string, args []any, docs ...any) (int, error) {
+	rows, err := s.QueryContext(ctx, query+" RETURNING `"+database.IDKey+"`", args...)
+	if err != nil {
+		return 0, convertSQLError(s.scheme, err)
+	}
+	defer rows.Close()
+	var count int
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return count, convertSQLError(s.scheme, err)
+		}
+		if count < len(docs) {
+			if field, ok := database.IDOf(docs[count]); ok && field.IsGenerated() && field.IsZero() {
+				field.Set(id)
+			}
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return count, convertSQLError(s.scheme, err)
+	}
+	return count, nil
+}
+
+// fillInsertIDs fill the auto increment ids of the inserted docs by the LastInsertId, which is the first id of
+// the rows on mysql and the last id on sqlite. The ids of the rows of an insert are consecutive on sqlite, and
+// separated by the @@auto_increment_increment on mysql. The ids are not filled if any doc has set its id, as the
+// ids of the other rows are unknown.
+func (s *SQL) fillInsertIDs(ctx context.Context, result sql.Result, docs ...any) {
+	ids := make([]*database.ID, len(docs))
+	for i, v := range docs {
+		id, ok := database.IDOf(v)
+		if !ok || !id.IsGenerated() {
+			continue
+		}
+		if !id.IsZero() {
+			return
+		}
+		ids[i] = id
+	}
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	step := int64(1)
+	if s.scheme == schemeMysql && len(docs) > 1 {
+		if err = s.QueryRowContext(ctx, "SELECT @@auto_increment_increment").Scan(&step); err != nil {
+			return
+		}
+	}
+	setInsertIDs(s.scheme, ids, lastID, step)
+}
+
+// setInsertIDs set the ids of the rows of an insert, the ids are separated by the step.
+func setInsertIDs(scheme string, ids []*database.ID, lastID, step int64) {
+	firstID := lastID
+	if scheme == schemeSQLite {
+		firstID = lastID - int64(len(ids)-1)*step
+	}
+	for i, id := range ids {
+		if id != nil {
+			id.Set(firstID + int64(i)*step)
+		}
+	}
 }
 
 var (
@@ -108,7 +215,9 @@ var (
 )
 
 // Update update all matched data
-func (s *SQL) Update(ctx context.Context, table string, conds database.C, d any) (int, error) {
+func (s *SQL) Update(ctx context.Context, table string, conds database.C, d any) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "update", conds)
+	defer func() { done(err) }()
 	return s.update(ctx, table, conds, d, false)
 }
 
@@ -128,7 +237,10 @@ func (s *SQL) update(ctx context.Context, table string, conds database.C, d any,
 			})
 		}
 	} else {
-		doc = TransformDocument(s.scheme, d, keepEmpty)
+		var err error
+		if doc, err = transformDocument(s.scheme, d, keepEmpty); err != nil {
+			return 0, err
+		}
 	}
 	querySet, args := convertDocsToSet(s.scheme, doc)
 	query += querySet
@@ -136,7 +248,7 @@ func (s *SQL) update(ctx context.Context, table string, conds database.C, d any,
 	if s.project != "" {
 		query += fmt.Sprintf("`project` = '%s' ", s.project)
 	}
-	conQuery, conArgs := tidySQLConds(conds, s.compactMode)
+	conQuery, conArgs := tidySQLConds(s.scheme, conds, s.compactMode)
 	if conQuery != "" {
 		if s.project != "" {
 			query += queryAnd
@@ -147,8 +259,13 @@ func (s *SQL) update(ctx context.Context, table string, conds database.C, d any,
 	return s.ExecQuery(ctx, query, args...)
 }
 
-// UpdateOne update one data
+// UpdateOne update one data, the version of a versioned model is checked and incremented.
 func (s *SQL) UpdateOne(ctx context.Context, table string, conds database.C, data any) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "updateOne", conds)
+	defer func() { done(err) }()
+	if version, ok := database.VersionOf(data); ok {
+		return s.updateVersion(ctx, table, conds, data, version, false)
+	}
 	count, err = s.update(ctx, table, conds, data, false)
 	if err == nil && s.updateDifferent && count == 0 {
 		err = status.Errorf(codes.NotFound, "condition %s not found", conds)
@@ -156,8 +273,13 @@ func (s *SQL) UpdateOne(ctx context.Context, table string, conds database.C, dat
 	return
 }
 
-// ReplaceOne replace one data
+// ReplaceOne replace one data, a versioned model is never inserted, and its version is checked and incremented.
 func (s *SQL) ReplaceOne(ctx context.Context, table string, conds database.C, data any) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "replaceOne", conds)
+	defer func() { done(err) }()
+	if version, ok := database.VersionOf(data); ok {
+		return s.updateVersion(ctx, table, conds, data, version, true)
+	}
 	count, err = s.update(ctx, table, conds, data, true)
 	if err == nil && s.updateDifferent && count == 0 {
 		err = s.InsertOne(ctx, table, data)
@@ -172,8 +294,40 @@ func (s *SQL) ReplaceOne(ctx context.Context, table string, conds database.C, da
 	return
 }
 
-// Replace update data in bulk
+// updateVersion update the doc of the same version and increment the version
+func (s *SQL) updateVersion(ctx context.Context, table string, conds database.C, data any,
+	version *database.Version, keepEmpty bool,
+) (int, error) {
+	version.Increment()
+	count, err := s.update(ctx, table, version.Scope(conds), data, keepEmpty)
+	if err == nil && count == 0 {
+		err = s.versionNotMatched(ctx, table, conds)
+	}
+	if err != nil {
+		version.Reset()
+		return 0, err
+	}
+	return count, nil
+}
+
+// versionNotMatched returns ErrVersionConflict if the doc exists with another version, or NotFound.
+func (s *SQL) versionNotMatched(ctx context.Context, table string, conds database.C) error {
+	// the replicas may not have the doc which is just written
+	exist, err := s.Exist(WithPrimary(ctx), table, conds)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return database.ErrVersionConflict
+	}
+	return status.Errorf(codes.NotFound, "condition %s not found", conds)
+}
+
+// Replace update data in bulk by the index keys, the missing rows are inserted by postgres only, mysql and
+// sqlite update the existing rows and skip the missing ones.
 func (s *SQL) Replace(ctx context.Context, table string, indexKeys []string, docs any) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "replace", nil)
+	defer func() { done(err) }()
 	data := reflect.ValueOf(docs)
 	indexKeysMap := make(map[string]int)
 	for i, v := range indexKeys {
@@ -181,9 +335,11 @@ func (s *SQL) Replace(ctx context.Context, table string, indexKeys []string, doc
 	}
 	isSlice := data.Kind() == reflect.Slice
 	if !isSlice {
-		conds, doc := s.getFilterByIndexKeys(indexKeysMap, docs)
-		_, err = s.update(ctx, table, conds, doc, true)
-		if err != nil {
+		conds, doc, transformErr := s.getFilterByIndexKeys(indexKeysMap, docs)
+		if transformErr != nil {
+			return 0, transformErr
+		}
+		if err = s.replace(ctx, table, indexKeys, conds, doc); err != nil {
 			return 0, err
 		}
 		return 1, nil
@@ -194,9 +350,12 @@ func (s *SQL) Replace(ctx context.Context, table string, indexKeys []string, doc
 		return 0, errors.New("no insert data found")
 	}
 	for i := range dataLen {
-		conds, doc := s.getFilterByIndexKeys(indexKeysMap, data.Index(i).Interface())
+		conds, doc, transformErr := s.getFilterByIndexKeys(indexKeysMap, data.Index(i).Interface())
 		// TODO: performance optimization
-		_, err = s.update(ctx, table, conds, doc, true)
+		err = transformErr
+		if err == nil {
+			err = s.replace(ctx, table, indexKeys, conds, doc)
+		}
 		if err != nil {
 			errResp.Elements = append(errResp.Elements, &database.BulkElement{
 				Index:   i,
@@ -212,27 +371,88 @@ func (s *SQL) Replace(ctx context.Context, table string, indexKeys []string, doc
 	return
 }
 
-func (s *SQL) getFilterByIndexKeys(indexKeysMap map[string]int, data any) (database.C, database.D) {
-	doc := TransformDocument(s.scheme, data, true)
-	indexValues := make([]any, len(indexKeysMap))
+func (s *SQL) getFilterByIndexKeys(indexKeysMap map[string]int, data any) (database.C, database.D, error) {
+	doc, err := transformDocument(s.scheme, data, true)
+	if err != nil {
+		return nil, nil, err
+	}
 	conds := make(database.C, len(indexKeysMap))
 	for _, field := range doc {
 		if iDoc, ok := indexKeysMap[field.Key]; ok {
-			indexValues[iDoc] = field.Value
+			conds[iDoc] = database.CE{Key: field.Key, Value: field.Value}
+		}
+	}
+	return conds, doc, nil
+}
+
+// replace the doc of the conditions of the index keys, postgres inserts the doc if it is not found.
+func (s *SQL) replace(ctx context.Context, table string, indexKeys []string, conds database.C,
+	doc database.D,
+) error {
+	if s.scheme == schemePostgres {
+		_, err := s.upsert(ctx, table, indexKeys, doc)
+		return err
+	}
+	_, err := s.update(ctx, table, conds, doc, true)
+	return err
+}
+
+// upsert insert the doc or update the row of the same index keys by INSERT ... ON CONFLICT of postgres,
+// the index keys must be the fields of a unique index, exp: EnsureIndex(ctx, table, indexKeys, true, false).
+// The project is the first key of the conflict if it is set, so the unique index is (`project`, index keys),
+// and the rows of the other projects are never updated.
+func (s *SQL) upsert(ctx context.Context, table string, indexKeys []string, doc database.D) (int, error) {
+	if len(indexKeys) == 0 {
+		indexKeys = []string{database.IDKey}
+	}
+	conflictKeys := indexKeys
+	if s.project != "" {
+		conflictKeys = append([]string{"project"}, indexKeys...)
+	}
+	var columns, values, sets []string
+	var args []any
+	if s.project != "" {
+		columns = append(columns, "`project`")
+		values = append(values, fmt.Sprintf("'%s'", s.project))
+	}
+	for _, v := range doc {
+		isIndexKey := slices.Contains(indexKeys, v.Key)
+		// the _id is generated unless the rows are replaced by the _id
+		if v.Key == database.IDKey && !isIndexKey {
+			continue
+		}
+		columns = append(columns, "`"+v.Key+"`")
+		values = append(values, "?")
+		args = append(args, v.Value)
+		if !isIndexKey {
+			sets = append(sets, fmt.Sprintf("`%s` = EXCLUDED.`%s`", v.Key, v.Key))
 		}
 	}
-	return conds, doc
+	query := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s) ON CONFLICT (%s) DO ", table,
+		strings.Join(columns, ","), strings.Join(values, ","), quoteFields(conflictKeys))
+	if len(sets) == 0 {
+		query += "NOTHING"
+	} else {
+		query += "UPDATE SET " + strings.Join(sets, ", ")
+	}
+	return s.ExecQuery(ctx, query, args...)
 }
 
 // FindOne find one data
-func (s *SQL) FindOne(ctx context.Context, table string, conds database.C, data any) error {
+func (s *SQL) FindOne(ctx context.Context, table string, conds database.C, data any) (err error) {
+	ctx, done := s.operation(ctx, table, "findOne", conds)
+	defer func() { done(err) }()
+	// the conditions of the deterministic encrypted fields match the encrypted values
+	if conds, err = database.EncryptConditions(conds, data); err != nil {
+		return err
+	}
 	exs, querys, args := TransformSQLDocument(data, true, map[string]bool{})
 	query := "SELECT " + strings.Join(querys, ",")
 	query += fmt.Sprintf(" FROM `%s` WHERE ", table)
 	if s.project != "" {
 		query += fmt.Sprintf("`project` = '%s' ", s.project)
 	}
-	conQuery, conArgs := tidySQLConds(conds, s.compactMode)
+	conQuery, conArgs := tidySQLConds(s.scheme, conds, s.compactMode)
 	if conQuery != "" {
 		if s.project != "" {
 			query += queryAnd
@@ -240,7 +460,7 @@ func (s *SQL) FindOne(ctx context.Context, table string, conds database.C, data
 		query += conQuery
 	}
 	query += limit1
-	row := s.QueryRowContext(ctx, query, conArgs...)
+	row := s.readQueryRowContext(ctx, query, conArgs...)
 	if err := row.Err(); err != nil {
 		return convertSQLError(s.scheme, err)
 	}
@@ -253,7 +473,9 @@ func (s *SQL) FindOne(ctx context.Context, table string, conds database.C, data
 }
 
 // Find all data
-func (s *SQL) Find(ctx context.Context, table string, conds database.C, order []string, limit int, arryPtr any) error {
+func (s *SQL) Find(ctx context.Context, table string, conds database.C, order []string, limit int, arryPtr any) (err error) {
+	ctx, done := s.operation(ctx, table, "find", conds)
+	defer func() { done(err) }()
 	valuePtr := reflect.ValueOf(arryPtr)
 	value := valuePtr.Elem()
 	eleType := reflect.TypeOf(arryPtr).Elem().Elem().Elem()
@@ -276,14 +498,16 @@ func (s *SQL) Find(ctx context.Context, table string, conds database.C, order []
 }
 
 // Count the data
-func (s *SQL) Count(ctx context.Context, table string, conds database.C) (int64, error) {
+func (s *SQL) Count(ctx context.Context, table string, conds database.C) (count int64, err error) {
+	ctx, done := s.operation(ctx, table, "count", conds)
+	defer func() { done(err) }()
 	query := "SELECT count(*)"
 	query += fmt.Sprintf(" FROM `%s` ", table)
 	var whereQuery string
 	if s.project != "" {
 		whereQuery += fmt.Sprintf("`project` = '%s' ", s.project)
 	}
-	conQuery, conArgs := tidySQLConds(conds, s.compactMode)
+	conQuery, conArgs := tidySQLConds(s.scheme, conds, s.compactMode)
 	if conQuery != "" {
 		if s.project != "" {
 			whereQuery += queryAnd
@@ -293,8 +517,7 @@ func (s *SQL) Count(ctx context.Context, table string, conds database.C) (int64,
 	if whereQuery != "" {
 		query += "WHERE " + whereQuery
 	}
-	var count int64
-	err := s.QueryRowContext(ctx, query, conArgs...).Scan(&count)
+	err = s.readQueryRowContext(ctx, query, conArgs...).Scan(&count)
 	if err != nil {
 		return 0, convertSQLError(s.scheme, err)
 	}
@@ -304,7 +527,9 @@ func (s *SQL) Count(ctx context.Context, table string, conds database.C) (int64,
 // FindRows find rows data
 func (s *SQL) FindRows(ctx context.Context, table string, conds database.C, sortBy []string,
 	limit int, data any,
-) (database.Row, error) {
+) (row database.Row, err error) {
+	ctx, done := s.operation(ctx, table, "findRows", conds)
+	defer func() { done(err) }()
 	eleType := reflect.TypeOf(data).Elem()
 	return s.findRows(ctx, table, conds, sortBy, limit, eleType)
 }
@@ -313,30 +538,40 @@ func (s *SQL) FindRows(ctx context.Context, table string, conds database.C, sort
 func (s *SQL) findRows(ctx context.Context, table string, conds database.C,
 	sortBy []string, limit int, eleType reflect.Type,
 ) (database.Row, error) {
-	querys := TransformSQLQuery(reflect.New(eleType).Interface())
+	model := reflect.New(eleType).Interface()
+	conds, err := database.EncryptConditions(conds, model)
+	if err != nil {
+		return nil, err
+	}
+	querys := TransformSQLQuery(model)
 	query := "SELECT " + strings.Join(querys, ",")
-	query += fmt.Sprintf(" FROM `%s` WHERE ", table)
+	query += fmt.Sprintf(" FROM `%s` ", table)
+	var whereQuery string
 	if s.project != "" {
-		query += fmt.Sprintf("`project` = '%s' ", s.project)
+		whereQuery += fmt.Sprintf("`project` = '%s' ", s.project)
 	}
-	conQuery, conArgs := tidySQLConds(conds, s.compactMode)
+	conQuery, conArgs := tidySQLConds(s.scheme, conds, s.compactMode)
 	if conQuery != "" {
 		if s.project != "" {
-			query += queryAnd
+			whereQuery += queryAnd
 		}
-		query += conQuery
+		whereQuery += conQuery
+	}
+	if whereQuery != "" {
+		query += "WHERE " + whereQuery
 	}
 	if len(sortBy) > 0 {
-		query += " ORDER BY " + getSortValue(sortBy[0])
-		sortBy = sortBy[1:]
-		for _, v := range sortBy {
-			query += ", " + getSortValue(v)
+		order, orderArgs, err := parseOrder(s.scheme, conds, sortBy, getSortValue)
+		if err != nil {
+			return nil, err
 		}
+		query += " ORDER BY " + order
+		conArgs = append(conArgs, orderArgs...)
 	}
 	if limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", limit)
 	}
-	rows, err := s.QueryContext(ctx, query, conArgs...)
+	rows, err := s.readQueryContext(ctx, query, conArgs...)
 	if err != nil {
 		return nil, convertSQLError(s.scheme, err)
 	}
@@ -355,17 +590,25 @@ func (s *SQL) findRows(ctx context.Context, table string, conds database.C,
 // QueryContext executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (s *SQL) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	defer s.observe(ctx, time.Now(), query, args)
 	if s.scheme == schemePostgres {
 		query = postgres.ConvertSQL(query)
 	}
+	if s.tx != nil {
+		return s.tx.QueryContext(ctx, query, args...)
+	}
 	return s.DB.QueryContext(ctx, query, args...)
 }
 
 // QueryRowContext executes a query that is expected to return at most one row.
 func (s *SQL) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer s.observe(ctx, time.Now(), query, args)
 	if s.scheme == schemePostgres {
 		query = postgres.ConvertSQL(query)
 	}
+	if s.tx != nil {
+		return s.tx.QueryRowContext(ctx, query, args...)
+	}
 	return s.DB.QueryRowContext(ctx, query, args...)
 }
 
@@ -379,19 +622,17 @@ func getSortValue(src string) string {
 }
 
 // Exist check if cond exist
-func (s *SQL) Exist(ctx context.Context, table string, conds database.C) (bool, error) {
+func (s *SQL) Exist(ctx context.Context, table string, conds database.C) (exist bool, err error) {
+	ctx, done := s.operation(ctx, table, "exist", conds)
+	defer func() { done(err) }()
 	query := fmt.Sprintf("SELECT `_id` FROM `%s` WHERE ", table)
 	if s.project != "" {
 		query += fmt.Sprintf("`project` = '%s' AND ", s.project)
 	}
-	conQuery, conArgs := tidySQLConds(conds, s.compactMode)
+	conQuery, conArgs := tidySQLConds(s.scheme, conds, s.compactMode)
 	query += conQuery
 	query = fmt.Sprintf("SELECT EXISTS(%s);", query)
-	var (
-		row   = s.QueryRowContext(ctx, query, conArgs...)
-		exist bool
-		err   error
-	)
+	row := s.readQueryRowContext(ctx, query, conArgs...)
 	if s.scheme == schemePostgres {
 		err = row.Scan(&exist)
 	} else {
@@ -479,7 +720,11 @@ func decodeExs(scheme string, exs []*EX, timeLoc *time.Location) error {
 		if vs == "" {
 			continue
 		}
-		if v.IsJSON {
+		if v.IsEncrypted {
+			if err := database.DecryptValue(v.Field, vs); err != nil {
+				return err
+			}
+		} else if v.IsJSON {
 			sv := v.Field.Interface()
 			st := reflect.TypeOf(sv)
 			var newPtr reflect.Value
@@ -559,12 +804,14 @@ func DecodeRow(scheme string, row *sql.Rows, selectFields map[string]bool, id *i
 }
 
 // Delete delete data
-func (s *SQL) Delete(ctx context.Context, table string, conds database.C) (int, error) {
+func (s *SQL) Delete(ctx context.Context, table string, conds database.C) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "delete", conds)
+	defer func() { done(err) }()
 	query := fmt.Sprintf("DELETE FROM `%s` WHERE ", table)
 	if s.project != "" {
 		query += fmt.Sprintf("`project` = '%s' ", s.project)
 	}
-	conQuery, args := tidySQLConds(conds, s.compactMode)
+	conQuery, args := tidySQLConds(s.scheme, conds, s.compactMode)
 	if conQuery != "" {
 		if s.project != "" {
 			query += queryAnd
@@ -576,6 +823,17 @@ func (s *SQL) Delete(ctx context.Context, table string, conds database.C) (int,
 
 // ExecQuery query data
 func (s *SQL) ExecQuery(ctx context.Context, query string, args ...any) (int, error) {
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// exec execute the query in the transaction of s if any
+func (s *SQL) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	defer s.observe(ctx, time.Now(), query, args)
 	var result sql.Result
 	var err error
 	// Compatible with non-MySQL databases
@@ -588,10 +846,9 @@ func (s *SQL) ExecQuery(ctx context.Context, query string, args ...any) (int, er
 		result, err = s.ExecContext(ctx, query, args...)
 	}
 	if err != nil {
-		return 0, convertSQLError(s.scheme, err)
+		return nil, convertSQLError(s.scheme, err)
 	}
-	count, _ := result.RowsAffected()
-	return int(count), nil
+	return result, nil
 }
 
 func convertSQLError(scheme string, err error) error {
@@ -608,6 +865,8 @@ func convertSQLError(scheme string, err error) error {
 			return mysql.ConvertError(err)
 		case schemePostgres:
 			return postgres.ConvertError(err)
+		case schemeSQLite:
+			return sqlite.ConvertError(err)
 		}
 	}
 	return status.Error(code, msg)
@@ -615,11 +874,15 @@ func convertSQLError(scheme string, err error) error {
 
 // DeleteOne delete one data
 func (s *SQL) DeleteOne(ctx context.Context, table string, conds database.C) (count int, err error) {
+	ctx, done := s.operation(ctx, table, "deleteOne", conds)
+	defer func() { done(err) }()
 	return s.Delete(ctx, table, conds)
 }
 
 // IncrCounter +1 a value
-func (s *SQL) IncrCounter(ctx context.Context, counterTable, key string, start int64, count int64) error {
+func (s *SQL) IncrCounter(ctx context.Context, counterTable, key string, start int64, count int64) (err error) {
+	ctx, done := s.operation(ctx, counterTable, "incrCounter", nil)
+	defer func() { done(err) }()
 	query := "UPDATE " + counterTable + fmt.Sprintf(" SET count = count + %d WHERE ", count)
 	var args []any
 	if s.project != "" {
@@ -628,31 +891,35 @@ func (s *SQL) IncrCounter(ctx context.Context, counterTable, key string, start i
 	}
 	query += queryKey
 	args = append(args, key)
-	ret, err := s.ExecContext(ctx, query, args...)
+	rowsAffected, err := s.ExecQuery(ctx, query, args...)
 	if err != nil {
 		return err
 	}
-	rowsAffected, _ := ret.RowsAffected()
 	if rowsAffected == 0 {
 		query = "INSERT INTO " + counterTable + " ("
 		args = []any{}
+		holders := "?,?"
 		if s.project != "" {
 			query += queryFieldProject
 			args = append(args, s.project)
+			holders += ",?"
 		}
-		query += fmt.Sprintf("`key`,count) VALUES (?,?,?) ON DUPLICATE KEY UPDATE count = count + %d;", count)
-		args = append(args, key, start+count-1)
-		_, err = s.ExecContext(ctx, query, args...)
-		if err != nil {
-			return convertSQLError(s.scheme, err)
+		onDuplicate := "ON DUPLICATE KEY UPDATE"
+		if s.scheme == schemeSQLite {
+			onDuplicate = "ON CONFLICT DO UPDATE SET"
 		}
-		return nil
+		query += fmt.Sprintf("`key`,count) VALUES (%s) %s count = count + %d;", holders, onDuplicate, count)
+		args = append(args, key, start+count-1)
+		_, err = s.ExecQuery(ctx, query, args...)
+		return err
 	}
 	return nil
 }
 
 // DecrCounter decr the counter of a table
-func (s *SQL) DecrCounter(ctx context.Context, counterTable, key string, count int64) error {
+func (s *SQL) DecrCounter(ctx context.Context, counterTable, key string, count int64) (err error) {
+	ctx, done := s.operation(ctx, counterTable, "decrCounter", nil)
+	defer func() { done(err) }()
 	query := "UPDATE " + counterTable + fmt.Sprintf(" SET count = count - %d WHERE ", count)
 	var args []any
 	if s.project != "" {
@@ -661,25 +928,23 @@ func (s *SQL) DecrCounter(ctx context.Context, counterTable, key string, count i
 	}
 	query += queryKey
 	args = append(args, key)
-	_, err := s.ExecContext(ctx, query, args...)
-	if err != nil {
-		return convertSQLError(s.scheme, err)
-	}
-	return nil
+	_, err = s.ExecQuery(ctx, query, args...)
+	return err
 }
 
 // GetCounter Decr Counter
 // the counter table for example:
 // ```create table counter (`_id` bigint unsigned auto_increment, `project` CHAR(64) not null, `key` CHAR(12),
 // `count` int, KEY (`project`), UNIQUE KEY (`project`,`key`), constraint counter_pk primary key (_id));```
-func (s *SQL) GetCounter(ctx context.Context, counterTable, key string) (int64, error) {
+func (s *SQL) GetCounter(ctx context.Context, counterTable, key string) (count int64, err error) {
+	ctx, done := s.operation(ctx, counterTable, "getCounter", nil)
+	defer func() { done(err) }()
 	query := fmt.Sprintf("SELECT count FROM `%s` WHERE ", counterTable)
 	if s.project != "" {
 		query += fmt.Sprintf("`project` = '%s' AND ", s.project)
 	}
 	query += fmt.Sprintf("`key` = '%s'", key)
-	var count int64
-	err := s.QueryRowContext(ctx, query).Scan(&count)
+	err = s.QueryRowContext(ctx, query).Scan(&count)
 	if err != nil {
 		return 0, convertSQLError(s.scheme, err)
 	}
@@ -10,14 +10,24 @@ import (
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// TransformDocument transform any object to bson documents
+// TransformDocument transform any object to bson documents, the encrypted fields which can not be encrypted
+// are skipped, so the plain values are never written.
 func TransformDocument(scheme string, ptrVal any, keepEmpty bool) database.D {
+	docs, err := transformDocument(scheme, ptrVal, keepEmpty)
+	if err != nil {
+		slog.Warn("encrypt error", "error", err)
+	}
+	return docs
+}
+
+// transformDocument transform any object to bson documents, it returns the first error of the encrypted fields.
+func transformDocument(scheme string, ptrVal any, keepEmpty bool) (docs database.D, err error) {
 	v := reflect.ValueOf(ptrVal).Elem()
 	t := v.Type()
-	var docs database.D
 	for i := range t.NumField() {
 		sf := t.Field(i)
 		if !sf.IsExported() {
@@ -29,7 +39,11 @@ func TransformDocument(scheme string, ptrVal any, keepEmpty bool) database.D {
 		}
 		sv := sfv.Interface()
 		if sf.Anonymous {
-			docs = append(docs, TransformDocument(scheme, sv, keepEmpty)...)
+			embedded, embeddedErr := transformDocument(scheme, sv, keepEmpty)
+			docs = append(docs, embedded...)
+			if err == nil {
+				err = embeddedErr
+			}
 			continue
 		}
 		tag := sf.Tag.Get("json")
@@ -41,23 +55,45 @@ func TransformDocument(scheme string, ptrVal any, keepEmpty bool) database.D {
 		} else {
 			tag, _, _ = strings.Cut(tag, ",")
 		}
+		// the zero id is generated by the database, it is never written
+		if tag == database.IDKey && isEmptyValue(sfv) {
+			continue
+		}
 		ft := sf.Type
 		e := database.E{
 			Key: tag,
 		}
-		skip := fillElements(scheme, &e, ft, sv)
+		encrypted, skip, encryptErr := encryptElement(sf, sfv, &e)
+		if !encrypted {
+			skip = fillElements(scheme, &e, ft, sv)
+		}
+		if err == nil {
+			err = encryptErr
+		}
 		if skip {
 			continue
 		}
 		docs = append(docs, e)
 	}
-	return docs
+	return docs, err
 }
 
-// TransformSQLArgs transform any object to sql args
+// TransformSQLArgs transform any object to sql args, the encrypted fields which can not be encrypted are
+// skipped, so the plain values are never written.
 func TransformSQLArgs(scheme string, ptrVal any, keepEmpty bool,
 	loc *time.Location,
 ) (query []string, args []any) {
+	query, args, err := transformSQLArgs(scheme, ptrVal, keepEmpty, loc)
+	if err != nil {
+		slog.Warn("encrypt error", "error", err)
+	}
+	return query, args
+}
+
+// transformSQLArgs transform any object to sql args, it returns the first error of the encrypted fields.
+func transformSQLArgs(scheme string, ptrVal any, keepEmpty bool,
+	loc *time.Location,
+) (query []string, args []any, err error) {
 	v := reflect.ValueOf(ptrVal).Elem()
 	t := v.Type()
 	for i := range t.NumField() {
@@ -71,7 +107,7 @@ func TransformSQLArgs(scheme string, ptrVal any, keepEmpty bool,
 		}
 		sv := sfv.Interface()
 		if sf.Anonymous {
-			query, args = TransformSQLArgs(scheme, sv, keepEmpty, loc)
+			query, args, err = transformSQLArgs(scheme, sv, keepEmpty, loc)
 			continue
 		}
 		tag := sf.Tag.Get("json")
@@ -87,12 +123,21 @@ func TransformSQLArgs(scheme string, ptrVal any, keepEmpty bool,
 		e := database.E{
 			Key: tag,
 		}
-		skip := fillElements(scheme, &e, ft, sv)
+		encrypted, skip, encryptErr := encryptElement(sf, sfv, &e)
+		if !encrypted {
+			skip = fillElements(scheme, &e, ft, sv)
+		}
+		if err == nil {
+			err = encryptErr
+		}
 		if skip {
 			continue
 		}
 		query = append(query, "`"+e.Key+"`")
-		if timeValue, ok := e.Value.(time.Time); ok {
+		if timeValue, ok := e.Value.(time.Time); ok && scheme == schemePostgres {
+			// the timestamptz keeps the instant of the time
+			args = append(args, timeValue)
+		} else if ok {
 			timeStr := timeValue.In(loc).Format(time.DateTime)
 			args = append(args, timeStr)
 		} else {
@@ -113,6 +158,8 @@ type EX struct {
 	IsTime  bool
 	IsSlice bool
 	IsMap   bool
+	// IsEncrypted the field is encrypted, see database.EncryptOf
+	IsEncrypted bool
 }
 
 // TransformSQLQuery transform sql querys
@@ -180,6 +227,16 @@ func TransformSQLDocument(ptrVal any, hasQuery bool,
 		}
 		ft := sf.Type
 		ex := fillHolders(ft, sfv)
+		if encrypted, _ := database.EncryptOf(sf); encrypted {
+			ex = &EX{
+				Value:       sfv.Addr().Interface(),
+				Holder:      new(string),
+				Type:        ft,
+				Field:       sfv,
+				Kind:        ft.Kind(),
+				IsEncrypted: true,
+			}
+		}
 		if ex != nil {
 			args = append(args, ex.Holder)
 			exs = append(exs, ex)
@@ -227,6 +284,22 @@ func fillHolders(ft reflect.Type, sfv reflect.Value) *EX {
 	}
 }
 
+// encryptElement set the encrypted value of the encrypted field to the element, the field is skipped with the
+// error if it can not be encrypted, so the plain value is never written.
+func encryptElement(sf reflect.StructField, sfv reflect.Value, e *database.E) (encrypted, skip bool, err error) {
+	encrypted, deterministic := database.EncryptOf(sf)
+	if !encrypted {
+		return false, false, nil
+	}
+	value, err := database.EncryptValue(sfv, deterministic)
+	if err != nil {
+		return true, true, status.Errorf(status.Code(err), "encrypt the field %s error %s",
+			e.Key, status.Convert(err).Message())
+	}
+	e.Value = value
+	return true, false, nil
+}
+
 func fillElements(scheme string, e *database.E, ft reflect.Type, sv any) (skip bool) {
 	switch ft.Kind() {
 	case reflect.Interface, reflect.Pointer:
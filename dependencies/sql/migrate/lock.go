@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// lockName the name of the advisory lock
+const lockName = "common-go:" + TableName
+
+// localLock the lock of sqlite, whose database is owned by one process
+var localLock sync.Mutex
+
+// withLock runs fn with the advisory lock, which is held by one connection of the database.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	switch m.db.Scheme() {
+	case "mysql", "postgres":
+	default:
+		localLock.Lock()
+		defer localLock.Unlock()
+		return fn()
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.LockTimeout)
+	defer cancel()
+	// the advisory locks belong to the session, so lock and unlock with the same connection
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "get connection for migration lock error %s", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	unlock, err := advisoryLock(ctx, conn, m.db.Scheme(), int(m.LockTimeout.Seconds()))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+func advisoryLock(ctx context.Context, conn *sql.Conn, scheme string, timeoutSeconds int) (func(), error) {
+	if scheme == "postgres" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(lockName))
+		key := int64(h.Sum64())
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return nil, status.Errorf(codes.Aborted, "acquire migration lock error %s", err)
+		}
+		return func() {
+			_, _ = conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", key)
+		}, nil
+	}
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, timeoutSeconds).Scan(&acquired)
+	if err != nil {
+		return nil, status.Errorf(codes.Aborted, "acquire migration lock error %s", err)
+	}
+	if acquired.Int64 != 1 {
+		return nil, status.Errorf(codes.Aborted, "acquire migration lock timeout after %d seconds", timeoutSeconds)
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", lockName)
+	}, nil
+}
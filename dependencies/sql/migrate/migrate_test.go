@@ -0,0 +1,89 @@
+package migrate_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	sqldb "github.com/ti/common-go/dependencies/sql"
+	"github.com/ti/common-go/dependencies/sql/migrate"
+)
+
+type userV1 struct {
+	Name string `json:"name"`
+}
+
+type userV2 struct {
+	Name  string `json:"name"`
+	Age   int    `json:"age"`
+	Email string `json:"email"`
+}
+
+func newTestDB(t *testing.T, uri string) *sqldb.SQL {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqldb.New(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close(ctx)
+	})
+	return db.(*sqldb.SQL)
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, "sqlite:///:memory:")
+	fileMigrations, err := migrate.LoadFS(os.DirFS("testdata"), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations := append(fileMigrations,
+		migrate.ModelMigration(2, "users", &userV1{}),
+		migrate.ModelMigration(3, "users", &userV2{}),
+	)
+	m := migrate.New(db, migrations...)
+	for range 2 {
+		if err = m.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	version, err := m.Version(ctx)
+	if err != nil || version != 3 {
+		t.Fatalf("version %d error %v", version, err)
+	}
+	columns, err := migrate.Columns(ctx, db, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !columns["age"] || !columns["email"] {
+		t.Fatalf("the new fields are not added: %v", columns)
+	}
+	if _, err = db.ExecQuery(ctx, "INSERT INTO `items` (`name`) VALUES (?)", "a"); err != nil {
+		t.Fatal(err)
+	}
+	statements, err := migrate.Diff(ctx, db, "users", &userV2{})
+	if err != nil || len(statements) != 0 {
+		t.Fatalf("unexpected diff %v error %v", statements, err)
+	}
+
+	if err = m.Down(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ = m.Version(ctx); version != 2 {
+		t.Fatalf("expect version 2 after down, got %d", version)
+	}
+}
+
+func TestMigrateAtInit(t *testing.T) {
+	migrate.Register(migrate.SQLMigration(1, "create_tags",
+		"CREATE TABLE `tags` (`_id` INTEGER PRIMARY KEY AUTOINCREMENT, `project` TEXT, `name` TEXT);",
+		"DROP TABLE `tags`;"))
+	db := newTestDB(t, "sqlite:///:memory:?migrate=true")
+	exist, err := db.Exist(context.Background(), "tags", database.C{{Key: "name", Value: "a"}})
+	if err != nil || exist {
+		t.Fatalf("exist %v error %v", exist, err)
+	}
+}
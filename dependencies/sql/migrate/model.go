@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	sqldb "github.com/ti/common-go/dependencies/sql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ModelMigration a migration which creates the table of the model, or adds the new fields of the model
+// to the table, the removed or changed fields are kept as they are.
+func ModelMigration(version int64, table string, model any) *Migration {
+	return &Migration{
+		Version: version,
+		Name:    "model_" + table,
+		Up: func(ctx context.Context, db *sqldb.SQL) error {
+			statements, err := Diff(ctx, db, table, model)
+			if err != nil {
+				return err
+			}
+			for _, statement := range statements {
+				if _, err = db.ExecQuery(ctx, statement); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// Diff compares the TransformScheme of the model with the live table, it returns the CREATE statement
+// if the table does not exist, or the ALTER statements of the new fields.
+func Diff(ctx context.Context, db *sqldb.SQL, table string, model any) ([]string, error) {
+	columns, err := Columns(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		switch db.Scheme() {
+		case "sqlite":
+			return []string{sqldb.GenerateSQLiteScheme(table, model)}, nil
+		case "postgres":
+			return []string{sqldb.GeneratePostgresScheme(table, model)}, nil
+		}
+		return []string{sqldb.GenerateScheme(table, model)}, nil
+	}
+	var statements []string
+	for _, v := range sqldb.SchemeColumns(db.Scheme(), model) {
+		if columns[v.Key] {
+			continue
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s",
+			table, v.Key, v.Value))
+	}
+	return statements, nil
+}
+
+// Columns the column names of the live table, it is empty if the table does not exist.
+func Columns(ctx context.Context, db *sqldb.SQL, table string) (map[string]bool, error) {
+	var query string
+	switch db.Scheme() {
+	case "sqlite":
+		query = "SELECT `name` FROM pragma_table_info(?)"
+	case "postgres":
+		query = "SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() " +
+			"AND table_name = ?"
+	default:
+		query = "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() " +
+			"AND TABLE_NAME = ?"
+	}
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "query columns of %s error %s", table, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, status.Errorf(codes.Internal, "scan columns of %s error %s", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
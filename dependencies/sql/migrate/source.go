@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sqldb "github.com/ti/common-go/dependencies/sql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SQLMigration a migration of sql scripts, the statements of a script are separated by a semicolon
+// at the end of a line.
+func SQLMigration(version int64, name, up, down string) *Migration {
+	return &Migration{
+		Version: version,
+		Name:    name,
+		Up:      execScript(up),
+		Down:    execScript(down),
+	}
+}
+
+func execScript(script string) func(ctx context.Context, db *sqldb.SQL) error {
+	statements := splitStatements(script)
+	if len(statements) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, db *sqldb.SQL) error {
+		for _, statement := range statements {
+			if _, err := db.ExecQuery(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// statementSeparator a semicolon at the end of a line
+var statementSeparator = regexp.MustCompile(`;[ \t]*(\r?\n|$)`)
+
+func splitStatements(script string) []string {
+	var statements []string
+	for _, statement := range statementSeparator.Split(script, -1) {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// migrationFileName the file name of a migration, for exp: 0001_create_users.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadFS load the sql migrations of the dir, the files are named as {version}_{name}.up.sql
+// and {version}_{name}.down.sql, the down file is optional, for exp:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	list, err := migrate.LoadFS(migrations, "migrations")
+func LoadFS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(map[int64]*Migration)
+	var versions []int64
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrations[version] = migration
+			versions = append(versions, version)
+		} else if migration.Name != match[2] {
+			return nil, status.Errorf(codes.InvalidArgument, "migration %d has two names %s and %s",
+				version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = execScript(string(script))
+		} else {
+			migration.Down = execScript(string(script))
+		}
+	}
+	result := make([]*Migration, len(versions))
+	for i, version := range versions {
+		result[i] = migrations[version]
+	}
+	return result, nil
+}
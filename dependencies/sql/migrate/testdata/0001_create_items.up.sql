@@ -0,0 +1,2 @@
+CREATE TABLE `items` (`_id` INTEGER PRIMARY KEY AUTOINCREMENT, `name` TEXT);
+CREATE INDEX `items_name` ON `items` (`name`);
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"log/slog"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/sql/adapters/postgres"
+	"github.com/ti/common-go/dependencies/sql/adapters/sqlite"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -18,12 +21,125 @@ func GenerateScheme(table string, data any) string {
 		"\t`project`\tCHAR(64)\tnot null,\n", table)
 	docs := TransformScheme(data)
 	for _, v := range docs {
+		// the _id field of the model is the auto increment _id
+		if v.Key == database.IDKey {
+			continue
+		}
 		scheme += fmt.Sprintf("\t`%s`\t\t%s,\n", v.Key, v.Value)
 	}
 	scheme += fmt.Sprintf("\tKEY(`project`),\n\tconstraint %s_pk primary key (_id)\n);", table)
 	return scheme
 }
 
+// GenerateSQLiteScheme gen the sqlite scheme, the json fields are stored as TEXT and queried by json_extract.
+func GenerateSQLiteScheme(table string, data any) string {
+	scheme := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n"+
+		"\t`_id`\t\tINTEGER PRIMARY KEY AUTOINCREMENT,\n"+
+		"\t`project`\tTEXT\tNOT NULL DEFAULT ''", table)
+	docs := TransformScheme(data)
+	for _, v := range docs {
+		// the _id field of the model is the auto increment _id
+		if v.Key == database.IDKey {
+			continue
+		}
+		scheme += fmt.Sprintf(",\n\t`%s`\t\t%s", v.Key, sqliteColumnTypes[v.Value])
+	}
+	scheme += "\n);\n"
+	scheme += fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s_project` ON `%s` (`project`);", table, table)
+	return scheme
+}
+
+// sqliteColumnTypes the mapping of TransformScheme values to the sqlite column types
+var sqliteColumnTypes = map[any]string{
+	"datetime(6)":  "DATETIME",
+	"json":         "TEXT",
+	"bool":         "BOOLEAN",
+	"int":          "INTEGER",
+	"float":        "REAL",
+	"CHAR(64)":     "TEXT",
+	"VARCHAR(512)": "TEXT",
+}
+
+// GeneratePostgresScheme gen the postgres scheme, the _id is a bigserial, the json fields are stored as jsonb,
+// the slices of the scalars are the arrays of postgres, and the times are timestamptz.
+func GeneratePostgresScheme(table string, data any) string {
+	scheme := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n"+
+		"\t`_id`\t\tBIGSERIAL PRIMARY KEY,\n"+
+		"\t`project`\tVARCHAR(64)\tNOT NULL DEFAULT ''", table)
+	for _, v := range SchemeColumns(schemePostgres, data) {
+		// the _id field of the model is the bigserial _id
+		if v.Key == database.IDKey {
+			continue
+		}
+		scheme += fmt.Sprintf(",\n\t`%s`\t\t%s", v.Key, v.Value)
+	}
+	scheme += "\n);\n"
+	scheme += fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s_project` ON `%s` (`project`);", table, table)
+	return postgres.ConvertSQL(scheme)
+}
+
+// postgresColumnTypes the mapping of TransformScheme values to the postgres column types
+var postgresColumnTypes = map[any]string{
+	"datetime(6)":  "TIMESTAMPTZ",
+	"json":         "JSONB",
+	"bool":         "BOOLEAN",
+	"int":          "BIGINT",
+	"float":        "DOUBLE PRECISION",
+	"CHAR(64)":     "VARCHAR(64)",
+	"VARCHAR(512)": "TEXT",
+}
+
+// postgresArrayTypes the element types of the postgres arrays of the slices of the scalars, the slices are
+// written as the array literals {a,b} by marshal.
+var postgresArrayTypes = map[reflect.Kind]string{
+	reflect.String:  "TEXT",
+	reflect.Bool:    "BOOLEAN",
+	reflect.Int:     "BIGINT",
+	reflect.Int8:    "BIGINT",
+	reflect.Int16:   "BIGINT",
+	reflect.Int32:   "BIGINT",
+	reflect.Int64:   "BIGINT",
+	reflect.Uint16:  "BIGINT",
+	reflect.Uint32:  "BIGINT",
+	reflect.Uint64:  "BIGINT",
+	reflect.Float32: "DOUBLE PRECISION",
+	reflect.Float64: "DOUBLE PRECISION",
+}
+
+// ColumnType convert the column type of TransformScheme to the column type of the scheme
+func ColumnType(scheme string, schemeValue any) string {
+	switch scheme {
+	case schemeSQLite:
+		return sqliteColumnTypes[schemeValue]
+	case schemePostgres:
+		return postgresColumnTypes[schemeValue]
+	}
+	return fmt.Sprint(schemeValue)
+}
+
+// SchemeColumns the columns of the model with the column types of the scheme, the slices of the scalars are
+// the arrays on postgres rather than the json of ColumnType.
+func SchemeColumns(scheme string, ptrVal any) database.D {
+	switch scheme {
+	case schemeSQLite:
+		return transformScheme(ptrVal, func(ft reflect.Type) any {
+			return sqliteColumnTypes[columnValue(ft)]
+		})
+	case schemePostgres:
+		return transformScheme(ptrVal, postgresColumnType)
+	}
+	return TransformScheme(ptrVal)
+}
+
+func postgresColumnType(ft reflect.Type) any {
+	if kind := ft.Kind(); kind == reflect.Slice || kind == reflect.Array {
+		if elem, ok := postgresArrayTypes[ft.Elem().Kind()]; ok {
+			return elem + "[]"
+		}
+	}
+	return postgresColumnTypes[columnValue(ft)]
+}
+
 // GenerateDBScheme gen scheme
 func GenerateDBScheme(database string) string {
 	return "CREATE DATABASE IF NOT EXISTS " + database + ";"
@@ -31,10 +147,20 @@ func GenerateDBScheme(database string) string {
 
 // GenerateIndexScheme gen scheme
 func GenerateIndexScheme(table string, field []string, unique, reverOrder bool) string {
+	return generateIndexScheme(table, indexNameOf(field), field, unique, reverOrder)
+}
+
+// indexNameOf the default name of the index of the fields, it is the fields joined by "_" without the [*]
+// of the array key.
+func indexNameOf(field []string) string {
 	indexName := strings.Join(field, "_")
 	if i := strings.Index(indexName, "["); i > 0 {
 		indexName = indexName[0:i] + indexName[i+3:]
 	}
+	return indexName
+}
+
+func generateIndexScheme(table, indexName string, field []string, unique, reverOrder bool) string {
 	for i, v := range field {
 		if strings.Contains(v, "[") {
 			field[i] = generateArrayIndexScheme(v)
@@ -56,6 +182,91 @@ func GenerateIndexScheme(table string, field []string, unique, reverOrder bool)
 	return fmt.Sprintf(tpl, uniqueArgs, indexName, table, indexValue)
 }
 
+// GenerateSQLiteIndexScheme gen the sqlite index scheme, json keys are indexed by expression,
+// array members can not be indexed in sqlite, so the array fields are ignored.
+func GenerateSQLiteIndexScheme(table string, field []string, unique, reverOrder bool) string {
+	return generateSQLiteIndexScheme(table, indexNameOf(field), field, unique, reverOrder)
+}
+
+func generateSQLiteIndexScheme(table, indexName string, field []string, unique, reverOrder bool) string {
+	indexFields := make([]string, 0, len(field))
+	for _, v := range field {
+		if strings.Contains(v, "[") {
+			continue
+		}
+		if jsonKey, jsonSubKey, ok := strings.Cut(v, "."); ok {
+			indexFields = append(indexFields, "("+sqlite.JSONValue(jsonKey, jsonSubKey)+")")
+		} else {
+			indexFields = append(indexFields, "`"+v+"`")
+		}
+	}
+	if len(indexFields) == 0 {
+		return ""
+	}
+	indexValue := strings.Join(indexFields, ",")
+	if reverOrder {
+		indexValue += " DESC"
+	}
+	uniqueArgs := " UNIQUE"
+	if !unique {
+		uniqueArgs = ""
+	}
+	return fmt.Sprintf("CREATE%s INDEX IF NOT EXISTS `%s_%s` ON `%s` (%s);", uniqueArgs, table, indexName,
+		table, indexValue)
+}
+
+// GeneratePostgresIndexScheme gen the postgres index scheme. The json keys field.sub and the array keys
+// arr[*].id are matched by the @> containment of the jsonb fields, so the jsonb fields are indexed by a GIN
+// index of jsonb_path_ops, which is created apart from the btree index of the other fields. A GIN index can
+// not be unique, so the json keys of a unique index are indexed by their expressions, and an index with the
+// array keys is never unique.
+func GeneratePostgresIndexScheme(table string, field []string, unique, reverOrder bool) string {
+	return generatePostgresIndexScheme(table, indexNameOf(field), field, unique, reverOrder)
+}
+
+func generatePostgresIndexScheme(table, indexName string, field []string, unique, reverOrder bool) string {
+	indexName = table + "_" + indexName
+	if slices.ContainsFunc(field, func(v string) bool { return strings.Contains(v, "[") }) {
+		unique = false
+	}
+	var indexFields, ginFields []string
+	for _, v := range field {
+		jsonKey, jsonSubKey, ok := strings.Cut(v, ".")
+		switch {
+		case !ok:
+			indexFields = append(indexFields, "`"+v+"`")
+		case unique:
+			indexFields = append(indexFields, "("+postgres.JSONPath(jsonKey, jsonSubKey, false)+")")
+		default:
+			jsonKey, _, _ = strings.Cut(jsonKey, "[")
+			if ginField := "`" + jsonKey + "` jsonb_path_ops"; !slices.Contains(ginFields, ginField) {
+				ginFields = append(ginFields, ginField)
+			}
+		}
+	}
+	var statements []string
+	if len(indexFields) > 0 {
+		indexValue := strings.Join(indexFields, ",")
+		if reverOrder {
+			indexValue += " DESC"
+		}
+		uniqueArgs := " UNIQUE"
+		if !unique {
+			uniqueArgs = ""
+		}
+		statements = append(statements, fmt.Sprintf("CREATE%s INDEX IF NOT EXISTS `%s` ON `%s` (%s);",
+			uniqueArgs, indexName, table, indexValue))
+	}
+	if len(ginFields) > 0 {
+		if len(indexFields) > 0 {
+			indexName += "_gin"
+		}
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s` ON `%s` USING GIN (%s);",
+			indexName, table, strings.Join(ginFields, ",")))
+	}
+	return postgres.ConvertSQL(strings.Join(statements, "\n"))
+}
+
 // generateArrayIndexScheme generate array index scheme, for exp: data[*].id
 func generateArrayIndexScheme(field string) string {
 	dot := strings.Index(field, ".")
@@ -85,23 +296,46 @@ func generateJSONKeyIndexScheme(field string) string {
 	return fmt.Sprintf("(JSON_VALUE(`%s`, '$.%s' RETURNING CHAR(64)))", fields[0], fields[1])
 }
 
-// GenerateAutoExpScheme gen scheme
+// GenerateAutoExpScheme gen scheme, it is the index of the field and the event which deletes the expired rows
+// every day, the statements are separated by a new line.
 func GenerateAutoExpScheme(dbName, table, expiriedAtField string) string {
-	query := GenerateIndexScheme(table, []string{expiriedAtField}, false, false)
-	query += "\n"
-	tpl := `CREATE EVENT IF NOT EXISTS exp_%s_%s
-	ON SCHEDULE
-	EVERY 1 DAY
-	DO
-	BEGIN
-	DELETE FROM %s.%s WHERE %s < NOW();
-	END;`
-	query += fmt.Sprintf(tpl, dbName, table, dbName, table, expiriedAtField)
-	return query
+	return generateIndexScheme(table, expiriedAtField, []string{expiriedAtField}, false, false) + "\n" +
+		generateAutoExpEvent(dbName, table, "exp_"+dbName+"_"+table, expiriedAtField, 0) + ";"
+}
+
+// generateTTLIndexScheme the index of the ttl field, it is commented as ttl, so the index whose event is
+// missing is found, see ttlEventName.
+func generateTTLIndexScheme(table, indexName, field string) string {
+	return fmt.Sprintf("CREATE INDEX `%s` ON `%s` (`%s`) COMMENT '%s';", indexName, table, field, ttlIndexComment)
+}
+
+// ttlIndexComment the comment of the ttl indexes of mysql
+const ttlIndexComment = "ttl"
+
+// ttlEventName the name of the event of the ttl index of the table
+func ttlEventName(table, indexName string) string {
+	return "exp_" + table + "_" + indexName
+}
+
+// generateAutoExpEvent the event which deletes the rows whose field is older than the ttl every day, the
+// field is the expiration time if the ttl is 0. It is one statement, so it is executed without the
+// multiStatements of the dsn.
+func generateAutoExpEvent(dbName, table, eventName, field string, ttl time.Duration) string {
+	expiredAt := "NOW()"
+	if ttl > 0 {
+		expiredAt = fmt.Sprintf("NOW() - INTERVAL %d SECOND", int64(ttl/time.Second))
+	}
+	return fmt.Sprintf("CREATE EVENT IF NOT EXISTS `%s` ON SCHEDULE EVERY 1 DAY DO DELETE FROM `%s`.`%s` WHERE `%s` < %s",
+		eventName, dbName, table, field, expiredAt)
 }
 
 // TransformScheme transform any object to sql scheme
 func TransformScheme(ptrVal any) database.D {
+	return transformScheme(ptrVal, columnValue)
+}
+
+// transformScheme transform any object to the columns, the values are the column types of the field types
+func transformScheme(ptrVal any, columnType func(reflect.Type) any) database.D {
 	v := reflect.ValueOf(ptrVal).Elem()
 	t := v.Type()
 	var docs database.D
@@ -116,7 +350,7 @@ func TransformScheme(ptrVal any) database.D {
 			if sfv.IsNil() {
 				sv = reflect.New(reflect.TypeOf(sv).Elem()).Interface()
 			}
-			docs = append(docs, TransformScheme(sv)...)
+			docs = append(docs, transformScheme(sv, columnType)...)
 			continue
 		}
 		tag := sf.Tag.Get("json")
@@ -129,33 +363,48 @@ func TransformScheme(ptrVal any) database.D {
 			tag, _, _ = strings.Cut(tag, ",")
 		}
 		ft := sf.Type
-		e := database.E{
-			Key: tag,
-		}
-		switch ft.Kind() {
-		case reflect.Interface, reflect.Pointer, reflect.Array, reflect.Map, reflect.Struct, reflect.Slice:
-			if ft == timestampPtrType || ft == timeType {
-				e.Value = "datetime(6)"
-			} else {
-				e.Value = "json"
-			}
-		case reflect.Bool:
-			e.Value = "bool"
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
-			reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			e.Value = "int"
-		case reflect.Float32, reflect.Float64:
-			e.Value = "float"
-		case reflect.String:
-			e.Value = "CHAR(64)"
-		default:
-			e.Value = ft.Kind()
+		if encrypted, _ := database.EncryptOf(sf); encrypted {
+			ft = encryptedType
 		}
-		docs = append(docs, e)
+		docs = append(docs, database.E{
+			Key:   tag,
+			Value: columnType(ft),
+		})
 	}
 	return docs
 }
 
+// encryptedValue the type of the columns of the encrypted fields, the encrypted values are longer than the
+// plain strings, see database.EncryptOf.
+type encryptedValue string
+
+var encryptedType = reflect.TypeFor[encryptedValue]()
+
+// columnValue the column type of TransformScheme of the field type
+func columnValue(ft reflect.Type) any {
+	if ft == encryptedType {
+		return "VARCHAR(512)"
+	}
+	switch ft.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Array, reflect.Map, reflect.Struct, reflect.Slice:
+		if ft == timestampPtrType || ft == timeType {
+			return "datetime(6)"
+		}
+		return "json"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "CHAR(64)"
+	default:
+		return ft.Kind()
+	}
+}
+
 func convertDocsToSet(scheme string, d database.D) (query string, args []any) {
 	var cond string
 	for i, v := range d {
@@ -177,6 +426,19 @@ func convertDocsToSet(scheme string, d database.D) (query string, args []any) {
 			}
 			jsonKey := v.Key[1:subArrayIndex]
 			jsonSubKey := v.Key[dot+1:]
+			if scheme == schemePostgres {
+				doc, err := postgres.JSONDocument(jsonSubKey, v.Value)
+				if err != nil {
+					slog.Warn("cannot convert to json", "key", v.Key)
+					continue
+				}
+				// for exp: UPDATE account SET "groups" = COALESCE((SELECT jsonb_agg(e) FROM
+				// jsonb_array_elements("groups") e WHERE NOT e @> '{"id":"2333"}'), '[]') WHERE "sub" = 'xx_1';
+				query += fmt.Sprintf("%s`%s` = COALESCE((SELECT jsonb_agg(e) FROM jsonb_array_elements(`%s`) e "+
+					"WHERE NOT e @> ?::jsonb), '[]'::jsonb) ", cond, jsonKey, jsonKey)
+				args = append(args, doc)
+				continue
+			}
 			v.Key = jsonKey
 			// for exp: UPDATE test.account SET `groups` = JSON_REMOVE(`groups`,
 			// JSON_UNQUOTE(JSON_SEARCH(JSON_EXTRACT(`groups` ,'$[*].id'), 'one', '2333'))) WHERE `sub` = 'xx_1';
@@ -216,65 +478,300 @@ func convertDocsToSet(scheme string, d database.D) (query string, args []any) {
 	return
 }
 
-func tidySQLConds(conds database.C, compactMode bool) (query string, args []any) {
+func tidySQLConds(scheme string, conds database.C, compactMode bool) (query string, args []any) {
 	for i, v := range conds {
-		condQuery := tidySQLCond(v, compactMode)
+		condQuery, condArgs := tidySQLCond(scheme, v, compactMode)
 		if i > 0 {
 			query += queryAnd
 		}
 		query += condQuery + " "
-		if v.C == database.In || v.C == database.Nin {
-			data := reflect.ValueOf(v.Value)
-			for i := range data.Len() {
-				args = append(args, data.Index(i).Interface())
-			}
-		} else {
-			args = append(args, v.Value)
-		}
+		args = append(args, condArgs...)
 	}
 	return
 }
 
-// tidySQLConn support test=1 or test.id=1 or test[*].id = 1
-func tidySQLCond(cond database.CE, compactMode bool) string {
+// tidySQLConn support test=1 or test.id=1 or test[*].id = 1 and the nested Or, And, Not groups
+func tidySQLCond(scheme string, cond database.CE, compactMode bool) (string, []any) {
+	switch {
+	case cond.C.IsGroup():
+		return tidySQLGroup(scheme, cond, compactMode)
+	case cond.C == database.JSONMatch:
+		return tidySQLGroup(scheme, database.CE{Value: cond.JSONMatches(), C: database.And}, compactMode)
+	case cond.C == database.Search:
+		return tidySQLSearch(scheme, cond)
+	}
 	key := cond.Key
 	i := strings.Index(key, ".")
-	condition := conditionMap[cond.C]
 	if i <= 0 {
-		if cond.C == database.In || cond.C == database.Nin {
-			data := reflect.ValueOf(cond.Value)
-			n := data.Len()
-			holder := "?"
-			for j := 1; j < n; j++ {
-				holder += ",?"
-			}
-			return fmt.Sprintf("`%s` %s (%s)", key, condition, holder)
+		if cond.C == database.ArrayContains {
+			return tidySQLArrayContains(scheme, key, "", cond.Value)
 		}
-		return fmt.Sprintf("`%s` %s ?", key, condition)
+		return tidySQLColumnCond(scheme, fmt.Sprintf("`%s`", key), cond)
 	}
 	jsonKey := key[0:i]
 	jsonSubKey := key[i+1:]
 	subArrayIndex := strings.Index(jsonKey, "[")
 	if subArrayIndex < 0 {
+		if cond.C == database.ArrayContains {
+			return tidySQLArrayContains(scheme, jsonKey, jsonSubKey, cond.Value)
+		}
+		switch scheme {
+		case schemeSQLite:
+			return tidySQLColumnCond(scheme, sqlite.JSONValue(jsonKey, jsonSubKey), cond)
+		case schemePostgres:
+			return tidyPostgresJSONCond(jsonKey, jsonSubKey, cond)
+		}
+		if cond.C == database.Exists {
+			return tidyMySQLJSONExists(jsonKey, jsonSubKey, cond.ShouldExist()), nil
+		}
 		if compactMode {
-			return fmt.Sprintf("JSON_SEARCH(json_extract(`%s`,'$.%s'), 'one', ?) is not null", jsonKey, jsonSubKey)
+			if cond.C == database.Eq {
+				return fmt.Sprintf("JSON_SEARCH(json_extract(`%s`,'$.%s'), 'one', ?) is not null",
+					jsonKey, jsonSubKey), []any{cond.Value}
+			}
+			return tidySQLColumnCond(scheme, fmt.Sprintf("json_unquote(json_extract(`%s`,'$.%s'))",
+				jsonKey, jsonSubKey), cond)
+		}
+		if cond.C == database.Like || cond.C == database.Regex {
+			// compare the unquoted text of the json value
+			return tidySQLColumnCond(scheme, fmt.Sprintf("`%s`->>'$.%s'", jsonKey, jsonSubKey), cond)
 		}
-		return fmt.Sprintf("`%s`->'$.%s' %s ?", jsonKey, jsonSubKey, condition)
+		return tidySQLColumnCond(scheme, fmt.Sprintf("`%s`->'$.%s'", jsonKey, jsonSubKey), cond)
+	}
+	return tidySQLArrayCond(scheme, jsonKey[0:subArrayIndex], jsonSubKey, cond, compactMode)
+}
+
+// tidyMySQLJSONExists check if the json value at the path exists and is not the json null, the unquoted text
+// of the json null is 'null' in mysql, the null is treated as not exist like the columns, mongo and the mock.
+func tidyMySQLJSONExists(jsonKey, path string, exist bool) string {
+	query := fmt.Sprintf("IFNULL(JSON_CONTAINS_PATH(`%s`, 'one', '$.%s') AND JSON_TYPE(`%s`->'$.%s') <> 'NULL', 0)",
+		jsonKey, path, jsonKey, path)
+	if exist {
+		return query
 	}
-	jsonKey = jsonKey[0:subArrayIndex]
+	return "NOT " + query
+}
+
+// tidySQLArrayCond convert the condition of the elements of a json array, for exp groups[*].id, Eq and In
+// (and ArrayContains) match the arrays which have any element of the values, Ne and Nin match the arrays which
+// have none of them.
+func tidySQLArrayCond(scheme, jsonKey, jsonSubKey string, cond database.CE, compactMode bool) (string, []any) {
+	switch cond.C {
+	case database.Eq, database.ArrayContains:
+		return tidySQLArrayMember(scheme, jsonKey, jsonSubKey, cond.Value, compactMode)
+	case database.Ne:
+		query, args := tidySQLArrayMember(scheme, jsonKey, jsonSubKey, cond.Value, compactMode)
+		return sqlNot(query), args
+	case database.In, database.Nin:
+		data := reflect.ValueOf(cond.Value)
+		if data.Kind() != reflect.Slice && data.Kind() != reflect.Array {
+			return "1=0", nil
+		}
+		queries := make([]string, data.Len())
+		var args []any
+		for i := range queries {
+			var subArgs []any
+			queries[i], subArgs = tidySQLArrayMember(scheme, jsonKey, jsonSubKey, data.Index(i).Interface(),
+				compactMode)
+			args = append(args, subArgs...)
+		}
+		query := "1=0"
+		if len(queries) > 0 {
+			query = "(" + strings.Join(queries, " OR ") + ")"
+		}
+		if cond.C == database.Nin {
+			query = sqlNot(query)
+		}
+		return query, args
+	default:
+		slog.Warn("the json array supports the Eq, Ne, In and Nin conditions only", "key", cond.Key)
+		return "1=0", nil
+	}
+}
+
+// tidySQLArrayMember check if the json array of the column has an element of the value
+func tidySQLArrayMember(scheme, jsonKey, jsonSubKey string, value any, compactMode bool) (string, []any) {
 	queryDot := "."
 	if jsonSubKey == "" {
 		queryDot = ""
 	}
+	switch scheme {
+	case schemeSQLite:
+		return sqlite.JSONMemberOf(jsonKey, jsonSubKey), []any{value}
+	case schemePostgres:
+		doc, err := postgres.JSONArrayDocument(jsonSubKey, value)
+		if err != nil {
+			slog.Warn("invalid json array condition", "key", jsonKey, "error", err)
+			return "1=0", nil
+		}
+		return fmt.Sprintf("`%s` @> ?::jsonb", jsonKey), []any{doc}
+	}
 	if compactMode {
 		if queryDot == "" {
-			return fmt.Sprintf("JSON_CONTAINS(`%s`, '?', '$')", jsonKey)
+			return fmt.Sprintf("JSON_CONTAINS(`%s`, '?', '$')", jsonKey), []any{value}
 		}
 		// same as MariaDB: JSON_CONTAINS(json_extract(`groups`,'$[*].id'), '"?"', '$');
 		// same as Mysql: JSON_CONTAINS(`groups`->'$[*].id', '"?"');
-		return fmt.Sprintf("JSON_SEARCH(json_extract(`%s`,'$[*]%s%s'), 'one', ?) is not null", jsonKey, queryDot, jsonSubKey)
+		return fmt.Sprintf("JSON_SEARCH(json_extract(`%s`,'$[*]%s%s'), 'one', ?) is not null",
+			jsonKey, queryDot, jsonSubKey), []any{value}
+	}
+	return fmt.Sprintf("? MEMBER OF(`%s`->'$[*]%s%s')", jsonKey, queryDot, jsonSubKey), []any{value}
+}
+
+// tidySQLGroup convert the Or, And, Not groups to (a OR b), (a AND b) and NOT (a AND b),
+// an empty Or or Not group matches nothing and an empty And group matches everything.
+func tidySQLGroup(scheme string, cond database.CE, compactMode bool) (string, []any) {
+	group := cond.Group()
+	if len(group) == 0 {
+		if cond.C == database.And {
+			return "1=1", nil
+		}
+		return "1=0", nil
+	}
+	sep := " AND "
+	if cond.C == database.Or {
+		sep = " OR "
+	}
+	queries := make([]string, len(group))
+	var args []any
+	for i, v := range group {
+		var subArgs []any
+		queries[i], subArgs = tidySQLCond(scheme, v, compactMode)
+		args = append(args, subArgs...)
+	}
+	query := "(" + strings.Join(queries, sep) + ")"
+	if cond.C == database.Not {
+		query = sqlNot(query)
+	}
+	return query, args
+}
+
+// sqlNot negates the condition, the unknown result of the null values is false before it is negated, so
+// the rows whose fields are null are matched like mongo and the mock.
+func sqlNot(query string) string {
+	return "NOT COALESCE(" + query + ", FALSE)"
+}
+
+// tidySQLColumnCond convert the condition of a column or json value expression
+func tidySQLColumnCond(scheme, field string, cond database.CE) (string, []any) {
+	condition := conditionMap[cond.C]
+	switch cond.C {
+	case database.In, database.Nin:
+		data := reflect.ValueOf(cond.Value)
+		n := data.Len()
+		if n == 0 {
+			// IN () is invalid sql, nothing is in an empty list
+			if cond.C == database.In {
+				return "1=0", nil
+			}
+			return "1=1", nil
+		}
+		args := make([]any, n)
+		for j := range n {
+			args[j] = data.Index(j).Interface()
+		}
+		return fmt.Sprintf("%s %s (%s)", field, condition, strings.Repeat(",?", n)[1:]), args
+	case database.Like:
+		// the backslash escapes the wildcards like mysql, the like of postgres is case-sensitive
+		switch scheme {
+		case schemePostgres:
+			return fmt.Sprintf("%s ILIKE ? ESCAPE '\\'", field), []any{cond.Value}
+		case schemeSQLite:
+			return fmt.Sprintf("%s LIKE ? ESCAPE '\\'", field), []any{cond.Value}
+		}
+		return fmt.Sprintf("%s LIKE ?", field), []any{cond.Value}
+	case database.Regex:
+		// the regex is case-sensitive, the REGEXP of mysql follows the case-insensitive collations by default
+		switch scheme {
+		case schemePostgres:
+			return fmt.Sprintf("%s ~ ?", field), []any{cond.Value}
+		case schemeSQLite:
+			return fmt.Sprintf("%s REGEXP ?", field), []any{cond.Value}
+		}
+		return fmt.Sprintf("REGEXP_LIKE(%s, ?, 'c')", field), []any{cond.Value}
+	case database.Exists:
+		if cond.ShouldExist() {
+			return fmt.Sprintf("%s IS NOT NULL", field), nil
+		}
+		return fmt.Sprintf("%s IS NULL", field), nil
+	case database.Between:
+		from, to, err := cond.Range()
+		if err != nil {
+			slog.Warn("invalid between condition", "error", err)
+			return "1=0", nil
+		}
+		return fmt.Sprintf("%s BETWEEN ? AND ?", field), []any{from, to}
+	default:
+		return fmt.Sprintf("%s %s ?", field, condition), []any{cond.Value}
+	}
+}
+
+// tidySQLArrayContains check if the json array of the column, or the json array at the path of the column,
+// contains the value. The column of postgres is an array of the scalars, and the path is in a jsonb column.
+func tidySQLArrayContains(scheme, jsonKey, path string, value any) (string, []any) {
+	switch scheme {
+	case schemeSQLite:
+		return sqlite.JSONArrayContains(jsonKey, path), []any{value}
+	case schemePostgres:
+		if path == "" {
+			return fmt.Sprintf("? = ANY(`%s`)", jsonKey), []any{value}
+		}
+		doc, err := postgres.JSONArrayDocument("", value)
+		if err != nil {
+			slog.Warn("invalid array contains condition", "key", jsonKey, "error", err)
+			return "1=0", nil
+		}
+		return postgres.JSONPath(jsonKey, path, false) + " @> ?::jsonb", []any{doc}
+	}
+	if path == "" {
+		return fmt.Sprintf("? MEMBER OF(`%s`)", jsonKey), []any{value}
+	}
+	return fmt.Sprintf("? MEMBER OF(`%s`->'$.%s')", jsonKey, path), []any{value}
+}
+
+// tidyPostgresJSONCond convert the condition of the json key to the jsonb operators, the equal condition is
+// the @> containment which is served by the GIN index of the column, the other values are compared as jsonb,
+// so the numbers are not compared as text, and the text conditions compare the text of the value.
+func tidyPostgresJSONCond(jsonKey, path string, cond database.CE) (string, []any) {
+	switch cond.C {
+	case database.Eq:
+		doc, err := postgres.JSONDocument(path, cond.Value)
+		if err != nil {
+			slog.Warn("invalid json condition", "key", cond.Key, "error", err)
+			return "1=0", nil
+		}
+		return fmt.Sprintf("`%s` @> ?::jsonb", jsonKey), []any{doc}
+	case database.Like, database.Regex, database.Exists:
+		return tidySQLColumnCond(schemePostgres, postgres.JSONPath(jsonKey, path, true), cond)
+	}
+	value, err := jsonCondValue(cond)
+	if err != nil {
+		slog.Warn("invalid json condition", "key", cond.Key, "error", err)
+		return "1=0", nil
+	}
+	cond.Value = value
+	return tidySQLColumnCond(schemePostgres, postgres.JSONPath(jsonKey, path, false), cond)
+}
+
+// jsonCondValue the json of the value of the condition, the values of In, Nin and Between are the json of
+// the elements.
+func jsonCondValue(cond database.CE) (any, error) {
+	if cond.C != database.In && cond.C != database.Nin && cond.C != database.Between {
+		return postgres.JSONValue(cond.Value)
+	}
+	data := reflect.ValueOf(cond.Value)
+	if data.Kind() != reflect.Slice && data.Kind() != reflect.Array {
+		return cond.Value, nil
+	}
+	values := make([]string, data.Len())
+	for i := range values {
+		value, err := postgres.JSONValue(data.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
 	}
-	return fmt.Sprintf("? MEMBER OF(`%s`->'$[*]%s%s')", jsonKey, queryDot, jsonSubKey)
+	return values, nil
 }
 
 var jsonKinds = map[reflect.Kind]bool{
@@ -287,12 +784,13 @@ var jsonKinds = map[reflect.Kind]bool{
 }
 
 var conditionMap = map[database.Condition]string{
-	database.Eq:  "=",
-	database.Ne:  "!=",
-	database.Lt:  "<",
-	database.Lte: "<=",
-	database.Gt:  ">",
-	database.Gte: ">=",
-	database.In:  "IN",
-	database.Nin: "NOT IN",
+	database.Eq:   "=",
+	database.Ne:   "!=",
+	database.Lt:   "<",
+	database.Lte:  "<=",
+	database.Gt:   ">",
+	database.Gte:  ">=",
+	database.In:   "IN",
+	database.Nin:  "NOT IN",
+	database.Like: "LIKE",
 }
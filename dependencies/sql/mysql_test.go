@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+)
+
+func TestMySQLConditions(t *testing.T) {
+	exists := "IFNULL(JSON_CONTAINS_PATH(`profile`, 'one', '$.nick') AND JSON_TYPE(`profile`->'$.nick') <> 'NULL', 0)"
+	tests := []struct {
+		cond        database.CE
+		compactMode bool
+		query       string
+		args        []any
+	}{
+		{database.CE{Key: "name", Value: "^a", C: database.Regex}, false, "REGEXP_LIKE(`name`, ?, 'c')",
+			[]any{"^a"}},
+		{database.CE{Key: "profile.city", Value: "^b", C: database.Regex}, false,
+			"REGEXP_LIKE(`profile`->>'$.city', ?, 'c')", []any{"^b"}},
+		{database.CE{Key: "profile.city", Value: "^b", C: database.Regex}, true,
+			"REGEXP_LIKE(json_unquote(json_extract(`profile`,'$.city')), ?, 'c')", []any{"^b"}},
+		{database.CE{Key: "name", Value: true, C: database.Exists}, false, "`name` IS NOT NULL", nil},
+		{database.CE{Key: "profile.nick", Value: true, C: database.Exists}, false, exists, nil},
+		{database.CE{Key: "profile.nick", Value: true, C: database.Exists}, true, exists, nil},
+		{database.CE{Key: "profile.nick", Value: false, C: database.Exists}, false, "NOT " + exists, nil},
+	}
+	for _, v := range tests {
+		query, args := tidySQLCond(schemeMysql, v.cond, v.compactMode)
+		if query != v.query || !slices.Equal(args, v.args) {
+			t.Fatalf("condition %s\n got: %s %v\nwant: %s %v", v.cond.Key, query, args, v.query, v.args)
+		}
+	}
+}
+
+func TestMySQLInsertIDs(t *testing.T) {
+	type article struct {
+		ID    int64  `json:"_id"`
+		Title string `json:"title"`
+	}
+	tests := []struct {
+		scheme string
+		lastID int64
+		step   int64
+		want   []int64
+	}{
+		{schemeMysql, 11, 1, []int64{11, 12, 13}},
+		{schemeMysql, 11, 2, []int64{11, 13, 15}},
+		{schemeMysql, 5, 10, []int64{5, 15, 25}},
+		{schemeSQLite, 13, 1, []int64{11, 12, 13}},
+	}
+	for _, v := range tests {
+		docs := []*article{{Title: "a"}, {Title: "b"}, {Title: "c"}}
+		ids := make([]*database.ID, len(docs))
+		for i, doc := range docs {
+			ids[i], _ = database.IDOf(doc)
+		}
+		setInsertIDs(v.scheme, ids, v.lastID, v.step)
+		got := []int64{docs[0].ID, docs[1].ID, docs[2].ID}
+		if !slices.Equal(got, v.want) {
+			t.Fatalf("%s ids of the last id %d and the step %d, got %v, want %v", v.scheme, v.lastID, v.step,
+				got, v.want)
+		}
+	}
+}
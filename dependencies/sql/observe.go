@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/sql/adapters/postgres"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operation the table, operation and conditions of the statements of a method, they are the labels and log
+// fields of the observer.
+type operation struct {
+	table string
+	op    string
+	conds database.C
+}
+
+type operationKey struct{}
+
+// operation set the operation of the statements in the ctx and starts its span, the done ends the span with
+// the error of the operation. The ctx is returned as is if nothing is observed or traced, or the operation
+// is set by the caller, for exp: the statements of DeleteOne are the statements of deleteOne.
+func (s *SQL) operation(ctx context.Context, table, op string, conds database.C) (context.Context, func(error)) {
+	if s.observer == nil && s.tracer == nil {
+		return ctx, func(error) {}
+	}
+	if _, ok := ctx.Value(operationKey{}).(*operation); ok {
+		return ctx, func(error) {}
+	}
+	ctx = context.WithValue(ctx, operationKey{}, &operation{table: table, op: op, conds: conds})
+	if s.txCtx != nil {
+		// the spans of the operations in the transaction are the children of the span of the transaction
+		ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(s.txCtx))
+	}
+	return s.tracer.Start(ctx, table, op, conds)
+}
+
+// observe the statement which started at the start, the operation of a statement which is not executed by
+// the methods of SQL is the verb of the statement.
+func (s *SQL) observe(ctx context.Context, start time.Time, query string, args []any) {
+	if s.observer == nil {
+		return
+	}
+	op, ok := ctx.Value(operationKey{}).(*operation)
+	if !ok {
+		verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+		op = &operation{op: strings.ToLower(verb)}
+	}
+	s.observer.Observe(ctx, op.table, op.op, op.conds, start, func(ctx context.Context) (string, error) {
+		return s.explain(ctx, query, args)
+	})
+}
+
+// explain returns the query plan of the statement, the rows of the plan are separated by new lines.
+func (s *SQL) explain(ctx context.Context, query string, args []any) (string, error) {
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	switch strings.ToUpper(verb) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE":
+	default:
+		return "", fmt.Errorf("%s can not be explained", verb)
+	}
+	prefix := "EXPLAIN "
+	if s.scheme == schemeSQLite {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	query = prefix + query
+	if s.scheme == schemePostgres {
+		query = postgres.ConvertSQL(query)
+	}
+	// the plan is explained out of the transaction, which may be finished before the explain
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var plan []string
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		fields := make([]string, len(values))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			fields[i] = fmt.Sprint(v)
+		}
+		plan = append(plan, strings.Join(fields, " | "))
+	}
+	return strings.Join(plan, "\n"), rows.Err()
+}
@@ -0,0 +1,123 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/log"
+)
+
+// recordLogger records the fields of the logs
+type recordLogger struct {
+	mu   sync.Mutex
+	logs []map[string]any
+}
+
+func (r *recordLogger) find(op string) map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.logs {
+		if v["op"] == op {
+			return v
+		}
+	}
+	return nil
+}
+
+func (r *recordLogger) Action(action string) log.StdLogger {
+	return r.With(map[string]any{"action": action})
+}
+
+func (r *recordLogger) With(m map[string]any) log.StdLogger {
+	return &recordStdLogger{r: r, fields: m}
+}
+
+func (r *recordLogger) Inject(map[string]any) {}
+
+type recordStdLogger struct {
+	r      *recordLogger
+	fields map[string]any
+}
+
+func (l *recordStdLogger) record(msg string, args ...any) {
+	l.r.mu.Lock()
+	defer l.r.mu.Unlock()
+	fields := map[string]any{"msg": fmt.Sprintf(msg, args...)}
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	l.r.logs = append(l.r.logs, fields)
+}
+
+func (l *recordStdLogger) Debug(msg string, args ...any) { l.record(msg, args...) }
+func (l *recordStdLogger) Info(msg string, args ...any)  { l.record(msg, args...) }
+func (l *recordStdLogger) Warn(msg string, args ...any)  { l.record(msg, args...) }
+func (l *recordStdLogger) Error(msg string, args ...any) { l.record(msg, args...) }
+func (l *recordStdLogger) Fatal(msg string, args ...any) { l.record(msg, args...) }
+
+func TestSQLiteSlowQuery(t *testing.T) {
+	logger := &recordLogger{}
+	ctx := log.NewContextWithLogger(context.Background(), logger)
+	db, err := New(ctx, "sqlite:///:memory:?slowQuery=1ns&explain=true&metrics=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := db.(*SQL)
+	t.Cleanup(func() {
+		_ = s.Close(ctx)
+	})
+	if err = s.EnsureTable(ctx, "users", &sqliteUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.InsertOne(ctx, "users", &sqliteUser{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	conds := database.C{{Key: "name", Value: "a"}}
+	var users []*sqliteUser
+	if err = s.Find(ctx, "users", conds, nil, 0, &users); err != nil || len(users) != 1 {
+		t.Fatalf("unexpected find %v %v", users, err)
+	}
+	// the slow operation is logged after the plan is explained in background
+	var found map[string]any
+	for deadline := time.Now().Add(5 * time.Second); found == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		found = logger.find("find")
+	}
+	if found == nil {
+		t.Fatal("the slow find is not logged")
+	}
+	if found["action"] != "sql.slowQuery" || found["table"] != "users" || found["condition"] != conds.String() {
+		t.Fatalf("unexpected slow query log %v", found)
+	}
+	if plan, _ := found["explain"].(string); !strings.Contains(plan, "users") {
+		t.Fatalf("unexpected query plan %v", found["explain"])
+	}
+	families, err := prom.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var observed bool
+	for _, family := range families {
+		if family.GetName() != "db_operation_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["backend"] == "sql" && labels["table"] == "users" && labels["op"] == "insertOne" {
+				observed = metric.GetHistogram().GetSampleCount() > 0
+			}
+		}
+	}
+	if !observed {
+		t.Fatal("the insert is not observed by db_operation_seconds")
+	}
+}
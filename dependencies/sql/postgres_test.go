@@ -0,0 +1,90 @@
+package sql
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/sql/adapters/postgres"
+)
+
+type postgresUser struct {
+	ID        int64          `json:"_id"`
+	Name      string         `json:"name"`
+	Tags      []string       `json:"tags"`
+	Profile   *sqliteProfile `json:"profile"`
+	Groups    []*sqliteGroup `json:"groups"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func TestGeneratePostgresScheme(t *testing.T) {
+	scheme := GeneratePostgresScheme("users", &postgresUser{})
+	for _, v := range []string{
+		`CREATE TABLE IF NOT EXISTS "users"`,
+		`"_id"		BIGSERIAL PRIMARY KEY`,
+		`"tags"		TEXT[]`,
+		`"profile"		JSONB`,
+		`"groups"		JSONB`,
+		`"created_at"		TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS "users_project" ON "users" ("project");`,
+	} {
+		if !strings.Contains(scheme, v) {
+			t.Fatalf("%s is not in the scheme\n%s", v, scheme)
+		}
+	}
+	if strings.Count(scheme, `"_id"`) != 1 || strings.Contains(scheme, "`") {
+		t.Fatalf("unexpected scheme\n%s", scheme)
+	}
+}
+
+func TestGeneratePostgresIndexScheme(t *testing.T) {
+	tests := []struct {
+		field  []string
+		unique bool
+		want   string
+	}{
+		{[]string{"name"}, true, `CREATE UNIQUE INDEX IF NOT EXISTS "users_name" ON "users" ("name");`},
+		{[]string{"profile.city"}, false,
+			`CREATE INDEX IF NOT EXISTS "users_profile.city" ON "users" USING GIN ("profile" jsonb_path_ops);`},
+		{[]string{"profile.city"}, true,
+			`CREATE UNIQUE INDEX IF NOT EXISTS "users_profile.city" ON "users" (("profile"->'city'));`},
+		{[]string{"name", "groups[*].id"}, true,
+			`CREATE INDEX IF NOT EXISTS "users_name_groups.id" ON "users" ("name");` + "\n" +
+				`CREATE INDEX IF NOT EXISTS "users_name_groups.id_gin" ON "users" USING GIN ("groups" jsonb_path_ops);`},
+	}
+	for _, v := range tests {
+		if got := GeneratePostgresIndexScheme("users", v.field, v.unique, false); got != v.want {
+			t.Fatalf("index of %v\n got: %s\nwant: %s", v.field, got, v.want)
+		}
+	}
+}
+
+func TestPostgresJSONConditions(t *testing.T) {
+	tests := []struct {
+		cond  database.CE
+		query string
+		args  []any
+	}{
+		{database.CE{Key: "profile.city", Value: "sh"}, `"profile" @> $1::jsonb`, []any{`{"city":"sh"}`}},
+		{database.CE{Key: "profile.geo.lat", Value: 10, C: database.Gt}, `"profile"->'geo'->'lat' > $1`,
+			[]any{"10"}},
+		{database.CE{Key: "profile.age", Value: []int{1, 2}, C: database.In}, `"profile"->'age' IN ($1,$2)`,
+			[]any{"1", "2"}},
+		{database.CE{Key: "profile.city", Value: "s%", C: database.Like}, `"profile"->>'city' ILIKE $1 ESCAPE '\'`,
+			[]any{"s%"}},
+		{database.CE{Key: "groups[*].id", Value: "a"}, `"groups" @> $1::jsonb`, []any{`[{"id":"a"}]`}},
+		{database.CE{Key: "groups[*].id", Value: "a", C: database.Ne}, `NOT COALESCE("groups" @> $1::jsonb, FALSE)`,
+			[]any{`[{"id":"a"}]`}},
+		{database.CE{Key: "tags", Value: "a", C: database.ArrayContains}, `$1 = ANY("tags")`, []any{"a"}},
+		{database.CE{Key: "profile.tags", Value: "a", C: database.ArrayContains}, `"profile"->'tags' @> $1::jsonb`,
+			[]any{`["a"]`}},
+	}
+	for _, v := range tests {
+		query, args := tidySQLCond(schemePostgres, v.cond, false)
+		if query = postgres.ConvertSQL(query); query != v.query || !slices.Equal(args, v.args) {
+			t.Fatalf("condition %s\n got: %s %v\nwant: %s %v", v.cond.Key, query, args, v.query, v.args)
+		}
+	}
+}
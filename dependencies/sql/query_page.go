@@ -3,15 +3,31 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"reflect"
 
 	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // PageQuery query the documents
 func PageQuery[T any](ctx context.Context, s *SQL, table string,
 	in *database.PageQueryRequest,
-) (out *database.PageQueryResponse[T], err error) {
+) (*database.PageQueryResponse[T], error) {
+	out := &database.PageQueryResponse[T]{}
+	if err := s.DoPageQuery(ctx, table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DoPageQuery implements database.PageQuerier, the result is a *database.PageQueryResponse[T]
+func (s *SQL) DoPageQuery(ctx context.Context, table string, in *database.PageQueryRequest, result any) (err error) {
+	ctx, done := s.operation(ctx, table, "pageQuery", in.Filters)
+	defer func() { done(err) }()
+	out, err := database.NewQueryResult(result)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
 	query := &Query{
 		Table: table,
 	}
@@ -20,24 +36,29 @@ func PageQuery[T any](ctx context.Context, s *SQL, table string,
 	} else {
 		query.Limit = 2000
 	}
-	var data T
-	fullQuery := TransformSQLQuery(&data)
+	fullQuery := TransformSQLQuery(out.New())
 	var selectFields map[string]bool
 	query.Select, selectFields = ParseSelect(fullQuery, in.Select)
-	query.Order = ParseSort(in.Sort)
 	query.Offset = ParseOffset(in.Page, in.Limit)
-	query.Where, query.Arguments = ParseWhere(in.Filters, s.project)
-	out = &database.PageQueryResponse[T]{}
-	var rows *sql.Rows
+	query.Where, query.Arguments = parseWhere(s.scheme, in.Filters, s.project)
+	var orderArgs []any
+	query.Order, orderArgs, err = parseOrder(s.scheme, in.Filters, in.Sort, func(v string) string {
+		return ParseSort([]string{v})
+	})
+	if err != nil {
+		return err
+	}
+	query.Arguments = append(query.Arguments, orderArgs...)
 	// nolint: rowserrcheck
-	rows, out.Total, err = queryData(ctx, table, s, in.Filters, query, in.NoCount)
+	rows, total, err := queryData(ctx, table, s, in.Filters, query, in.NoCount)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	out.SetTotal(total)
 	dataRows := DataRows{
 		Rows:         rows,
 		scheme:       s.scheme,
-		dataType:     reflect.TypeOf(data),
+		dataType:     out.ElemType,
 		selectFields: selectFields,
 		timeLoc:      s.loc,
 	}
@@ -47,11 +68,11 @@ func PageQuery[T any](ctx context.Context, s *SQL, table string,
 	for dataRows.Next() {
 		rowData, errDec := dataRows.Decode()
 		if errDec != nil {
-			return nil, errDec
+			return errDec
 		}
-		out.Data = append(out.Data, rowData.(*T))
+		out.Append(rowData)
 	}
-	return
+	return nil
 }
 
 func queryData(ctx context.Context, table string, s *SQL, filters database.C, query *Query,
@@ -63,7 +84,7 @@ func queryData(ctx context.Context, table string, s *SQL, filters database.C, qu
 			return
 		}
 	}
-	rows, err = s.QueryContext(ctx, query.String(), query.Arguments...)
+	rows, err = s.readQueryContext(ctx, query.String(), query.Arguments...)
 	if err != nil {
 		return
 	}
@@ -2,11 +2,14 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"math/big"
 	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/ti/common-go/dependencies/database"
 	"google.golang.org/grpc/codes"
@@ -16,7 +19,30 @@ import (
 // StreamQuery query the documents
 func StreamQuery[T any](ctx context.Context, s *SQL, table string,
 	in *database.StreamQueryRequest,
-) (out *database.StreamResponse[T], err error) {
+) (*database.StreamResponse[T], error) {
+	out := &database.StreamResponse[T]{}
+	if err := s.DoStreamQuery(ctx, table, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DoStreamQuery implements database.StreamQuerier, the result is a *database.StreamResponse[T], the stream
+// is turned by `_id` if in.Sort is empty.
+func (s *SQL) DoStreamQuery(ctx context.Context, table string, in *database.StreamQueryRequest, result any) (err error) {
+	ctx, done := s.operation(ctx, table, "streamQuery", in.Filters)
+	defer func() { done(err) }()
+	out, err := database.NewQueryResult(result)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if database.HasSearchScore(in.Sort) {
+		return status.Errorf(codes.InvalidArgument, "%s can not be a sort key of the stream query",
+			database.SearchScore)
+	}
+	if len(in.Sort) > 0 {
+		return s.doSortedStreamQuery(ctx, table, in, out)
+	}
 	query := &Query{
 		Table:    table,
 		SelectID: true,
@@ -27,24 +53,22 @@ func StreamQuery[T any](ctx context.Context, s *SQL, table string,
 	}
 	query.Where, query.Arguments, err = parsePageTokenWhere(in.PageToken, in.Ascending)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var data T
 	var selectFields map[string]bool
-	fullQuery := TransformSQLQuery(&data)
+	fullQuery := TransformSQLQuery(out.New())
 	query.Select, selectFields = ParseSelect(fullQuery, in.Select)
 	parseOrderQuery(query, s, in)
-	out = &database.StreamResponse[T]{}
-	var rows *sql.Rows
 	// nolint: rowserrcheck // it is checked in query data
-	rows, out.Total, err = queryData(ctx, table, s, in.Filters, query, in.NoCount)
+	rows, total, err := queryData(ctx, table, s, in.Filters, query, in.NoCount)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	out.SetTotal(total)
 	dataRows := DataRows{
 		Rows:         rows,
 		scheme:       s.scheme,
-		dataType:     reflect.TypeOf(data),
+		dataType:     out.ElemType,
 		selectFields: selectFields,
 		timeLoc:      s.loc,
 	}
@@ -57,34 +81,32 @@ func StreamQuery[T any](ctx context.Context, s *SQL, table string,
 	for dataRows.Next() {
 		rowData, id, errDec := dataRows.DecodeWithID()
 		if errDec != nil {
-			return nil, errDec
+			return errDec
 		}
 		if firstID == 0 {
 			firstID = id
 		} else {
 			lastID = id
 		}
-		out.Data = append(out.Data, rowData.(*T))
+		out.Append(rowData)
 	}
 	if in.Ascending {
-		for i, j := 0, len(out.Data)-1; i < j; i, j = i+1, j-1 {
-			out.Data[i], out.Data[j] = out.Data[j], out.Data[i]
+		swap := reflect.Swapper(out.Data().Interface())
+		for i, j := 0, out.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
 		}
 	}
-	if len(out.Data) == 0 {
-		return
+	if out.Len() == 0 {
+		return nil
 	}
 	if in.PageToken == "" {
 		firstID = 0
 	}
-	if len(out.Data) < in.Limit {
+	if out.Len() < in.Limit {
 		lastID = 0
 	}
-	if firstID == 0 || lastID == 0 {
-		out.PageToken = encodePageToken(firstID, lastID)
-	}
-	out.PageToken = encodePageToken(firstID, lastID)
-	return
+	out.SetPageToken(encodePageToken(firstID, lastID))
+	return nil
 }
 
 func parseOrderQuery(query *Query, s *SQL, in *database.StreamQueryRequest) {
@@ -92,7 +114,12 @@ func parseOrderQuery(query *Query, s *SQL, in *database.StreamQueryRequest) {
 	if !in.Ascending {
 		query.Order = "`_id` DESC"
 	}
-	if where, args := ParseWhere(in.Filters, s.project); where != "" {
+	appendWhere(query, s, in.Filters)
+}
+
+// appendWhere append the filters to the where of the query
+func appendWhere(query *Query, s *SQL, filters database.C) {
+	if where, args := parseWhere(s.scheme, filters, s.project); where != "" {
 		if query.Where != "" {
 			query.Where += queryAnd + where
 			query.Arguments = append(query.Arguments, args...)
@@ -103,6 +130,127 @@ func parseOrderQuery(query *Query, s *SQL, in *database.StreamQueryRequest) {
 	}
 }
 
+// doSortedStreamQuery the stream query sorted by the keys of in.Sort and `_id`, the page token holds
+// the sort values and the `_id` of the last row.
+func (s *SQL) doSortedStreamQuery(ctx context.Context, table string, in *database.StreamQueryRequest,
+	out *database.QueryResult,
+) error {
+	query := &Query{
+		Table:    table,
+		SelectID: true,
+		Limit:    2000,
+	}
+	if in.Limit > 0 && in.Limit < 2000 {
+		query.Limit = in.Limit
+	}
+	sort := database.WithTieBreaker(in.Sort, "_id")
+	if in.PageToken != "" {
+		values, err := database.DecodeSortToken(in.PageToken, sort)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		query.Where, query.Arguments = s.keysetWhere(sort, values)
+	}
+	appendWhere(query, s, in.Filters)
+	var selectFields map[string]bool
+	query.Select, selectFields = ParseSelect(TransformSQLQuery(out.New()), database.SelectSortKeys(in.Select, in.Sort))
+	query.Order = ParseSort(quoteSort(sort))
+	// nolint: rowserrcheck // it is checked in query data
+	rows, total, err := queryData(ctx, table, s, in.Filters, query, in.NoCount)
+	if err != nil {
+		return err
+	}
+	out.SetTotal(total)
+	dataRows := DataRows{
+		Rows:         rows,
+		scheme:       s.scheme,
+		dataType:     out.ElemType,
+		selectFields: selectFields,
+		timeLoc:      s.loc,
+	}
+	defer func() {
+		_ = dataRows.Close()
+	}()
+	var last any
+	var lastID int64
+	for dataRows.Next() {
+		rowData, id, errDec := dataRows.DecodeWithID()
+		if errDec != nil {
+			return errDec
+		}
+		last, lastID = rowData, id
+		out.Append(rowData)
+	}
+	if out.Len() < query.Limit {
+		return nil
+	}
+	// the _id is not a field of the rows
+	keys, _ := database.SortKeys(sort)
+	idIndex := slices.Index(keys, "_id")
+	values, err := database.SortValues(last, slices.Delete(slices.Clone(sort), idIndex, idIndex+1))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	pageToken, err := database.EncodeSortToken(sort, slices.Insert(values, idIndex, any(lastID)))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	out.SetPageToken(pageToken)
+	return nil
+}
+
+// keysetWhere the rows after the sort values, it is (`a`,`b`,`_id`) > (?,?,?) if the keys are sorted
+// in the same direction and the nulls are before the values, or `a` > ? OR (`a` = ? AND `b` < ?) ...
+func (s *SQL) keysetWhere(sort []string, values []any) (string, []any) {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = s.keysetArg(v)
+	}
+	keys, desc := database.SortKeys(sort)
+	// the nulls are the largest values of postgres, and the smallest values of mysql and sqlite
+	nullsLast := s.scheme == schemePostgres
+	if slices.Contains(desc, !desc[0]) || desc[0] != nullsLast || slices.Contains(args, nil) ||
+		slices.ContainsFunc(keys, func(key string) bool {
+			return strings.Contains(key, ".")
+		}) {
+		return tidySQLConds(s.scheme, database.C{database.KeysetCondition(sort, args, nullsLast)}, s.compactMode)
+	}
+	op := ">"
+	if desc[0] {
+		op = "<"
+	}
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = "`" + key + "`"
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(keys)), ",")
+	return fmt.Sprintf("(%s) %s (%s) ", strings.Join(columns, ","), op, marks), args
+}
+
+// keysetArg the argument of the sort value, the times are compared as the timestamptz of postgres, or as
+// the strings of DateTime in the location of the database with the fractional seconds, so the rows of the
+// same second are not skipped.
+func (s *SQL) keysetArg(v any) any {
+	t, ok := v.(time.Time)
+	if !ok || s.scheme == schemePostgres {
+		return v
+	}
+	return t.In(s.loc).Format(layoutDateTimeMicro)
+}
+
+// quoteSort quote the keys of the sort
+func quoteSort(sort []string) []string {
+	quoted := make([]string, len(sort))
+	for i, v := range sort {
+		key, desc := strings.CutPrefix(v, "-")
+		quoted[i] = "`" + key + "`"
+		if desc {
+			quoted[i] = "-" + quoted[i]
+		}
+	}
+	return quoted
+}
+
 func encodePageToken(first, last int64) string {
 	var pageTokenBytes []byte
 	// store the version
@@ -0,0 +1,168 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ti/common-go/dependencies/sql/adapters/postgres"
+)
+
+// replicaCheckInterval the interval of the health checks of the replicas
+const replicaCheckInterval = 10 * time.Second
+
+// replicaSet the read replicas of the primary, the reads are routed to the healthy replicas in round-robin.
+type replicaSet struct {
+	dbs     []*sql.DB
+	healthy []atomic.Bool
+	next    atomic.Uint64
+	cancel  context.CancelFunc
+}
+
+// splitHosts split the primary host and the replica hosts of primary,replica1,replica2
+func splitHosts(u *url.URL) (primary string, replicas []string) {
+	hosts := strings.Split(u.Host, ",")
+	return hosts[0], hosts[1:]
+}
+
+// newReplicaSet connect the replicas with the uri of the primary, the unhealthy replicas are not used
+// until they pass the health check.
+func newReplicaSet(ctx context.Context, u *url.URL, hosts []string, logMode string) (*replicaSet, error) {
+	r := &replicaSet{
+		dbs:     make([]*sql.DB, len(hosts)),
+		healthy: make([]atomic.Bool, len(hosts)),
+	}
+	for i, host := range hosts {
+		replicaURI := *u
+		replicaURI.Host = host
+		db, err := newSQLClient(&replicaURI, logMode)
+		if err != nil {
+			_ = r.close()
+			return nil, err
+		}
+		r.dbs[i] = db
+	}
+	r.check(ctx)
+	var checkCtx context.Context
+	checkCtx, r.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	go r.watch(checkCtx)
+	return r, nil
+}
+
+// pick the next healthy replica, it is nil if no replica is healthy.
+func (r *replicaSet) pick() (int, *sql.DB) {
+	n := uint64(len(r.dbs))
+	start := r.next.Add(1)
+	for i := range n {
+		idx := int((start + i) % n)
+		if r.healthy[idx].Load() {
+			return idx, r.dbs[idx]
+		}
+	}
+	return -1, nil
+}
+
+func (r *replicaSet) check(ctx context.Context) {
+	for i, db := range r.dbs {
+		pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+		r.healthy[i].Store(db.PingContext(pingCtx) == nil)
+		cancel()
+	}
+}
+
+func (r *replicaSet) watch(ctx context.Context) {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
+
+func (r *replicaSet) close() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	var errs []error
+	for _, db := range r.dbs {
+		if db != nil {
+			errs = append(errs, db.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isConnError check if the error is caused by the connection, so the replica is unhealthy.
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr)
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context which reads from the primary, for exp, read your own writes right after a write
+// when the uri has readFrom=replica.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func isPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// replica returns the replica to read, it is nil when the read must go to the primary.
+func (s *SQL) replica(ctx context.Context) (int, *sql.DB) {
+	if s.replicas == nil || s.tx != nil || isPrimary(ctx) {
+		return -1, nil
+	}
+	return s.replicas.pick()
+}
+
+// readQueryContext executes the query of Find, Count or PageQuery on a replica when the uri has
+// readFrom=replica, it falls back to the primary if the replica is unavailable.
+func (s *SQL) readQueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	idx, db := s.replica(ctx)
+	if db == nil {
+		return s.QueryContext(ctx, query, args...)
+	}
+	defer s.observe(ctx, time.Now(), query, args)
+	if s.scheme == schemePostgres {
+		query = postgres.ConvertSQL(query)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil && ctx.Err() == nil && isConnError(err) {
+		s.replicas.healthy[idx].Store(false)
+		return s.DB.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// readQueryRowContext executes the query which returns at most one row on a replica when the uri has
+// readFrom=replica, it falls back to the primary if the replica is unavailable.
+func (s *SQL) readQueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	idx, db := s.replica(ctx)
+	if db == nil {
+		return s.QueryRowContext(ctx, query, args...)
+	}
+	defer s.observe(ctx, time.Now(), query, args)
+	if s.scheme == schemePostgres {
+		query = postgres.ConvertSQL(query)
+	}
+	row := db.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && ctx.Err() == nil && isConnError(err) {
+		s.replicas.healthy[idx].Store(false)
+		return s.DB.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
@@ -0,0 +1,144 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// postgresSearchConfig the text search config of postgres, the expression of the GIN index must use the same
+// config as the queries.
+const postgresSearchConfig = "'simple'"
+
+// tidySQLSearch convert the Search condition to MATCH ... AGAINST of mysql, to_tsvector @@ plainto_tsquery of
+// postgres, or the scan of the words of the text on sqlite.
+func tidySQLSearch(scheme string, cond database.CE) (string, []any) {
+	fields := cond.SearchFields()
+	switch scheme {
+	case schemePostgres:
+		return fmt.Sprintf("%s @@ plainto_tsquery(%s, ?)", postgresTSVector(fields), postgresSearchConfig),
+			[]any{cond.SearchText()}
+	case schemeSQLite:
+		matches, args := sqliteWordMatches(fields, database.SearchTokens(cond.SearchText()))
+		if len(matches) == 0 {
+			return "1=0", nil
+		}
+		return "(" + strings.Join(matches, " OR ") + ")", args
+	default:
+		return mysqlMatch(fields), []any{cond.SearchText()}
+	}
+}
+
+// searchScore the relevance of the Search condition, which is larger for the more relevant rows.
+func searchScore(scheme string, cond database.CE) (string, []any) {
+	fields := cond.SearchFields()
+	switch scheme {
+	case schemePostgres:
+		return fmt.Sprintf("ts_rank(%s, plainto_tsquery(%s, ?))", postgresTSVector(fields), postgresSearchConfig),
+			[]any{cond.SearchText()}
+	case schemeSQLite:
+		matches, args := sqliteWordMatches(fields, database.SearchTokens(cond.SearchText()))
+		if len(matches) == 0 {
+			return "0", nil
+		}
+		// the count of the matched words and fields
+		return "(" + strings.Join(matches, " + ") + ")", args
+	default:
+		return mysqlMatch(fields), []any{cond.SearchText()}
+	}
+}
+
+func mysqlMatch(fields []string) string {
+	return "MATCH(" + quoteFields(fields) + ") AGAINST(? IN NATURAL LANGUAGE MODE)"
+}
+
+func postgresTSVector(fields []string) string {
+	values := make([]string, len(fields))
+	for i, v := range fields {
+		values[i] = "coalesce(`" + v + "`,'')"
+	}
+	return fmt.Sprintf("to_tsvector(%s, %s)", postgresSearchConfig, strings.Join(values, " || ' ' || "))
+}
+
+// sqliteWordMatches the matches of each word in each field, sqlite has no full-text index without a virtual
+// table, so the words are matched as the substrings of the lower case fields.
+func sqliteWordMatches(fields, words []string) (matches []string, args []any) {
+	for _, word := range words {
+		for _, field := range fields {
+			matches = append(matches, "(instr(lower(coalesce(`"+field+"`,'')), ?) > 0)")
+			args = append(args, word)
+		}
+	}
+	return matches, args
+}
+
+func quoteFields(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, v := range fields {
+		quoted[i] = "`" + v + "`"
+	}
+	return strings.Join(quoted, ",")
+}
+
+// parseOrder the ORDER BY of the sort, the keys are formatted by the format, and the database.SearchScore key
+// sorts by the relevance of the Search condition of the conds.
+func parseOrder(scheme string, conds database.C, sort []string, format func(string) string,
+) (string, []any, error) {
+	orders := make([]string, len(sort))
+	var args []any
+	for i, v := range sort {
+		key, desc := strings.CutPrefix(v, "-")
+		if key != database.SearchScore {
+			orders[i] = format(v)
+			continue
+		}
+		cond, ok := database.SearchCondition(conds)
+		if !ok {
+			return "", nil, status.Errorf(codes.InvalidArgument, "the sort key %s requires a search condition",
+				database.SearchScore)
+		}
+		score, scoreArgs := searchScore(scheme, cond)
+		if desc {
+			score += " DESC"
+		}
+		orders[i] = score
+		args = append(args, scoreArgs...)
+	}
+	return strings.Join(orders, ", "), args, nil
+}
+
+// IndexOptions the options of EnsureIndex
+type IndexOptions struct {
+	// FullText create the full-text index of the fields for the database.Search condition
+	FullText bool
+}
+
+// IndexOption the option of EnsureIndex
+type IndexOption func(*IndexOptions)
+
+// WithFullText create the full-text index of the fields, it is a FULLTEXT index of mysql and a GIN index of
+// postgres, sqlite scans the rows without an index.
+func WithFullText() IndexOption {
+	return func(o *IndexOptions) {
+		o.FullText = true
+	}
+}
+
+// GenerateFullTextIndexScheme gen the full-text index scheme of the scheme, which is mysql, postgres or sqlite,
+// it is empty for sqlite.
+func GenerateFullTextIndexScheme(scheme, table string, fields []string) string {
+	indexName := "ft_" + strings.Join(fields, "_")
+	switch scheme {
+	case schemePostgres:
+		// the index names of postgres are unique in the schema
+		return fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s_%s` ON `%s` USING GIN ((%s));", table, indexName,
+			table, postgresTSVector(fields))
+	case schemeSQLite:
+		return ""
+	default:
+		return fmt.Sprintf("CREATE FULLTEXT INDEX `%s` ON `%s` (%s);", indexName, table, quoteFields(fields))
+	}
+}
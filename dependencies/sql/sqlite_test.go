@@ -0,0 +1,972 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ti/common-go/dependencies/database"
+	_ "github.com/ti/common-go/dependencies/database/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type sqliteProfile struct {
+	City string  `json:"city"`
+	Nick *string `json:"nick"`
+}
+
+type sqliteGroup struct {
+	ID string `json:"id"`
+}
+
+type sqliteUser struct {
+	Name      string         `json:"name"`
+	Age       int            `json:"age"`
+	Profile   *sqliteProfile `json:"profile"`
+	Groups    []*sqliteGroup `json:"groups"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func newSQLiteTest(t *testing.T) *SQL {
+	t.Helper()
+	ctx := context.Background()
+	db, err := New(ctx, "sqlite:///:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := db.(*SQL)
+	t.Cleanup(func() {
+		_ = s.Close(ctx)
+	})
+	if err = s.EnsureTable(ctx, "users", &sqliteUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.EnsureIndex(ctx, "users", []string{"name"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.EnsureIndex(ctx, "users", []string{"profile.city"}, false, false); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSQLiteCRUD(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	now := time.Now().Truncate(time.Second)
+	users := []*sqliteUser{
+		{Name: "alice", Age: 20, Profile: &sqliteProfile{City: "beijing"},
+			Groups: []*sqliteGroup{{ID: "admin"}}, CreatedAt: now},
+		{Name: "bob", Age: 30, Profile: &sqliteProfile{City: "shanghai"},
+			Groups: []*sqliteGroup{{ID: "dev"}}, CreatedAt: now},
+	}
+	count, err := s.Insert(ctx, "users", users)
+	if err != nil || count != 2 {
+		t.Fatalf("insert count %d error %v", count, err)
+	}
+	err = s.InsertOne(ctx, "users", &sqliteUser{Name: "alice"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expect already exists, got %v", err)
+	}
+	var user sqliteUser
+	err = s.FindOne(ctx, "users", database.C{{Key: "profile.city", Value: "shanghai"}}, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "bob" || !user.CreatedAt.Equal(now) || len(user.Groups) != 1 {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	var found []*sqliteUser
+	err = s.Find(ctx, "users", database.C{{Key: "groups[*].id", Value: "admin"}}, nil, 0, &found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Name != "alice" {
+		t.Fatalf("unexpected users %+v", found)
+	}
+	count, err = s.UpdateOne(ctx, "users", database.C{{Key: "name", Value: "alice"}},
+		database.D{{Key: "age", Value: 21}})
+	if err != nil || count != 1 {
+		t.Fatalf("update count %d error %v", count, err)
+	}
+	total, err := s.Count(ctx, "users", database.C{{Key: "age", Value: 21, C: database.Gte}})
+	if err != nil || total != 2 {
+		t.Fatalf("count %d error %v", total, err)
+	}
+	exist, err := s.Exist(ctx, "users", database.C{{Key: "name", Value: "carol"}})
+	if err != nil || exist {
+		t.Fatalf("exist %v error %v", exist, err)
+	}
+	count, err = s.Replace(ctx, "users", []string{"name"}, &sqliteUser{Name: "bob", Age: 31, CreatedAt: now})
+	if err != nil || count != 1 {
+		t.Fatalf("replace count %d error %v", count, err)
+	}
+	if err = s.FindOne(ctx, "users", database.C{{Key: "name", Value: "bob"}}, &user); err != nil || user.Age != 31 {
+		t.Fatalf("unexpected replaced user %+v error %v", user, err)
+	}
+	count, err = s.Delete(ctx, "users", database.C{{Key: "name", Value: "bob"}})
+	if err != nil || count != 1 {
+		t.Fatalf("delete count %d error %v", count, err)
+	}
+	err = s.FindOne(ctx, "users", database.C{{Key: "name", Value: "bob"}}, &user)
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expect not found, got %v", err)
+	}
+}
+
+func TestSQLiteInsertIDs(t *testing.T) {
+	type article struct {
+		ID    int64  `json:"_id"`
+		Title string `json:"title"`
+	}
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureTable(ctx, "articles", &article{}); err != nil {
+		t.Fatal(err)
+	}
+	first := &article{Title: "a"}
+	if err := s.InsertOne(ctx, "articles", first); err != nil {
+		t.Fatal(err)
+	}
+	docs := []*article{{Title: "b"}, {Title: "c"}}
+	if _, err := s.Insert(ctx, "articles", docs); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != 1 || docs[0].ID != 2 || docs[1].ID != 3 {
+		t.Fatalf("unexpected ids %d %d %d", first.ID, docs[0].ID, docs[1].ID)
+	}
+	var found article
+	if err := s.FindOne(ctx, "articles", database.C{{Key: "_id", Value: 3}}, &found); err != nil ||
+		found.Title != "c" || found.ID != 3 {
+		t.Fatalf("unexpected article %+v error %v", found, err)
+	}
+	// the zero id of the replaced doc is not written
+	if _, err := s.Replace(ctx, "articles", []string{"title"}, &article{Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FindOne(ctx, "articles", database.C{{Key: "title", Value: "a"}}, &found); err != nil || found.ID != 1 {
+		t.Fatalf("unexpected replaced article %+v error %v", found, err)
+	}
+
+	// the db:"id" field of its own column is not filled by the _id
+	type tagged struct {
+		ID    int64  `json:"id" db:"id"`
+		Title string `json:"title"`
+	}
+	if err := s.EnsureTable(ctx, "tagged", &tagged{}); err != nil {
+		t.Fatal(err)
+	}
+	doc := &tagged{Title: "a"}
+	if err := s.InsertOne(ctx, "tagged", doc); err != nil || doc.ID != 0 {
+		t.Fatalf("unexpected tagged doc %+v error %v", doc, err)
+	}
+}
+
+func TestSQLiteUpsertProject(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureIndex(ctx, "users", []string{"project", "age"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	upsert := func(project, name string) {
+		db, err := s.GetDatabase(ctx, project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := database.D{{Key: "name", Value: name}, {Key: "age", Value: 30}}
+		if _, err = db.(*SQL).upsert(ctx, "users", []string{"age"}, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	upsert("p1", "alice")
+	// the row of the same index keys in another project is not updated
+	upsert("p2", "bob")
+	upsert("p1", "carol")
+	for project, want := range map[string]string{"p1": "carol", "p2": "bob"} {
+		db, _ := s.GetDatabase(ctx, project)
+		var users []*sqliteUser
+		if err := db.Find(ctx, "users", nil, nil, 0, &users); err != nil || len(users) != 1 || users[0].Name != want {
+			t.Fatalf("unexpected users of %s %v %v", project, users, err)
+		}
+	}
+}
+
+func TestSQLiteEnsureModel(t *testing.T) {
+	type indexedUser struct {
+		Name      string    `json:"name" db:"unique"`
+		Age       int       `json:"age" db:"index,name=age_created,desc"`
+		CreatedAt time.Time `json:"created_at" db:"index,name=age_created"`
+	}
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	report, err := database.EnsureModel(ctx, s, "users", &indexedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the name index is created by newSQLiteTest
+	if !slices.Equal(report.Created, []string{"age_created"}) || !slices.Equal(report.Extra, []string{"profile.city"}) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report, err = database.EnsureModel(ctx, s, "users", &indexedUser{}); err != nil || len(report.Created) != 0 {
+		t.Fatalf("unexpected report %+v error %v", report, err)
+	}
+	type expiredUser struct {
+		CreatedAt time.Time `json:"created_at" db:"ttl=720h"`
+	}
+	if _, err = database.EnsureModel(ctx, s, "users", &expiredUser{}); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected the ttl index is unimplemented, got %v", err)
+	}
+	// the ttl index of mysql is the index and the event, each is one statement
+	if scheme := generateTTLIndexScheme("users", "created_at", "created_at"); scheme !=
+		"CREATE INDEX `created_at` ON `users` (`created_at`) COMMENT 'ttl';" {
+		t.Fatalf("unexpected ttl index %s", scheme)
+	}
+	if scheme := generateAutoExpEvent("app", "users", ttlEventName("users", "created_at"), "created_at",
+		720*time.Hour); scheme != "CREATE EVENT IF NOT EXISTS `exp_users_created_at` ON SCHEDULE EVERY 1 DAY "+
+		"DO DELETE FROM `app`.`users` WHERE `created_at` < NOW() - INTERVAL 2592000 SECOND" {
+		t.Fatalf("unexpected ttl event %s", scheme)
+	}
+}
+
+func TestSQLiteEncryptedFields(t *testing.T) {
+	type customer struct {
+		Name    string  `json:"name"`
+		Phone   string  `json:"phone" db:"encrypt=deterministic"`
+		Address *string `json:"address" db:"encrypt"`
+	}
+	database.SetKeyProvider(&database.StaticKeys{Current: "k1", Keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef"),
+	}})
+	t.Cleanup(func() {
+		database.SetKeyProvider(nil)
+	})
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureTable(ctx, "customers", &customer{}); err != nil {
+		t.Fatal(err)
+	}
+	address := "123 Main St"
+	alice := &customer{Name: "alice", Phone: "+1234567890", Address: &address}
+	if err := s.InsertOne(ctx, "customers", alice); err != nil {
+		t.Fatal(err)
+	}
+	var phone, storedAddress string
+	err := s.QueryRowContext(ctx, "SELECT `phone`, `address` FROM `customers`").Scan(&phone, &storedAddress)
+	if err != nil || !strings.HasPrefix(phone, "enc:k1:") || !strings.HasPrefix(storedAddress, "enc:k1:") {
+		t.Fatalf("expected the encrypted values, got %s %s error %v", phone, storedAddress, err)
+	}
+	var found customer
+	if err = s.FindOne(ctx, "customers", database.C{{Key: "phone", Value: "+1234567890"}}, &found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "alice" || found.Phone != "+1234567890" || found.Address == nil || *found.Address != address {
+		t.Fatalf("unexpected customer %+v", found)
+	}
+	var list []*customer
+	err = s.Find(ctx, "customers", database.C{{Key: "phone", Value: []string{"+1234567890"}, C: database.In}},
+		nil, 0, &list)
+	if err != nil || len(list) != 1 || list[0].Phone != "+1234567890" {
+		t.Fatalf("unexpected customers %v error %v", list, err)
+	}
+	err = s.FindOne(ctx, "customers", database.C{{Key: "address", Value: address}}, &found)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected the random encrypted field can not be queried, got %v", err)
+	}
+
+	// the partial updates are encrypted by the model
+	customers := database.NewRepository[customer](s, "customers")
+	if _, err = customers.UpdateFields(ctx, database.C{{Key: "name", Value: "alice"}},
+		database.D{{Key: "phone", Value: "+1987654321"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.QueryRowContext(ctx, "SELECT `phone` FROM `customers`").Scan(&phone); err != nil ||
+		!strings.HasPrefix(phone, "enc:k1:") {
+		t.Fatalf("expected the encrypted phone, got %s error %v", phone, err)
+	}
+
+	// the writes fail if the fields can not be encrypted, so the plain values are never written
+	database.SetKeyProvider(nil)
+	bob := &customer{Name: "bob", Phone: "+1234567891"}
+	if err = s.InsertOne(ctx, "customers", bob); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected insert failed precondition, got %v", err)
+	}
+	if _, err = s.Insert(ctx, "customers", []*customer{bob, bob}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected insert failed precondition, got %v", err)
+	}
+	_, err = s.Update(ctx, "customers", database.C{{Key: "name", Value: "alice"}}, bob)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected update failed precondition, got %v", err)
+	}
+	if _, err = s.Replace(ctx, "customers", []string{"name"}, bob); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected replace failed precondition, got %v", err)
+	}
+	_, err = customers.UpdateFields(ctx, database.C{{Key: "name", Value: "alice"}},
+		database.D{{Key: "phone", Value: "+1234567891"}})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected update fields failed precondition, got %v", err)
+	}
+	if n, _ := s.Count(ctx, "customers", nil); n != 1 {
+		t.Fatalf("expected 1 customer, got %d", n)
+	}
+}
+
+func TestSQLiteQuery(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if err := s.InsertOne(ctx, "users", &sqliteUser{Name: name, Age: 18}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := PageQuery[sqliteUser](ctx, s, "users", &database.PageQueryRequest{
+		Filters: database.C{{Key: "age", Value: 18}},
+		Sort:    []string{"-name"},
+		Page:    2,
+		Limit:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 5 || len(page.Data) != 2 || page.Data[0].Name != "c" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	stream, err := StreamQuery[sqliteUser](ctx, s, "users", &database.StreamQueryRequest{
+		Limit: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.Data) != 3 || stream.PageToken == "" {
+		t.Fatalf("unexpected stream %+v", stream)
+	}
+	next, err := StreamQuery[sqliteUser](ctx, s, "users", &database.StreamQueryRequest{
+		PageToken: stream.PageToken,
+		Limit:     3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Data) != 2 || next.Data[0].Name != "b" {
+		t.Fatalf("unexpected next stream %+v", next)
+	}
+}
+
+func TestSQLiteTransactionAndCounter(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	_, err := s.ExecContext(ctx, "CREATE TABLE counter (`_id` INTEGER PRIMARY KEY AUTOINCREMENT, "+
+		"`project` TEXT NOT NULL DEFAULT '', `key` TEXT, `count` INTEGER, UNIQUE (`project`, `key`));")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err = s.IncrCounter(ctx, "counter", "visits", 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.DecrCounter(ctx, "counter", "visits", 1); err != nil {
+		t.Fatal(err)
+	}
+	count, err := s.GetCounter(ctx, "counter", "visits")
+	if err != nil || count != 2 {
+		t.Fatalf("counter %d error %v", count, err)
+	}
+	tx, err := s.StartTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txDB := s.WithTransaction(ctx, tx)
+	if err = txDB.InsertOne(ctx, "users", &sqliteUser{Name: "tx", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if exist, _ := txDB.Exist(ctx, "users", database.C{{Key: "name", Value: "tx"}}); !exist {
+		t.Fatal("the insert must be visible in transaction")
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if exist, _ := s.Exist(ctx, "users", database.C{{Key: "name", Value: "tx"}}); exist {
+		t.Fatal("the insert must be rolled back")
+	}
+	if tx.(*Tx).Tx() == nil {
+		t.Fatal("the *sql.Tx of the transaction is nil")
+	}
+	// the *sql.Tx of database/sql is accepted too
+	sqlTx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.WithTransaction(ctx, sqlTx).InsertOne(ctx, "users", &sqliteUser{Name: "sqltx"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = sqlTx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if exist, _ := s.Exist(ctx, "users", database.C{{Key: "name", Value: "sqltx"}}); !exist {
+		t.Fatal("the insert of the *sql.Tx must be committed")
+	}
+	// the transaction of another database fails the calls instead of a panic
+	_, err = s.WithTransaction(ctx, foreignTx{}).Count(ctx, "users", nil)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect InvalidArgument of the foreign transaction, got %v", err)
+	}
+}
+
+func TestSQLiteMemoryConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s := newSQLiteTest(t)
+	if err := s.EnsureTable(ctx, "groups", &sqliteGroup{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertOne(ctx, "groups", &sqliteGroup{ID: "g"}); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := s.StartTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err = s.WithTransaction(ctx, tx).InsertOne(ctx, "users", &sqliteUser{Name: "tx"}); err != nil {
+		t.Fatal(err)
+	}
+	// the queries out of the transaction use another connection of the same database
+	if count, err := s.Count(ctx, "groups", nil); err != nil || count != 1 {
+		t.Fatalf("count %d error %v", count, err)
+	}
+	// the table written by the transaction is locked instead of waiting for the transaction
+	if _, err = s.Count(ctx, "users", nil); status.Code(err) != codes.Aborted {
+		t.Fatalf("expect Aborted of the locked table, got %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := s.Count(ctx, "users", nil); err != nil || count != 1 {
+		t.Fatalf("count %d error %v", count, err)
+	}
+	// every client of :memory: is a new database
+	other := newSQLiteTest(t)
+	if count, err := other.Count(ctx, "users", nil); err != nil || count != 0 {
+		t.Fatalf("expect an empty database, got count %d error %v", count, err)
+	}
+}
+
+// foreignTx a transaction which is not started by the sql
+type foreignTx struct{}
+
+func (foreignTx) Commit() error { return nil }
+
+func (foreignTx) Rollback() error { return nil }
+
+func TestSQLiteRunInTransaction(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	users := database.NewRepository[sqliteUser](s, "users")
+	errFailed := status.Error(codes.FailedPrecondition, "failed")
+	err := database.RunInTransaction(ctx, s, func(ctx context.Context, tx database.Database) error {
+		if _, err := users.Insert(ctx, &sqliteUser{Name: "outer"}); err != nil {
+			return err
+		}
+		// the failed nested transaction is rolled back to its savepoint
+		err := database.RunInTransaction(ctx, s, func(ctx context.Context, tx database.Database) error {
+			if _, err := users.Insert(ctx, &sqliteUser{Name: "failed"}); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		if err != errFailed {
+			return fmt.Errorf("unexpected nested error %w", err)
+		}
+		return database.RunInTransaction(ctx, s, func(ctx context.Context, tx database.Database) error {
+			_, err := users.Insert(ctx, &sqliteUser{Name: "nested"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for user, err := range users.Iterate(ctx, nil, []string{"name"}, 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, user.Name)
+	}
+	if !slices.Equal(names, []string{"nested", "outer"}) {
+		t.Fatalf("unexpected users %v", names)
+	}
+	// the transaction is rolled back on error, the savepoints are used in a transaction only
+	err = database.RunInTransaction(ctx, s, func(ctx context.Context, tx database.Database) error {
+		if _, err := users.Insert(ctx, &sqliteUser{Name: "rollback"}); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if err != errFailed {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if exist, _ := users.Exist(ctx, database.C{{Key: "name", Value: "rollback"}}); exist {
+		t.Fatal("the insert must be rolled back")
+	}
+	if err = s.Savepoint(ctx, "sp"); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("unexpected savepoint error %v", err)
+	}
+}
+
+func TestSQLiteConditionOperators(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	users := []*sqliteUser{
+		{Name: "alice", Age: 25, Profile: &sqliteProfile{City: "beijing"}, Groups: []*sqliteGroup{{ID: "admin"}}},
+		{Name: "bob", Age: 30, Profile: &sqliteProfile{City: "shanghai"}, Groups: []*sqliteGroup{{ID: "dev"}}},
+		{Name: "albert", Age: 35},
+	}
+	for _, user := range users {
+		if err := s.InsertOne(ctx, "users", user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name  string
+		conds database.C
+		want  int64
+	}{
+		{"like", database.C{{Key: "name", Value: "al%", C: database.Like}}, 2},
+		{"regex", database.C{{Key: "name", Value: "^(bob|albert)$", C: database.Regex}}, 2},
+		{"exists", database.C{{Key: "profile.city", Value: true, C: database.Exists}}, 2},
+		{"not exists", database.C{{Key: "profile.city", Value: false, C: database.Exists}}, 1},
+		{"between", database.C{{Key: "age", Value: []int{25, 30}, C: database.Between}}, 2},
+		{"in", database.C{{Key: "name", Value: []string{"bob", "carol"}, C: database.In}}, 1},
+		{"array contains", database.C{{Key: "groups[*].id", Value: "dev", C: database.ArrayContains}}, 1},
+		{"json match", database.C{{Key: "profile", Value: map[string]any{"city": "beijing"},
+			C: database.JSONMatch}}, 1},
+		{"or", database.C{{C: database.Or, Value: database.C{
+			{Key: "name", Value: "bob"},
+			{C: database.And, Value: database.C{
+				{Key: "name", Value: "al%", C: database.Like},
+				{Key: "age", Value: 30, C: database.Gt},
+			}},
+		}}}, 2},
+		{"not", database.C{{C: database.Not, Value: database.C{{Key: "name", Value: "al%", C: database.Like}}}}, 1},
+		{"empty or", database.C{{C: database.Or, Value: database.C{}}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := s.Count(ctx, "users", tt.conds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.want {
+				t.Fatalf("expect %d users, got %d", tt.want, count)
+			}
+		})
+	}
+}
+
+// TestConditionSemantics checks the conditions match the same rows on sqlite and the mock
+func TestConditionSemantics(t *testing.T) {
+	ctx := context.Background()
+	mockDB, err := database.New(ctx, "mock://local/semantics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = mockDB.Close(ctx)
+	})
+	nick := "ali"
+	users := []*sqliteUser{
+		{Name: "Alice", Age: 25, Profile: &sqliteProfile{City: "beijing", Nick: &nick},
+			Groups: []*sqliteGroup{{ID: "admin"}}},
+		// the nick of bob is the json null
+		{Name: "bob", Age: 30, Profile: &sqliteProfile{City: "shanghai"}, Groups: []*sqliteGroup{{ID: "dev"}}},
+		{Name: "a_b", Age: 35},
+		{Name: "axb", Age: 40},
+	}
+	backends := map[string]database.Database{"sqlite": newSQLiteTest(t), "mock": mockDB}
+	for _, db := range backends {
+		for _, user := range users {
+			if err = db.InsertOne(ctx, "users", user); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tests := []struct {
+		name  string
+		conds database.C
+		want  int64
+	}{
+		{"like is case-insensitive", database.C{{Key: "name", Value: "AL%", C: database.Like}}, 1},
+		{"like escape", database.C{{Key: "name", Value: `a\_b`, C: database.Like}}, 1},
+		{"like wildcard", database.C{{Key: "name", Value: "a_b", C: database.Like}}, 2},
+		{"regex is case-sensitive", database.C{{Key: "name", Value: "^a", C: database.Regex}}, 2},
+		{"json regex is case-sensitive", database.C{{Key: "profile.city", Value: "^B", C: database.Regex}}, 0},
+		{"exists ignores json null", database.C{{Key: "profile.nick", Value: true, C: database.Exists}}, 1},
+		{"not exists matches json null", database.C{{Key: "profile.nick", Value: false, C: database.Exists}}, 3},
+		{"not matches null", database.C{{C: database.Not, Value: database.C{
+			{Key: "profile.city", Value: "beijing"},
+		}}}, 3},
+		{"array eq", database.C{{Key: "groups[*].id", Value: "dev"}}, 1},
+		{"array ne", database.C{{Key: "groups[*].id", Value: "dev", C: database.Ne}}, 3},
+		{"array in", database.C{{Key: "groups[*].id", Value: []string{"dev", "ops"}, C: database.In}}, 1},
+		{"array nin", database.C{{Key: "groups[*].id", Value: []string{"dev", "admin"}, C: database.Nin}}, 2},
+	}
+	for _, tt := range tests {
+		for name, db := range backends {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				count, err := db.Count(ctx, "users", tt.conds)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count != tt.want {
+					t.Fatalf("expect %d users, got %d", tt.want, count)
+				}
+			})
+		}
+	}
+}
+
+func TestSQLiteAggregate(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	users := []*sqliteUser{
+		{Name: "alice", Age: 20, Profile: &sqliteProfile{City: "beijing"}},
+		{Name: "bob", Age: 30, Profile: &sqliteProfile{City: "beijing"}},
+		{Name: "carol", Age: 40, Profile: &sqliteProfile{City: "shanghai"}},
+		{Name: "dave", Age: 50},
+	}
+	for _, user := range users {
+		if err := s.InsertOne(ctx, "users", user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := s.Aggregate(ctx, "users", &database.AggregateRequest{
+		Filters: database.C{{Key: "age", Value: 20, C: database.Gt}},
+		GroupBy: []string{"profile"},
+		Aggregations: []database.Aggregation{
+			{Func: database.Count},
+			{Func: database.Sum, Key: "age", As: "total_age"},
+			{Func: database.Min, Key: "name"},
+		},
+		Having: database.C{{Key: "count", Value: 1}},
+		Sort:   []string{"-total_age"},
+		Limit:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Data) != 2 || result.Data[0]["total_age"] != float64(50) || result.Data[0]["profile"] != nil ||
+		result.Data[1]["min_name"] != "carol" || result.Data[1]["count"] != int64(1) {
+		t.Fatalf("unexpected aggregate %v", result.Data)
+	}
+	result, err = s.Aggregate(ctx, "users", &database.AggregateRequest{
+		Aggregations: []database.Aggregation{{Func: database.Avg, Key: "age"}, {Func: database.Count, Key: "profile"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Data) != 1 || result.Data[0]["avg_age"] != float64(35) || result.Data[0]["count_profile"] != int64(3) {
+		t.Fatalf("unexpected aggregate %v", result.Data)
+	}
+	_, err = s.Aggregate(ctx, "users", &database.AggregateRequest{GroupBy: []string{"name` FROM users; --"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect invalid argument, got %v", err)
+	}
+}
+
+type sqliteScopedUser struct {
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at"`
+	DeletedAt *timestamppb.Timestamp `json:"deleted_at"`
+}
+
+func TestSQLiteScoped(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureTable(ctx, "scoped_users", &sqliteScopedUser{}); err != nil {
+		t.Fatal(err)
+	}
+	db := database.NewScoped(s, database.ScopeOptions{SoftDelete: "deleted_at", CreatedAt: "created_at"})
+	user := &sqliteScopedUser{Name: "alice"}
+	if err := db.InsertOne(ctx, "scoped_users", user); err != nil {
+		t.Fatal(err)
+	}
+	if user.CreatedAt.IsZero() {
+		t.Fatal("the created_at must be stamped")
+	}
+	if err := db.InsertOne(ctx, "scoped_users", &sqliteScopedUser{Name: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	count, err := db.DeleteOne(ctx, "scoped_users", database.C{{Key: "name", Value: "alice"}})
+	if err != nil || count != 1 {
+		t.Fatalf("delete count %d error %v", count, err)
+	}
+	if total, err := db.Count(ctx, "scoped_users", nil); err != nil || total != 1 {
+		t.Fatalf("count %d error %v", total, err)
+	}
+	var found sqliteScopedUser
+	err = database.Unscoped(db).FindOne(ctx, "scoped_users", database.C{{Key: "name", Value: "alice"}}, &found)
+	if err != nil || found.DeletedAt == nil {
+		t.Fatalf("unscoped find %+v error %v", found, err)
+	}
+}
+
+type sqliteVersionedUser struct {
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Version int64  `json:"version" db:"version"`
+}
+
+func TestSQLiteOptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureTable(ctx, "versioned_users", &sqliteVersionedUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertOne(ctx, "versioned_users", &sqliteVersionedUser{Name: "alice", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	byName := database.C{{Key: "name", Value: "alice"}}
+	var first, second sqliteVersionedUser
+	_ = s.FindOne(ctx, "versioned_users", byName, &first)
+	_ = s.FindOne(ctx, "versioned_users", byName, &second)
+	first.Age = 20
+	if _, err := s.UpdateOne(ctx, "versioned_users", byName, &first); err != nil || first.Version != 2 {
+		t.Fatalf("update %+v error %v", first, err)
+	}
+	second.Age = 30
+	if _, err := s.ReplaceOne(ctx, "versioned_users", byName, &second); !errors.Is(err, database.ErrVersionConflict) {
+		t.Fatalf("expect version conflict, got %v", err)
+	}
+	if second.Version != 1 {
+		t.Fatalf("expect the version is reset, got %d", second.Version)
+	}
+	_, err := s.UpdateOne(ctx, "versioned_users", database.C{{Key: "name", Value: "bob"}}, &second)
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expect not found, got %v", err)
+	}
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	ctx := context.Background()
+	users := database.NewRepository[sqliteUser](newSQLiteTest(t), "users")
+	count, err := users.Insert(ctx, &sqliteUser{Name: "a", Age: 1}, &sqliteUser{Name: "b", Age: 2})
+	if err != nil || count != 2 {
+		t.Fatalf("insert count %d error %v", count, err)
+	}
+	user, err := users.FindOne(ctx, database.C{{Key: "name", Value: "b"}})
+	if err != nil || user.Age != 2 {
+		t.Fatalf("find one %+v error %v", user, err)
+	}
+	page, err := users.PageQuery(ctx, &database.PageQueryRequest{Sort: []string{"name"}})
+	if err != nil || page.Total != 2 || page.Data[0].Name != "a" {
+		t.Fatalf("page %+v error %v", page, err)
+	}
+	stream, err := users.StreamQuery(ctx, &database.StreamQueryRequest{Limit: 1})
+	if err != nil || len(stream.Data) != 1 || stream.PageToken == "" {
+		t.Fatalf("stream %+v error %v", stream, err)
+	}
+	var names []string
+	for user, err := range users.Iterate(ctx, nil, []string{"-name"}, 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, user.Name)
+	}
+	if len(names) != 2 || names[0] != "b" {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestSQLiteBulkWrite(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.InsertOne(ctx, "users", &sqliteUser{Name: "alice", Age: 10}); err != nil {
+		t.Fatal(err)
+	}
+	byName := func(name string) database.C {
+		return database.C{{Key: "name", Value: name}}
+	}
+	models := []database.WriteModel{
+		database.InsertModel(&sqliteUser{Name: "bob"}),
+		database.InsertModel(&sqliteUser{Name: "alice"}),
+		database.InsertModel(&sqliteUser{Name: "carol"}),
+		database.UpdateModel(byName("alice"), database.D{{Key: "age", Value: 11}}),
+		database.DeleteModel(byName("bob")),
+	}
+
+	result, err := s.BulkWrite(ctx, "users", models)
+	var bulkErr *database.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 1 || bulkErr.Elements[0].Index != 1 {
+		t.Fatalf("expect the duplicated alice fails, got %v", err)
+	}
+	if status.Code(result.Results[1].Err) != codes.AlreadyExists || !result.Results[2].Skipped ||
+		!result.Results[4].Skipped || result.Inserted != 1 {
+		t.Fatalf("unexpected ordered result %+v", result)
+	}
+	if exist, _ := s.Exist(ctx, "users", byName("bob")); !exist {
+		t.Fatal("expect the writes before the failed one are committed")
+	}
+
+	result, err = s.BulkWrite(ctx, "users", models[2:], database.WithOrdered(false))
+	if err != nil || result.Inserted != 1 || result.Updated != 1 || result.Deleted != 1 {
+		t.Fatalf("unexpected unordered result %+v %v", result, err)
+	}
+	result, err = s.BulkWrite(ctx, "users", models[:3], database.WithOrdered(false))
+	if !errors.As(err, &bulkErr) || len(bulkErr.Elements) != 2 || result.Inserted != 1 {
+		t.Fatalf("expect alice and carol fail, got %+v %v", result, err)
+	}
+	if count, _ := s.Count(ctx, "users", nil); count != 3 {
+		t.Fatalf("expect 3 users, got %d", count)
+	}
+}
+
+func TestSQLiteSortedStreamQuery(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		user := &sqliteUser{Name: name, Age: 20 + i%2, CreatedAt: created.Add(time.Duration(i/2) * time.Hour)}
+		if err := s.InsertOne(ctx, "users", user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := func(sort ...string) []string {
+		var names []string
+		in := &database.StreamQueryRequest{Sort: sort, Limit: 2, NoCount: true}
+		for {
+			out, err := StreamQuery[sqliteUser](ctx, s, "users", in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, user := range out.Data {
+				names = append(names, user.Name)
+			}
+			if out.PageToken == "" {
+				return names
+			}
+			in.PageToken = out.PageToken
+		}
+	}
+	// the ties of age are broken by _id
+	if names := strings.Join(stream("age"), ""); names != "acebd" {
+		t.Fatalf("unexpected age ASC stream %s", names)
+	}
+	if names := strings.Join(stream("-age", "name"), ""); names != "bdace" {
+		t.Fatalf("unexpected age DESC, name ASC stream %s", names)
+	}
+	if names := strings.Join(stream("-created_at"), ""); names != "edcba" {
+		t.Fatalf("unexpected created_at DESC stream %s", names)
+	}
+	_, err := StreamQuery[sqliteUser](ctx, s, "users", &database.StreamQueryRequest{
+		Sort:      []string{"name"},
+		PageToken: "invalid",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect invalid argument, got %v", err)
+	}
+	// the times keep the fractional seconds
+	if arg := s.keysetArg(created.Add(500 * time.Millisecond)); arg != "2024-01-01 00:00:00.5" {
+		t.Fatalf("unexpected time argument %v", arg)
+	}
+}
+
+type sqliteScore struct {
+	Name  string `json:"name"`
+	Score *int   `json:"score"`
+}
+
+func TestSQLiteSortedStreamQueryNulls(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureTable(ctx, "scores", &sqliteScore{}); err != nil {
+		t.Fatal(err)
+	}
+	one, two := 1, 2
+	for _, score := range []*sqliteScore{{"a", &one}, {"b", nil}, {"c", &two}, {"d", nil}, {"e", &one}} {
+		if err := s.InsertOne(ctx, "scores", score); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := func(sort ...string) string {
+		var names string
+		in := &database.StreamQueryRequest{Sort: sort, Limit: 2, NoCount: true}
+		for {
+			out, err := StreamQuery[sqliteScore](ctx, s, "scores", in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, score := range out.Data {
+				names += score.Name
+			}
+			if out.PageToken == "" {
+				return names
+			}
+			in.PageToken = out.PageToken
+		}
+	}
+	// the nulls are the smallest values of sqlite
+	if names := stream("score"); names != "bdaec" {
+		t.Fatalf("unexpected score ASC stream %s", names)
+	}
+	if names := stream("-score"); names != "ceadb" {
+		t.Fatalf("unexpected score DESC stream %s", names)
+	}
+	// the _id of the sort is not appended again
+	if names := stream("-_id"); names != "edcba" {
+		t.Fatalf("unexpected _id DESC stream %s", names)
+	}
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTest(t)
+	if err := s.EnsureIndex(ctx, "users", []string{"name"}, false, false, WithFullText()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Alice Smith", "Bob", "Alice Jones"} {
+		if err := s.InsertOne(ctx, "users", &sqliteUser{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := database.C{{Key: "name", Value: "alice jones", C: database.Search}}
+	var users []*sqliteUser
+	if err := s.Find(ctx, "users", search, []string{"-" + database.SearchScore}, 0, &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Name != "Alice Jones" || users[1].Name != "Alice Smith" {
+		t.Fatalf("unexpected search result %v", users)
+	}
+	page, err := PageQuery[sqliteUser](ctx, s, "users", &database.PageQueryRequest{
+		Filters: search,
+		Sort:    []string{database.SearchScore},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Data[0].Name != "Alice Smith" {
+		t.Fatalf("unexpected search page %d %v", page.Total, page.Data)
+	}
+	err = s.Find(ctx, "users", nil, []string{"-" + database.SearchScore}, 0, &users)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect InvalidArgument of the score without a search, got %v", err)
+	}
+	_, err = StreamQuery[sqliteUser](ctx, s, "users", &database.StreamQueryRequest{
+		Filters: search,
+		Sort:    []string{"-" + database.SearchScore},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect InvalidArgument of the stream sorted by the score, got %v", err)
+	}
+	if scheme := GenerateFullTextIndexScheme(schemeMysql, "users", []string{"name", "bio"}); scheme !=
+		"CREATE FULLTEXT INDEX `ft_name_bio` ON `users` (`name`,`bio`);" {
+		t.Fatalf("unexpected mysql full-text index %s", scheme)
+	}
+}
@@ -106,11 +106,16 @@ func ParseOffset(page, limit int) int {
 
 // ParseWhere prase where from query
 func ParseWhere(filterData database.C, project string) (query string, args []any) {
+	return parseWhere(schemeMysql, filterData, project)
+}
+
+// parseWhere prase where from query in the dialect of the scheme
+func parseWhere(scheme string, filterData database.C, project string) (query string, args []any) {
 	if project != "" {
 		args = append(args, project)
 		query = "`project`=? AND "
 	}
-	condsQuery, condsArgs := tidySQLConds(filterData, false)
+	condsQuery, condsArgs := tidySQLConds(scheme, filterData, false)
 	query += condsQuery
 	args = append(args, condsArgs...)
 	return
@@ -0,0 +1,292 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ti/common-go/dependencies/database"
+	"github.com/ti/common-go/dependencies/sql/adapters/postgres"
+	"github.com/ti/common-go/dependencies/sql/adapters/sqlite"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tenantSet the clients of the tenants of tenancy=schema or tenancy=database, a client is opened on the
+// first use of the tenant and shared by the handles of the tenant.
+type tenantSet struct {
+	root  *SQL
+	mu    sync.Mutex
+	dbs   map[string]*SQL
+	group singleflight.Group
+}
+
+func newTenantSet(root *SQL) *tenantSet {
+	return &tenantSet{
+		root: root,
+		dbs:  make(map[string]*SQL),
+	}
+}
+
+// get the client of the project, the schema or database of the project is created if it does not exist.
+// The tenants are opened without the lock, the callers of the same project share its opening.
+func (t *tenantSet) get(ctx context.Context, project string) (*SQL, error) {
+	if db, ok := t.load(project); ok {
+		return db, nil
+	}
+	v, err, _ := t.group.Do(project, func() (any, error) {
+		if db, ok := t.load(project); ok {
+			return db, nil
+		}
+		db, err := t.root.openTenant(ctx, project)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.dbs[project] = db
+		return db, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*SQL), nil
+}
+
+func (t *tenantSet) load(project string) (*SQL, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	db, ok := t.dbs[project]
+	return db, ok
+}
+
+// remove removes the closed client of a tenant, so the tenant is opened again on its next use.
+func (t *tenantSet) remove(db *sql.DB) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for project, v := range t.dbs {
+		if v.DB == db {
+			delete(t.dbs, project)
+		}
+	}
+}
+
+func (t *tenantSet) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for project, db := range t.dbs {
+		errs = append(errs, db.DB.Close())
+		delete(t.dbs, project)
+	}
+	return errors.Join(errs...)
+}
+
+// checkTenancy check if the tenancy is supported by the scheme
+func checkTenancy(scheme string, tenancy database.Tenancy) error {
+	if tenancy == database.TenancySchema && scheme != schemePostgres {
+		return status.Errorf(codes.InvalidArgument,
+			"tenancy=schema is supported by postgres only, use tenancy=database for %s", scheme)
+	}
+	return nil
+}
+
+// tenantBase the base name of the tenant names, it is the name of the database, or the file name without
+// the extension for sqlite.
+func (s *SQL) tenantBase() string {
+	if s.scheme == schemeSQLite {
+		name := path.Base(s.uri.Path)
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return s.dbName
+}
+
+// openTenant create the schema or database of the project and open its client.
+func (s *SQL) openTenant(ctx context.Context, project string) (*SQL, error) {
+	name, err := database.TenantName(s.tenantBase(), project)
+	if err != nil {
+		return nil, err
+	}
+	u, dbName := s.tenantURL(name)
+	if err = s.createTenant(ctx, name); err != nil {
+		return nil, err
+	}
+	db, err := newSQLClient(u, s.logMode)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, convertError(s.scheme, err)
+	}
+	return &SQL{
+		DB:              db,
+		uri:             u,
+		loc:             s.loc,
+		compactMode:     s.compactMode,
+		updateDifferent: s.updateDifferent,
+		logMode:         s.logMode,
+		bustedIndex:     s.bustedIndex,
+		dbName:          dbName,
+		scheme:          s.scheme,
+		tenancy:         s.tenancy,
+		tenants:         s.tenants,
+		observer:        s.observer,
+		tracer:          s.tracer,
+	}, nil
+}
+
+// tenantURL the uri and the database name of the client of the tenant.
+func (s *SQL) tenantURL(name string) (*url.URL, string) {
+	u := *s.uri
+	dbName := s.dbName
+	switch {
+	case s.tenancy == database.TenancySchema:
+		// the schema is created with the quoted name, so the search path keeps the case of the name too
+		query := u.Query()
+		query.Set("search_path", `"`+name+`"`)
+		u.RawQuery = query.Encode()
+	case s.scheme == schemeSQLite:
+		// every client of an in memory database is a new database
+		if !sqlite.IsMemory(s.uri) {
+			u.Path = path.Join(path.Dir(s.uri.Path), name+path.Ext(s.uri.Path))
+			dbName = u.Path[1:]
+		}
+	default:
+		u.Path = "/" + name
+		dbName = name
+	}
+	return &u, dbName
+}
+
+// createTenant create the schema or database of the tenant if it does not exist.
+func (s *SQL) createTenant(ctx context.Context, name string) error {
+	var query string
+	switch {
+	case s.scheme == schemeSQLite:
+		// the sqlite database file is created when it is opened
+		return nil
+	case s.tenancy == database.TenancySchema:
+		query = "CREATE SCHEMA IF NOT EXISTS `" + name + "`"
+	case s.scheme == schemePostgres:
+		exists, err := s.tenantExists(ctx, name)
+		if err != nil || exists {
+			return err
+		}
+		query = "CREATE DATABASE `" + name + "`"
+	default:
+		query = GenerateDBScheme("`" + name + "`")
+	}
+	_, err := s.ExecQuery(ctx, query)
+	if err != nil && s.scheme == schemePostgres && s.tenancy == database.TenancyDatabase {
+		// the database may be created by another client concurrently
+		if exists, _ := s.tenantExists(ctx, name); exists {
+			return nil
+		}
+	}
+	return err
+}
+
+// tenantExists check if the postgres database exists, postgres has no CREATE DATABASE IF NOT EXISTS.
+func (s *SQL) tenantExists(ctx context.Context, name string) (bool, error) {
+	var count int
+	err := s.QueryRowContext(ctx, postgres.ConvertSQL("SELECT COUNT(*) FROM pg_database WHERE datname = ?"),
+		name).Scan(&count)
+	if err != nil {
+		return false, convertSQLError(s.scheme, err)
+	}
+	return count > 0, nil
+}
+
+// ProvisionTenant implements database.TenantManager, it creates the schema or database of the project,
+// it is a no-op for tenancy=column.
+func (s *SQL) ProvisionTenant(ctx context.Context, project string) error {
+	if s.tenants == nil {
+		return nil
+	}
+	_, err := s.tenants.get(ctx, project)
+	return err
+}
+
+// ListTenants implements database.TenantManager, it returns the projects of the schemas or databases
+// which are named by database.TenantName.
+func (s *SQL) ListTenants(ctx context.Context) ([]string, error) {
+	if s.tenants == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the tenants are listed with tenancy=schema or database")
+	}
+	root := s.tenants.root
+	names, err := root.tenantNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix := root.tenantBase() + "_"
+	var projects []string
+	for _, name := range names {
+		project, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if _, err = database.TenantName(root.tenantBase(), project); err == nil {
+			projects = append(projects, project)
+		}
+	}
+	slices.Sort(projects)
+	return projects, nil
+}
+
+// tenantNames the names of the schemas or databases which may be the tenants.
+func (s *SQL) tenantNames(ctx context.Context) ([]string, error) {
+	var query string
+	switch {
+	case s.scheme == schemeSQLite:
+		if sqlite.IsMemory(s.uri) {
+			s.tenants.mu.Lock()
+			defer s.tenants.mu.Unlock()
+			names := make([]string, 0, len(s.tenants.dbs))
+			for project := range s.tenants.dbs {
+				names = append(names, s.tenantBase()+"_"+project)
+			}
+			return names, nil
+		}
+		file := s.uri.Host + s.uri.Path
+		ext := filepath.Ext(file)
+		files, err := filepath.Glob(filepath.Join(filepath.Dir(file), s.tenantBase()+"_*"+ext))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list tenants error %v", err)
+		}
+		names := make([]string, len(files))
+		for i, file := range files {
+			names[i] = strings.TrimSuffix(filepath.Base(file), ext)
+		}
+		return names, nil
+	case s.scheme == schemePostgres && s.tenancy == database.TenancyDatabase:
+		query = "SELECT datname FROM pg_database"
+	default:
+		// the databases of mysql are the schemas
+		query = "SELECT schema_name FROM information_schema.schemata"
+	}
+	rows, err := s.QueryContext(ctx, query)
+	if err != nil {
+		return nil, convertSQLError(s.scheme, err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, convertSQLError(s.scheme, err)
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, convertSQLError(s.scheme, err)
+	}
+	return names, nil
+}
@@ -0,0 +1,142 @@
+package sql
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSQLiteTenancy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := database.New(ctx, "sqlite://"+dir+"/app.db?tenancy=database&createdAt=created_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close(ctx)
+	})
+	for _, project := range []string{"p1", "p2"} {
+		tenant, err := db.GetDatabase(ctx, project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = database.Unscoped(tenant).(*SQL).EnsureTable(ctx, "users", &sqliteUser{}); err != nil {
+			t.Fatal(err)
+		}
+		if err = tenant.InsertOne(ctx, "users", &sqliteUser{Name: project}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(dir, "app_p1.db")); err != nil {
+		t.Fatalf("the database of the tenant is not created: %v", err)
+	}
+	p1, err := db.GetDatabase(ctx, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p1.InsertOne(ctx, "users", &sqliteUser{Name: "p1-2"}); err != nil {
+		t.Fatal(err)
+	}
+	count, err := p1.Count(ctx, "users", nil)
+	if err != nil || count != 2 {
+		t.Fatalf("expect 2 users of p1, got %d %v", count, err)
+	}
+	if exist, _ := p1.Exist(ctx, "users", database.C{{Key: "name", Value: "p2"}}); exist {
+		t.Fatal("the user of p2 is visible to p1")
+	}
+	if err = database.ProvisionTenant(ctx, db, "p3"); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := database.ListTenants(ctx, db)
+	if err != nil || !slices.Equal(tenants, []string{"p1", "p2", "p3"}) {
+		t.Fatalf("unexpected tenants %v %v", tenants, err)
+	}
+	if _, err = db.GetDatabase(ctx, "p-4"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect InvalidArgument of the invalid tenant, got %v", err)
+	}
+
+	// the closed client of the tenant is opened again
+	if err = p1.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p1, err = db.GetDatabase(ctx, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if count, err = p1.Count(ctx, "users", nil); err != nil || count != 2 {
+		t.Fatalf("expect 2 users of the reopened p1, got %d %v", count, err)
+	}
+
+	// the concurrent callers share the client of the tenant
+	var wg sync.WaitGroup
+	clients := make([]database.Database, 8)
+	for i := range clients {
+		wg.Go(func() {
+			clients[i], _ = db.GetDatabase(ctx, "p5")
+		})
+	}
+	wg.Wait()
+	for _, client := range clients {
+		if client == nil || database.Unscoped(client).(*SQL).DB != database.Unscoped(clients[0]).(*SQL).DB {
+			t.Fatal("the concurrent callers open different clients of the tenant")
+		}
+	}
+}
+
+func TestTenantURL(t *testing.T) {
+	u, err := url.Parse("postgres://user@127.0.0.1:5432/app?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SQL{uri: u, dbName: "app", scheme: schemePostgres, tenancy: database.TenancySchema}
+	tenant, dbName := s.tenantURL("app_Tenant1")
+	if search := tenant.Query().Get("search_path"); search != `"app_Tenant1"` || dbName != "app" {
+		t.Fatalf("unexpected search path %s of %s", search, dbName)
+	}
+	s.tenancy = database.TenancyDatabase
+	if tenant, dbName = s.tenantURL("app_Tenant1"); tenant.Path != "/app_Tenant1" || dbName != "app_Tenant1" {
+		t.Fatalf("unexpected database %s of %s", dbName, tenant)
+	}
+}
+
+func TestSQLiteTenancyMemory(t *testing.T) {
+	ctx := context.Background()
+	db, err := New(ctx, "sqlite:///:memory:?tenancy=database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := db.(*SQL)
+	t.Cleanup(func() {
+		_ = s.Close(ctx)
+	})
+	p1, err := s.GetDatabase(ctx, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p1.(*SQL).EnsureTable(ctx, "users", &sqliteUser{}); err != nil {
+		t.Fatal(err)
+	}
+	// the in memory database of p2 is a new database without the table of p1
+	p2, err := s.GetDatabase(ctx, "p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p2.Count(ctx, "users", nil); err == nil {
+		t.Fatal("expect the table of p1 is missing in p2")
+	}
+	tenants, err := s.ListTenants(ctx)
+	if err != nil || !slices.Equal(tenants, []string{"p1", "p2"}) {
+		t.Fatalf("unexpected tenants %v %v", tenants, err)
+	}
+	if _, err = New(ctx, "sqlite:///:memory:?tenancy=schema"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect InvalidArgument of tenancy=schema, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ti/common-go/dependencies/database"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordedSpan the name, attributes and status of an ended span
+type recordedSpan struct {
+	name   string
+	attrs  map[attribute.Key]attribute.Value
+	status otelcodes.Code
+	parent *recordedSpan
+}
+
+// spanRecorder a tracer provider which records the ended spans
+type spanRecorder struct {
+	noop.TracerProvider
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordTracer{r: r}
+}
+
+func (r *spanRecorder) find(name string) *recordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.spans {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+type recordTracer struct {
+	noop.Tracer
+	r *spanRecorder
+}
+
+func (t *recordTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	span := &recordSpan{r: t.r, span: &recordedSpan{name: name, attrs: map[attribute.Key]attribute.Value{}}}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordSpan); ok {
+		span.span.parent = parent.span
+	}
+	config := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(config.Attributes()...)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordSpan struct {
+	noop.Span
+	r    *spanRecorder
+	span *recordedSpan
+}
+
+func (s *recordSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, v := range kv {
+		s.span.attrs[v.Key] = v.Value
+	}
+}
+
+func (s *recordSpan) SetStatus(code otelcodes.Code, _ string) {
+	s.span.status = code
+}
+
+func (s *recordSpan) End(...trace.SpanEndOption) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.r.spans = append(s.r.spans, s.span)
+}
+
+func TestSQLiteTracing(t *testing.T) {
+	recorder := &spanRecorder{}
+	otel.SetTracerProvider(recorder)
+	ctx := context.Background()
+	db, err := New(ctx, "sqlite:///:memory:?tracing=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := db.(*SQL)
+	t.Cleanup(func() {
+		_ = s.Close(ctx)
+	})
+	if s.uri.Query().Has("tracing") {
+		t.Fatal("the tracing query is not removed")
+	}
+	if err = s.EnsureTable(ctx, "users", &sqliteUser{}); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := s.StartTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.WithTransaction(ctx, tx).InsertOne(ctx, "users", &sqliteUser{Name: "a", Age: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var user sqliteUser
+	err = s.FindOne(ctx, "users", database.C{
+		{Key: "name", Value: "b"},
+		{C: database.Or, Value: database.C{{Key: "age", Value: 20, C: database.Gt}, {Key: "age", Value: 10}}},
+	}, &user)
+	if err == nil {
+		t.Fatal("the user should not be found")
+	}
+	insert := recorder.find("insertOne users")
+	if insert == nil || insert.attrs["db.system"].AsString() != "sqlite" ||
+		insert.attrs["db.operation"].AsString() != "insertOne" || insert.attrs["db.table"].AsString() != "users" {
+		t.Fatalf("unexpected insert span %+v", insert)
+	}
+	if insert.status == otelcodes.Error {
+		t.Fatal("the insert span should not be an error")
+	}
+	txSpan := recorder.find("transaction")
+	if txSpan == nil {
+		t.Fatal("the transaction span is not ended by the commit")
+	}
+	if insert.parent != txSpan {
+		t.Fatal("the insert span is not a child of the transaction span")
+	}
+	find := recorder.find("findOne users")
+	if find == nil {
+		t.Fatal("the find span is not recorded")
+	}
+	if v := find.attrs["db.condition"].AsString(); v != "name = ? AND (age > ? OR age = ?)" {
+		t.Fatalf("unexpected sanitized condition %s", v)
+	}
+	if find.status != otelcodes.Error || find.attrs["rpc.grpc.status_code"].AsInt64() != 5 {
+		t.Fatalf("the not found error is not recorded %+v", find)
+	}
+}
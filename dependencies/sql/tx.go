@@ -3,29 +3,116 @@ package sql
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 
 	"github.com/ti/common-go/dependencies/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// StartTransaction with database transaction
+// StartTransaction with database transaction, it returns a *Tx, the errors of the commit are converted to
+// the status errors, so the serialization failures and deadlocks are Aborted, see database.RunInTransaction.
 func (s *SQL) StartTransaction(ctx context.Context) (tx database.Transaction, err error) {
 	dbTx, err := s.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, convertError(s.scheme, err)
 	}
-	return dbTx, err
+	// the span of tracing=true is ended by the commit or rollback
+	ctx, done := s.tracer.Start(ctx, "", "transaction", nil)
+	return &Tx{tx: dbTx, ctx: ctx, scheme: s.scheme, done: done}, nil
 }
 
-// WithTransaction with database transaction
+// Tx the transaction of StartTransaction, it implements database.AfterCommitter, use Tx() for the *sql.Tx
+type Tx struct {
+	database.CommitHooks
+	tx *sql.Tx
+	// ctx the ctx of the span of the transaction, the spans of the operations in the transaction are its children
+	ctx    context.Context
+	scheme string
+	done   func(error)
+}
+
+// Tx returns the *sql.Tx of the transaction
+func (t *Tx) Tx() *sql.Tx {
+	return t.tx
+}
+
+// Commit implements database.Transaction
+func (t *Tx) Commit() error {
+	err := t.tx.Commit()
+	if !errors.Is(err, sql.ErrTxDone) {
+		t.done(err)
+	}
+	if err == nil {
+		t.Committed()
+	}
+	return convertError(t.scheme, err)
+}
+
+// Rollback implements database.Transaction
+func (t *Tx) Rollback() error {
+	err := t.tx.Rollback()
+	// the span is ended by the commit if the transaction is done
+	if !errors.Is(err, sql.ErrTxDone) {
+		t.done(err)
+	}
+	return err
+}
+
+// WithTransaction with database transaction, the tx is a *Tx of StartTransaction or is a *sql.Tx,
+// the calls of the database fail with InvalidArgument for the other transactions.
 func (s *SQL) WithTransaction(_ context.Context, tx database.Transaction) database.Database {
+	var txCtx context.Context
+	if t, ok := tx.(*Tx); ok {
+		tx, txCtx = t.tx, t.ctx
+	}
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return database.Failed(status.Errorf(codes.InvalidArgument, "%T is not a transaction of sql", tx))
+	}
 	return &SQL{
-		DB:          s.DB,
-		uri:         s.uri,
-		compactMode: s.compactMode,
-		bustedIndex: s.bustedIndex,
-		tx:          tx.(*sql.Tx),
-		dbName:      s.dbName,
-		scheme:      s.scheme,
-		project:     s.project,
+		DB:              s.DB,
+		uri:             s.uri,
+		loc:             s.loc,
+		compactMode:     s.compactMode,
+		updateDifferent: s.updateDifferent,
+		bustedIndex:     s.bustedIndex,
+		tx:              sqlTx,
+		txCtx:           txCtx,
+		dbName:          s.dbName,
+		scheme:          s.scheme,
+		project:         s.project,
+		replicas:        s.replicas,
+		tenancy:         s.tenancy,
+		tenants:         s.tenants,
+		observer:        s.observer,
+		tracer:          s.tracer,
+	}
+}
+
+// Savepoint implements database.Savepointer, the SQL must be bound to a transaction.
+func (s *SQL) Savepoint(ctx context.Context, name string) error {
+	return s.execSavepoint(ctx, "SAVEPOINT ", name)
+}
+
+// RollbackToSavepoint implements database.Savepointer
+func (s *SQL) RollbackToSavepoint(ctx context.Context, name string) error {
+	return s.execSavepoint(ctx, "ROLLBACK TO SAVEPOINT ", name)
+}
+
+// ReleaseSavepoint implements database.Savepointer
+func (s *SQL) ReleaseSavepoint(ctx context.Context, name string) error {
+	return s.execSavepoint(ctx, "RELEASE SAVEPOINT ", name)
+}
+
+func (s *SQL) execSavepoint(ctx context.Context, statement, name string) error {
+	if s.tx == nil {
+		return status.Error(codes.FailedPrecondition, "the savepoints are used in a transaction")
+	}
+	if name == "" || strings.Contains(name, "`") {
+		return status.Errorf(codes.InvalidArgument, "invalid savepoint %q", name)
 	}
+	_, err := s.ExecQuery(ctx, statement+"`"+name+"`")
+	return err
 }